@@ -0,0 +1,26 @@
+// Copyright Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the License);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an AS IS BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1alpha1
+
+const (
+	// ConditionTypeReady indicates whether all resources requested by the object are configured.
+	ConditionTypeReady = "Ready"
+	// ConditionTypePeersConnected indicates whether services were received from all remote peers.
+	ConditionTypePeersConnected = "PeersConnected"
+	// ConditionTypeExportsPublished indicates whether services matching export rules are published to remote peers.
+	ConditionTypeExportsPublished = "ExportsPublished"
+	// ConditionTypeExportsAccepted indicates whether the remote peer subscribed to exported services and accepted them.
+	ConditionTypeExportsAccepted = "ExportsAccepted"
+)
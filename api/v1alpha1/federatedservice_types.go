@@ -18,27 +18,119 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
-// EDIT THIS FILE!  THIS IS SCAFFOLDING FOR YOU TO OWN!
-// NOTE: json tags are required.  Any new fields you add must have json tags for the fields to be serialized.
-
 // FederatedServiceSpec defines the desired state of FederatedService.
 type FederatedServiceSpec struct {
-	// INSERT ADDITIONAL SPEC FIELDS - desired state of cluster
-	// Important: Run "make" to regenerate code after modifying this file
+	// Name of the remote mesh peer exporting the service.
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MinLength=1
+	Peer string `json:"peer"`
+
+	// Hostname of the service in the remote mesh, e.g. reviews.bookinfo.svc.cluster.local.
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MinLength=1
+	Host string `json:"host"`
+
+	// Ports exposed by the remote service.
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MinItems=1
+	Ports []ServicePort `json:"ports"`
+
+	// Alias is an optional local hostname under which the remote service is exposed in this mesh.
+	// If it is not set, the service is available under the remote hostname.
+	// +kubebuilder:validation:Optional
+	Alias string `json:"alias,omitempty"`
+
+	// TrafficSplit sends a part of requests for the local service with the same hostname to the remote service.
+	// Imported endpoints are not merged with local endpoints then, so the split can be adjusted without changing
+	// local workloads. The service must exist in the local mesh.
+	// +kubebuilder:validation:Optional
+	TrafficSplit *TrafficSplit `json:"trafficSplit,omitempty"`
+}
+
+// TrafficSplit defines how requests are split between local endpoints and the remote service.
+type TrafficSplit struct {
+	// Weight is the percentage of requests sent to the remote service. The remaining requests are sent to local endpoints.
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=100
+	Weight int32 `json:"weight"`
+}
+
+type ServicePort struct {
+	// Name of the port.
+	// +kubebuilder:validation:Required
+	Name string `json:"name"`
+
+	// Port number of the remote service.
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	Number uint32 `json:"number"`
+
+	// Protocol exposed on the port.
+	// +kubebuilder:default:=TCP
+	// +kubebuilder:validation:Enum=HTTP;HTTPS;HTTP2;GRPC;TLS;MONGO;TCP
+	Protocol string `json:"protocol,omitempty"`
 
-	// Foo is an example field of FederatedService. Edit federatedservice_types.go to remove/update
-	Foo string `json:"foo,omitempty"`
+	// Port number of the service workloads.
+	// +kubebuilder:validation:Optional
+	TargetPort uint32 `json:"targetPort,omitempty"`
 }
 
 // FederatedServiceStatus defines the observed state of FederatedService.
 type FederatedServiceStatus struct {
-	// INSERT ADDITIONAL STATUS FIELD - define observed state of cluster
-	// Important: Run "make" to regenerate code after modifying this file
+	// Conditions describes the state of the FederatedService resource.
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+
+	// Imported describes the service as received from remote peers.
+	// It is not set until any remote peer exports the service.
+	// +optional
+	Imported *ImportedService `json:"imported,omitempty"`
+
+	// Resources lists Istio objects generated to import the service.
+	// +optional
+	Resources []ResourceReference `json:"resources,omitempty"`
+}
+
+// ImportedService describes the service exported by remote peers.
+type ImportedService struct {
+	// Hostname of the service in the remote mesh.
+	Hostname string `json:"hostname"`
+
+	// Ports exposed by the service as reported by the peer from spec.
+	// +optional
+	Ports []ServicePort `json:"ports,omitempty"`
+
+	// Labels of the service as reported by the peer from spec.
+	// +optional
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// Peers currently exporting the service.
+	// +optional
+	Peers []string `json:"peers,omitempty"`
+}
+
+// ResourceReference identifies an object generated by the controller.
+type ResourceReference struct {
+	// Kind of the object, e.g. ServiceEntry or WorkloadEntry.
+	Kind string `json:"kind"`
+
+	// Namespace of the object.
+	Namespace string `json:"namespace"`
+
+	// Name of the object.
+	Name string `json:"name"`
 }
 
 // +kubebuilder:object:root=true
 // +kubebuilder:subresource:status
 // +kubebuilder:resource:scope=Namespaced
+// +kubebuilder:printcolumn:name="Peer",type=string,JSONPath=`.spec.peer`
+// +kubebuilder:printcolumn:name="Host",type=string,JSONPath=`.spec.host`
+// +kubebuilder:printcolumn:name="Exported By",type=string,JSONPath=`.status.imported.peers`
+// +kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
 
 // FederatedService is the Schema for the federatedservices API.
 type FederatedService struct {
@@ -76,6 +76,48 @@ type MeshFederationStatus struct {
 	// Conditions describes the state of the MeshFederation resource.
 	// +optional
 	Conditions []metav1.Condition `json:"conditions,omitempty"`
+
+	// Remotes describes the state of connections to remote peers.
+	// +optional
+	// +listType=map
+	// +listMapKey=name
+	Remotes []RemoteStatus `json:"remotes,omitempty"`
+}
+
+// RemoteStatus describes the state of the connection to a remote peer.
+type RemoteStatus struct {
+	// Name of the remote peer
+	Name string `json:"name"`
+
+	// Time when services exported by the remote peer were last received successfully
+	// +optional
+	LastSyncTime *metav1.Time `json:"lastSyncTime,omitempty"`
+
+	// Number of services imported from the remote peer
+	ImportedServices int32 `json:"importedServices"`
+
+	// Last error that occurred while connecting to the remote peer or processing its services
+	// +optional
+	LastError string `json:"lastError,omitempty"`
+
+	// State of the connection to the remote peer
+	// +kubebuilder:validation:Enum=Connecting;Synced;Degraded
+	// +optional
+	State string `json:"state,omitempty"`
+
+	// Time since when updates from the remote peer have been rejected, because they withdrew too many services at once.
+	// The services are kept until the remote peer keeps withdrawing them for longer than the import grace period.
+	// +optional
+	WithdrawalRejectedSince *metav1.Time `json:"withdrawalRejectedSince,omitempty"`
+
+	// Number of streams, over which the remote peer subscribes to services exported to it
+	// +optional
+	Subscribers int32 `json:"subscribers,omitempty"`
+
+	// Reason why the remote peer does not have the current exported services: it rejected them,
+	// or they could not be generated and the remote peer is served the last known-good snapshot
+	// +optional
+	ExportError string `json:"exportError,omitempty"`
 }
 
 type PortConfig struct {
@@ -124,4 +166,10 @@ type ExportRules struct {
 	// An empty service selector matches all Services.
 	// A null service selector matches no Services.
 	ServiceSelectors *metav1.LabelSelector `json:"serviceSelectors,omitempty"`
+
+	// NamespaceSelector is a label query over namespaces, which restricts ServiceSelectors
+	// to Services from namespaces with matching labels.
+	// A null namespace selector does not restrict namespaces.
+	// +optional
+	NamespaceSelector *metav1.LabelSelector `json:"namespaceSelector,omitempty"`
 }
@@ -0,0 +1,104 @@
+// Copyright Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the License);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an AS IS BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// MeshPeerSpec defines how to connect to a remote mesh.
+// The name of the MeshPeer object is used as the name of the remote peer.
+type MeshPeerSpec struct {
+	// Addresses of the remote federation ingress: IP addresses or a single DNS name.
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MinItems=1
+	Addresses []string `json:"addresses"`
+
+	// Port of the remote federation ingress gateway. Defaults to 15443.
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	Port *uint32 `json:"port,omitempty"`
+
+	// Network name of the remote mesh used by Istio for load balancing.
+	// +kubebuilder:validation:Optional
+	Network string `json:"network,omitempty"`
+
+	// Ingress type of the remote mesh, which determines how to reach its exported services.
+	// +kubebuilder:default:=istio
+	// +kubebuilder:validation:Enum=istio;openshift-router
+	IngressType string `json:"ingressType,omitempty"`
+
+	// Trust domain of the remote mesh. Defaults to the trust domain of the local mesh.
+	// +kubebuilder:validation:Optional
+	TrustDomain string `json:"trustDomain,omitempty"`
+
+	// Subject alternative names accepted in the certificate of the remote discovery server, when mTLS is enabled
+	// in the controller. If not set, any SPIFFE ID in the remote trust domain is accepted.
+	// +kubebuilder:validation:Optional
+	SubjectAltNames []string `json:"subjectAltNames,omitempty"`
+
+	// SPIFFE IDs allowed to subscribe to services exported to the remote mesh.
+	// If not set, only the federation controller service account in the controller namespace
+	// of the remote trust domain is allowed.
+	// +kubebuilder:validation:Optional
+	AllowedIdentities []string `json:"allowedIdentities,omitempty"`
+
+	// Namespace of the federation controller in the remote mesh. Defaults to istio-system.
+	// +kubebuilder:validation:Optional
+	ControllerNamespace string `json:"controllerNamespace,omitempty"`
+
+	// Locality of the remote mesh in the form region/zone/subzone, assigned to endpoints of imported services.
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Pattern=`^[^/]+(/[^/]+){0,2}$`
+	Locality string `json:"locality,omitempty"`
+
+	// Priority of the remote mesh in failover from local endpoints. Remote meshes with lower values are preferred.
+	// +kubebuilder:validation:Optional
+	Priority uint32 `json:"priority,omitempty"`
+}
+
+// MeshPeerStatus defines the observed state of MeshPeer.
+type MeshPeerStatus struct {
+	// Conditions describes the state of the MeshPeer resource.
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:scope=Namespaced
+
+// MeshPeer is the Schema for the meshpeers API.
+type MeshPeer struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   MeshPeerSpec   `json:"spec,omitempty"`
+	Status MeshPeerStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// MeshPeerList contains a list of MeshPeer.
+type MeshPeerList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []MeshPeer `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&MeshPeer{}, &MeshPeerList{})
+}
@@ -16,24 +16,31 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
 	"flag"
-	"fmt"
 	"os"
 	"os/signal"
 	"sort"
 	"syscall"
 	"time"
 
+	routev1 "github.com/openshift/api/route/v1"
 	routev1client "github.com/openshift/client-go/route/clientset/versioned"
+	routeinformers "github.com/openshift/client-go/route/informers/externalversions"
+	networkingv1alpha3 "istio.io/client-go/pkg/apis/networking/v1alpha3"
+	securityv1beta1 "istio.io/client-go/pkg/apis/security/v1beta1"
+	istioinformers "istio.io/client-go/pkg/informers/externalversions"
 	istiokube "istio.io/istio/pkg/kube"
 	istiolog "istio.io/istio/pkg/log"
 	"istio.io/istio/pkg/slices"
 	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	"k8s.io/client-go/informers"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	v1 "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
 	// +kubebuilder:scaffold:imports
 	"k8s.io/client-go/rest"
 	ctrl "sigs.k8s.io/controller-runtime"
@@ -42,16 +49,19 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/metrics/server"
 
 	"github.com/openshift-service-mesh/federation/api/v1alpha1"
+	"github.com/openshift-service-mesh/federation/internal/controller"
 	"github.com/openshift-service-mesh/federation/internal/controller/federatedservice"
 	"github.com/openshift-service-mesh/federation/internal/controller/meshfederation"
+	"github.com/openshift-service-mesh/federation/internal/controller/meshpeer"
+	"github.com/openshift-service-mesh/federation/internal/pkg/common"
 	"github.com/openshift-service-mesh/federation/internal/pkg/config"
 	"github.com/openshift-service-mesh/federation/internal/pkg/istio"
 	"github.com/openshift-service-mesh/federation/internal/pkg/legacy/fds"
 	"github.com/openshift-service-mesh/federation/internal/pkg/legacy/informer"
 	"github.com/openshift-service-mesh/federation/internal/pkg/legacy/kube"
 	"github.com/openshift-service-mesh/federation/internal/pkg/legacy/xds"
-	"github.com/openshift-service-mesh/federation/internal/pkg/legacy/xds/adsc"
 	"github.com/openshift-service-mesh/federation/internal/pkg/legacy/xds/adss"
+	"github.com/openshift-service-mesh/federation/internal/pkg/mtls"
 	"github.com/openshift-service-mesh/federation/internal/pkg/networking"
 	"github.com/openshift-service-mesh/federation/internal/pkg/openshift"
 
@@ -69,7 +79,18 @@ var (
 	probeAddr string
 
 	enableLeaderElection,
-	useCtrls bool
+	useCtrls,
+	trustXFCC bool
+
+	resyncPeriod,
+	pushQuietPeriod,
+	pushMaxDelay,
+	importGracePeriod,
+	initialSyncTimeout time.Duration
+
+	importMaxDropRatio float64
+
+	fdsTLS mtls.Config
 
 	loggingOptions = istiolog.DefaultOptions()
 	log            = istiolog.RegisterScope("default", "default logging scope")
@@ -80,7 +101,9 @@ var (
 func init() {
 	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
 	utilruntime.Must(v1alpha1.AddToScheme(scheme))
-	utilruntime.Must(v1alpha1.AddToScheme(scheme))
+	utilruntime.Must(networkingv1alpha3.AddToScheme(scheme))
+	utilruntime.Must(securityv1beta1.AddToScheme(scheme))
+	utilruntime.Must(routev1.AddToScheme(scheme))
 	// +kubebuilder:scaffold:scheme
 }
 
@@ -89,7 +112,8 @@ const reconnectDelay = time.Second * 5
 // parseFlags parses command-line flags using the standard flag package.
 func parseFlags() {
 	flag.StringVar(&meshPeers, "meshPeers", "",
-		"Mesh peers that include address ip/hostname to remote Peer, and the ports for dataplane and discovery")
+		"Mesh peers that include address ip/hostname to remote Peer, and the ports for dataplane and discovery. "+
+			"Remote peers can also be managed at runtime with MeshPeer resources when controllers are enabled.")
 	flag.StringVar(&exportedServiceSet, "exportedServiceSet", "",
 		"ExportedServiceSet that includes selectors to match the services that will be exported")
 	flag.StringVar(&importedServiceSet, "importedServiceSet", "",
@@ -102,6 +126,33 @@ func parseFlags() {
 
 	flag.BoolVar(&useCtrls, "use-ctrls", false,
 		"feature-flag: enables controller-runtime reconcilers instead of legacy mode.")
+	flag.DurationVar(&resyncPeriod, "resync-period", 5*time.Minute,
+		"How often all generated resources are reconciled in legacy mode, regardless of observed changes. Zero disables periodic resync.")
+	flag.DurationVar(&pushQuietPeriod, "push-debounce-quiet-period", 100*time.Millisecond,
+		"How long to wait for further events before pushing changes. Push requests of the same type received in the meantime are merged. Zero disables debouncing.")
+	flag.DurationVar(&pushMaxDelay, "push-debounce-max-delay", time.Second,
+		"Maximum delay of a push caused by debouncing constantly arriving events.")
+	flag.DurationVar(&importGracePeriod, "import-grace-period", 5*time.Minute,
+		"How long services imported from a disconnected remote peer are kept before they are withdrawn, and how long a remote peer must keep withdrawing "+
+			"more services than allowed by import-max-drop-ratio before the withdrawal is accepted. Zero keeps them until the peer is removed.")
+	flag.DurationVar(&initialSyncTimeout, "initial-sync-timeout", 2*time.Minute,
+		"How long to wait for remote peers to sync after start before deleting configs generated for services that are no longer imported.")
+	flag.Float64Var(&importMaxDropRatio, "import-max-drop-ratio", 0.5,
+		"Maximum share of services imported from a remote peer that can be withdrawn by a single update. Larger withdrawals are rejected. 1 disables the check.")
+	flag.StringVar((*string)(&fdsTLS.Source), "fds-tls-source", "",
+		"Source of certificates for native mTLS of FDS connections: files or spiffe. If not set, FDS connections are expected to be secured by the sidecar.")
+	flag.StringVar(&fdsTLS.CertFile, "fds-tls-cert-file", "", "Path to the PEM certificate used when fds-tls-source is files.")
+	flag.StringVar(&fdsTLS.KeyFile, "fds-tls-key-file", "", "Path to the PEM private key used when fds-tls-source is files.")
+	flag.StringVar(&fdsTLS.CAFile, "fds-tls-ca-file", "", "Path to the PEM CA certificates used to verify peers when fds-tls-source is files.")
+	flag.StringVar(&fdsTLS.WorkloadAPIAddr, "spiffe-workload-api-addr", "",
+		"Address of the SPIFFE Workload API used when fds-tls-source is spiffe. Defaults to SPIFFE_ENDPOINT_SOCKET environment variable.")
+	flag.BoolVar(&trustXFCC, "fds-trust-xfcc", true,
+		"Identify FDS subscribers by the x-forwarded-client-cert header set by the sidecar, when native mTLS is not enabled. "+
+			"Keep enabled only if the sidecar terminates mTLS for all inbound connections (STRICT PeerAuthentication), "+
+			"otherwise any client could forge the header. If disabled, native mTLS must be enabled.")
+	flag.StringVar(&fdsTLS.TrustDomain, "trust-domain", "cluster.local",
+		"Trust domain of the local mesh. The FDS certificate must have a SPIFFE ID in this trust domain. "+
+			"Remote peers without an explicit trust domain are expected to use the same one.")
 
 	// Attach Istio logging options to the flag set
 	loggingOptions.AttachFlags(func(_ *[]string, _ string, _ []string, _ string) {
@@ -125,20 +176,48 @@ func main() {
 	if err != nil {
 		log.Fatalf("failed to parse configuration passed to the program arguments: %v", err)
 	}
+	if importMaxDropRatio < 0 || importMaxDropRatio > 1 {
+		log.Fatalf("import-max-drop-ratio must be between 0 and 1, got %v", importMaxDropRatio)
+	}
+	if err := fdsTLS.Validate(); err != nil {
+		log.Fatalf("invalid FDS mTLS configuration: %v", err)
+	}
+	// Subscribers without a SPIFFE ID are rejected, so the controller would not serve any peer.
+	if !fdsTLS.Enabled() && !trustXFCC {
+		log.Fatalf("FDS subscribers cannot be identified: enable native mTLS with --fds-tls-source or trust the sidecar with --fds-trust-xfcc")
+	}
 
 	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer cancel()
 
+	var credentials *mtls.Credentials
+	if fdsTLS.Enabled() {
+		credentialsCtx, cancelCredentials := context.WithTimeout(ctx, time.Minute)
+		credentials, err = mtls.New(credentialsCtx, fdsTLS)
+		cancelCredentials()
+		if err != nil {
+			log.Fatalf("failed to load FDS certificates: %v", err)
+		}
+		defer credentials.Close()
+	}
+
+	meshConfigPushes := xds.NewDebouncer(pushQuietPeriod, pushMaxDelay)
+	go meshConfigPushes.Run(ctx)
+	importedServiceStore := fds.NewImportedServiceStore()
+	peers := fds.NewPeerRegistry(cfg.MeshPeers.Local.Name, importedServiceStore, cfg.ImportedServiceSet, meshConfigPushes.PushRequests(), reconnectDelay,
+		fds.ImportSafety{GracePeriod: importGracePeriod, MaxDropRatio: importMaxDropRatio}, credentials)
+	go peers.WithdrawStaleImports(ctx)
+
 	if useCtrls {
-		runCtrls(ctx, cancel)
+		runCtrls(ctx, cancel, cfg, importedServiceStore, peers)
 	}
 
-	runLegacyMode(ctx, cfg)
+	runLegacyMode(ctx, cfg, importedServiceStore, peers, meshConfigPushes, credentials)
 
 	<-ctx.Done()
 }
 
-func runCtrls(ctx context.Context, cancel context.CancelFunc) {
+func runCtrls(ctx context.Context, cancel context.CancelFunc, cfg *config.Federation, importedServiceStore *fds.ImportedServiceStore, peers *fds.PeerRegistry) {
 	opts := zap.Options{Development: true}
 	opts.BindFlags(flag.CommandLine)
 	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
@@ -157,14 +236,30 @@ func runCtrls(ctx context.Context, cancel context.CancelFunc) {
 		os.Exit(1)
 	}
 
-	if err = meshfederation.NewReconciler(mgr.GetClient()).SetupWithManager(mgr); err != nil {
+	// Services and Namespaces are read from the manager cache, which is also the source of events triggering reconciliation.
+	serviceLister := controller.NewServiceLister(mgr.GetClient())
+	namespaceLister := controller.NewNamespaceLister(mgr.GetClient())
+
+	var trustDomain string
+	if fdsTLS.Enabled() {
+		trustDomain = fdsTLS.TrustDomain
+	}
+	if err = meshfederation.NewReconciler(mgr.GetClient(), serviceLister, namespaceLister, peers, trustDomain).SetupWithManager(mgr); err != nil {
 		log.Errorf("unable to create controller for MeshFederation custom resource: %s", err)
 		os.Exit(1)
 	}
-	if err = federatedservice.NewReconciler(mgr.GetClient()).SetupWithManager(mgr); err != nil {
+	if err = federatedservice.NewReconciler(mgr.GetClient(), *cfg, peers, serviceLister, namespaceLister, importedServiceStore).SetupWithManager(mgr); err != nil {
 		log.Errorf("unable to create FederatedService controller: %s", err)
 		os.Exit(1)
 	}
+	if err = mgr.Add(federatedservice.NewInventory(mgr.GetClient(), cfg.Namespace(), peers, importedServiceStore)); err != nil {
+		log.Errorf("unable to add inventory of imported services: %s", err)
+		os.Exit(1)
+	}
+	if err = meshpeer.NewReconciler(mgr.GetClient(), cfg.Namespace(), peers, cfg.MeshPeers.Remotes).SetupWithManager(mgr); err != nil {
+		log.Errorf("unable to create MeshPeer controller: %s", err)
+		os.Exit(1)
+	}
 	// +kubebuilder:scaffold:builder
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
 		log.Errorf("unable to set up health check: %s", err)
@@ -183,7 +278,9 @@ func runCtrls(ctx context.Context, cancel context.CancelFunc) {
 	}()
 }
 
-func runLegacyMode(ctx context.Context, cfg *config.Federation) {
+func runLegacyMode(ctx context.Context, cfg *config.Federation, importedServiceStore *fds.ImportedServiceStore, peers *fds.PeerRegistry, meshConfigPushes *xds.Debouncer,
+	credentials *mtls.Credentials,
+) {
 	kubeConfig, err := rest.InClusterConfig()
 	if err != nil {
 		log.Fatalf("failed to create in-cluster config: %v", err)
@@ -194,36 +291,61 @@ func runLegacyMode(ctx context.Context, cfg *config.Federation) {
 		log.Fatalf("failed to create Istio client: %v", err)
 	}
 
-	fdsPushRequests := make(chan xds.PushRequest)
-	meshConfigPushRequests := make(chan xds.PushRequest)
+	// Services imported before the restart are restored before connecting to peers and reconciling Istio configs,
+	// so that configs for these services are kept while the peers are not synced yet.
+	checkpointer := fds.NewCheckpointer(istioClient.Kube(), cfg.Namespace(), importedServiceStore)
+	if err := checkpointer.Restore(ctx); err != nil {
+		log.Errorf("failed to restore imported services: %v", err)
+	}
+	go checkpointer.Run(ctx)
+	go peers.AwaitInitialSync(ctx, initialSyncTimeout)
+
+	// The manager serves metrics when controllers are enabled, otherwise they are served here.
+	if !useCtrls {
+		startMetricsServer(ctx, kubeConfig)
+	}
+
+	fdsPushes := xds.NewDebouncer(pushQuietPeriod, pushMaxDelay)
+	go fdsPushes.Run(ctx)
+	fdsPushRequests, meshConfigPushRequests := fdsPushes.PushRequests(), meshConfigPushes.PushRequests()
 
 	informerFactory := informers.NewSharedInformerFactory(istioClient.Kube(), 0)
 	serviceInformer := informerFactory.Core().V1().Services().Informer()
 	serviceLister := informerFactory.Core().V1().Services().Lister()
+	namespaceInformer := informerFactory.Core().V1().Namespaces().Informer()
+	namespaceLister := informerFactory.Core().V1().Namespaces().Lister()
 	informerFactory.Start(ctx.Done())
 
+	namespaceController, err := informer.NewResourceController(namespaceInformer, corev1.Namespace{},
+		informer.NewNamespaceExportEventHandler(*cfg, fdsPushRequests, meshConfigPushRequests))
+	if err != nil {
+		log.Fatalf("failed to create namespace informer: %v", err)
+	}
+	namespaceController.RunAndWait(ctx.Done())
+
 	serviceController, err := informer.NewResourceController(serviceInformer, corev1.Service{},
-		informer.NewServiceExportEventHandler(*cfg, fdsPushRequests, meshConfigPushRequests))
+		informer.NewServiceExportEventHandler(*cfg, namespaceLister, fdsPushRequests, meshConfigPushRequests))
 	if err != nil {
 		log.Fatalf("failed to create service informer: %v", err)
 	}
 	serviceController.RunAndWait(ctx.Done())
 
-	startFederationServer(ctx, cfg, serviceLister, fdsPushRequests)
+	startFederationServer(ctx, cfg, serviceLister, namespaceLister, fdsPushes.Debounced(), peers, credentials)
 
 	if cfg.MeshPeers.Local.IngressType == config.OpenShiftRouter {
-		go resolveRemoteIP(ctx, cfg.MeshPeers.Remotes, meshConfigPushRequests)
+		go resolveRemoteIP(ctx, peers, meshConfigPushRequests)
 	}
 
-	importedServiceStore := fds.NewImportedServiceStore()
 	for _, remote := range cfg.MeshPeers.Remotes {
-		startFDSClient(ctx, remote, meshConfigPushRequests, importedServiceStore)
+		if err := peers.Start(ctx, remote); err != nil {
+			log.Fatalf("failed to start FDS client: %v", err)
+		}
 	}
 
-	startReconciler(ctx, cfg, serviceLister, meshConfigPushRequests, importedServiceStore)
+	startReconciler(ctx, cfg, peers, serviceLister, namespaceLister, meshConfigPushes, importedServiceStore)
 }
 
-func startReconciler(ctx context.Context, cfg *config.Federation, serviceLister v1.ServiceLister, meshConfigPushRequests chan xds.PushRequest, importedServiceStore *fds.ImportedServiceStore) {
+func startReconciler(ctx context.Context, cfg *config.Federation, peers *fds.PeerRegistry, serviceLister v1.ServiceLister, namespaceLister v1.NamespaceLister, meshConfigPushes *xds.Debouncer, importedServiceStore *fds.ImportedServiceStore) {
 
 	kubeConfig, err := rest.InClusterConfig()
 	if err != nil {
@@ -237,41 +359,95 @@ func startReconciler(ctx context.Context, cfg *config.Federation, serviceLister
 
 	namespace := cfg.Namespace()
 
-	istioConfigFactory := istio.NewConfigFactory(*cfg, serviceLister, importedServiceStore, namespace)
+	istioConfigFactory := istio.NewConfigFactory(*cfg, common.LegacyMode, peers, serviceLister, namespaceLister, importedServiceStore, namespace)
 	reconcilers := []kube.Reconciler{
 		kube.NewGatewayResourceReconciler(istioClient, istioConfigFactory),
-		kube.NewServiceEntryReconciler(istioClient, istioConfigFactory),
-		kube.NewWorkloadEntryReconciler(istioClient, istioConfigFactory),
-		kube.NewPeerAuthResourceReconciler(istioClient, namespace),
-	}
-
-	if cfg.MeshPeers.AnyRemotePeerWithOpenshiftRouterIngress() {
-		reconcilers = append(reconcilers, kube.NewDestinationRuleReconciler(istioClient, istioConfigFactory))
+		kube.NewServiceEntryReconciler(istioClient, istioConfigFactory, peers),
+		kube.NewWorkloadEntryReconciler(istioClient, istioConfigFactory, peers),
+		kube.NewPeerAuthResourceReconciler(istioClient, istioConfigFactory),
+		// Remote peers can be added at runtime, so destination rules are reconciled even if no peer requires them yet.
+		kube.NewDestinationRuleReconciler(istioClient, istioConfigFactory, peers),
 	}
 
+	var routeClient routev1client.Interface
 	if cfg.MeshPeers.Local.IngressType == config.OpenShiftRouter {
-		routeClient, err := routev1client.NewForConfig(kubeConfig)
+		routeClient, err = routev1client.NewForConfig(kubeConfig)
 		if err != nil {
 			log.Fatalf("failed to create Route client: %v", err)
 		}
 
 		reconcilers = append(reconcilers, kube.NewEnvoyFilterReconciler(istioClient, istioConfigFactory))
-		reconcilers = append(reconcilers, kube.NewRouteReconciler(routeClient, openshift.NewConfigFactory(*cfg, serviceLister)))
+		reconcilers = append(reconcilers, kube.NewRouteReconciler(routeClient, openshift.NewConfigFactory(*cfg, common.LegacyMode, serviceLister, namespaceLister)))
 	}
 
-	rm := kube.NewReconcilerManager(meshConfigPushRequests, reconcilers...)
+	rm := kube.NewReconcilerManager(meshConfigPushes.Debounced(), resyncPeriod, reconcilers...)
 	if err := rm.ReconcileAll(ctx); err != nil {
 		log.Fatalf("initial Istio resource reconciliation failed: %v", err)
 	}
 
 	go rm.Start(ctx)
+
+	watchGeneratedObjects(ctx, cfg.MeshPeers.Local.Name, istioClient, routeClient, meshConfigPushes.PushRequests())
 }
 
-func startFederationServer(ctx context.Context, cfg *config.Federation, serviceLister v1.ServiceLister, fdsPushRequests chan xds.PushRequest) {
+type generatedObjectWatch struct {
+	informer     cache.SharedIndexInformer
+	resourceType any
+}
+
+// watchGeneratedObjects triggers reconciliation when objects generated by the given instance are modified or deleted by someone else.
+// Routes and EnvoyFilters are watched only if routeClient is not nil.
+func watchGeneratedObjects(ctx context.Context, instance string, istioClient istiokube.Client, routeClient routev1client.Interface,
+	meshConfigPushRequests chan<- xds.PushRequest,
+) {
+	withGeneratedLabels := func(opts *metav1.ListOptions) {
+		opts.LabelSelector = common.OwnerSelector(common.Owner{Mode: common.LegacyMode, Instance: instance})
+	}
+
+	istioInformerFactory := istioinformers.NewSharedInformerFactoryWithOptions(istioClient.Istio(), 0, istioinformers.WithTweakListOptions(withGeneratedLabels))
+	networkingInformers := istioInformerFactory.Networking().V1alpha3()
+	watches := map[string]generatedObjectWatch{
+		xds.GatewayTypeUrl:            {networkingInformers.Gateways().Informer(), networkingv1alpha3.Gateway{}},
+		xds.ServiceEntryTypeUrl:       {networkingInformers.ServiceEntries().Informer(), networkingv1alpha3.ServiceEntry{}},
+		xds.WorkloadEntryTypeUrl:      {networkingInformers.WorkloadEntries().Informer(), networkingv1alpha3.WorkloadEntry{}},
+		xds.DestinationRuleTypeUrl:    {networkingInformers.DestinationRules().Informer(), networkingv1alpha3.DestinationRule{}},
+		xds.PeerAuthenticationTypeUrl: {istioInformerFactory.Security().V1beta1().PeerAuthentications().Informer(), securityv1beta1.PeerAuthentication{}},
+	}
+	if routeClient != nil {
+		routeInformerFactory := routeinformers.NewSharedInformerFactoryWithOptions(routeClient, 0, routeinformers.WithTweakListOptions(withGeneratedLabels))
+		watches[xds.EnvoyFilterTypeUrl] = generatedObjectWatch{networkingInformers.EnvoyFilters().Informer(), networkingv1alpha3.EnvoyFilter{}}
+		watches[xds.RouteTypeUrl] = generatedObjectWatch{routeInformerFactory.Route().V1().Routes().Informer(), routev1.Route{}}
+		routeInformerFactory.Start(ctx.Done())
+	}
+	istioInformerFactory.Start(ctx.Done())
+
+	for typeUrl, watch := range watches {
+		controller, err := informer.NewResourceController(watch.informer, watch.resourceType,
+			informer.NewGeneratedObjectEventHandler(typeUrl, meshConfigPushRequests))
+		if err != nil {
+			log.Fatalf("failed to create informer for generated objects of type %s: %v", typeUrl, err)
+		}
+		go controller.Run(ctx.Done())
+	}
+}
+
+func startFederationServer(ctx context.Context, cfg *config.Federation, serviceLister v1.ServiceLister, namespaceLister v1.NamespaceLister, fdsPushRequests <-chan xds.PushRequest,
+	peers *fds.PeerRegistry, credentials *mtls.Credentials,
+) {
+	// Connections are accepted from the local trust domain and trust domains of registered peers,
+	// and subscribers are then authorized by the identities allowed for the peer they subscribe as.
+	var tlsConfig *tls.Config
+	if credentials != nil {
+		tlsConfig = credentials.ServerConfig(mtls.AuthorizeTrustDomains(peers.TrustDomains))
+	}
 	federationServer := adss.NewServer(
 		fdsPushRequests,
-		fds.NewExportedServicesGenerator(*cfg, serviceLister),
+		tlsConfig,
+		trustXFCC,
+		fds.NewSubscriberAuthorizer(peers, fdsTLS.TrustDomain),
+		fds.NewExportedServicesGenerator(*cfg, serviceLister, namespaceLister),
 	)
+	peers.ReportExports(federationServer)
 
 	go func() {
 		if err := federationServer.Run(ctx); err != nil {
@@ -280,15 +456,27 @@ func startFederationServer(ctx context.Context, cfg *config.Federation, serviceL
 	}()
 }
 
-func resolveRemoteIP(ctx context.Context, remotes []config.Remote, meshConfigPushRequests chan xds.PushRequest) {
+func startMetricsServer(ctx context.Context, kubeConfig *rest.Config) {
+	metricsServer, err := server.NewServer(server.Options{BindAddress: metricsAddr}, kubeConfig, nil)
+	if err != nil {
+		log.Fatalf("failed to create metrics server: %v", err)
+	}
+	go func() {
+		if err := metricsServer.Start(ctx); err != nil {
+			log.Errorf("failed to start metrics server: %v", err)
+		}
+	}()
+}
+
+func resolveRemoteIP(ctx context.Context, peers config.RemoteLister, meshConfigPushRequests chan<- xds.PushRequest) {
 	var prevIPs []string
-	for _, remote := range remotes {
+	for _, remote := range peers.Remotes() {
 		prevIPs = append(prevIPs, networking.Resolve(remote.Addresses[0])...)
 	}
 
 	resolveIPs := func() {
 		var currIPs []string
-		for _, remote := range remotes {
+		for _, remote := range peers.Remotes() {
 			log.Debugf("Resolving %s", remote.Name)
 			currIPs = append(currIPs, networking.Resolve(remote.Addresses[0])...)
 		}
@@ -315,39 +503,3 @@ resolveLoop:
 	}
 
 }
-
-func startFDSClient(ctx context.Context, remote config.Remote, meshConfigPushRequests chan xds.PushRequest, importedServiceStore *fds.ImportedServiceStore) {
-	var discoveryAddr string
-	if networking.IsIP(remote.Addresses[0]) {
-		discoveryAddr = fmt.Sprintf("%s:%d", remote.ServiceFQDN(), remote.ServicePort())
-	} else {
-		discoveryAddr = fmt.Sprintf("%s:%d", remote.Addresses[0], remote.ServicePort())
-	}
-
-	fdsClient, errClient := adsc.New(&adsc.ADSCConfig{
-		RemoteName:    remote.Name,
-		DiscoveryAddr: discoveryAddr,
-		Authority:     remote.ServiceFQDN(),
-		Handlers: map[string]adsc.ResponseHandler{
-			xds.ExportedServiceTypeUrl: fds.NewImportedServiceHandler(importedServiceStore, meshConfigPushRequests),
-		},
-		ReconnectDelay: reconnectDelay,
-	})
-	if errClient != nil {
-		log.Fatalf("failed to create FDS client: %v", errClient)
-	}
-
-	go func() {
-		if errRun := fdsClient.Run(ctx); errRun != nil {
-			log.Errorf("failed to start FDS client, will reconnect in %s: %v", reconnectDelay, errRun)
-			time.AfterFunc(reconnectDelay, func() {
-				if errCtx := ctx.Err(); errCtx != nil {
-					log.Infof("Parent ctx is done: %v", errCtx)
-					return
-				}
-
-				fdsClient.Restart(ctx)
-			})
-		}
-	}()
-}
@@ -0,0 +1,50 @@
+// Copyright Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the License);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an AS IS BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// FieldManager identifies the federation controller as the owner of fields in server-side apply requests.
+const FieldManager = "federation-controller"
+
+// Apply creates or updates a given object using server-side apply.
+// The owner is set as the controller of the object only if both are in the same namespace,
+// because owner references can't point to objects in other namespaces. The owner can be nil.
+func Apply(ctx context.Context, cli client.Client, owner, obj client.Object) error {
+	gvk, err := apiutil.GVKForObject(obj, cli.Scheme())
+	if err != nil {
+		return fmt.Errorf("failed to determine kind of %s/%s: %w", obj.GetNamespace(), obj.GetName(), err)
+	}
+	obj.GetObjectKind().SetGroupVersionKind(gvk)
+
+	if owner != nil && obj.GetNamespace() == owner.GetNamespace() {
+		if errOwner := controllerutil.SetControllerReference(owner, obj, cli.Scheme()); errOwner != nil {
+			return fmt.Errorf("failed to set owner reference on %s %s/%s: %w", gvk.Kind, obj.GetNamespace(), obj.GetName(), errOwner)
+		}
+	}
+
+	if errApply := cli.Patch(ctx, obj, client.Apply, client.ForceOwnership, client.FieldOwner(FieldManager)); errApply != nil {
+		return fmt.Errorf("failed to apply %s %s/%s: %w", gvk.Kind, obj.GetNamespace(), obj.GetName(), errApply)
+	}
+
+	return nil
+}
@@ -0,0 +1,117 @@
+// Copyright Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the License);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an AS IS BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controllertest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	clienttesting "k8s.io/client-go/testing"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// FakeClientset is implemented by fake typed clientsets.
+type FakeClientset interface {
+	PrependReactor(verb, resource string, reaction clienttesting.ReactionFunc)
+}
+
+// ShareObjects makes the fake clientset read and write objects through the given client, so that reconcilers
+// using typed clientsets and reconcilers using the controller-runtime client operate on the same objects.
+func ShareObjects(clientset FakeClientset, c client.Client) {
+	clientset.PrependReactor("*", "*", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		ctx := context.Background()
+		gvk, err := kindFor(c.Scheme(), action.GetResource())
+		if err != nil {
+			return true, nil, err
+		}
+
+		// Actions are distinguished by verbs, because all actions on named objects implement GetAction.
+		switch action.GetVerb() {
+		case "get":
+			get := action.(clienttesting.GetAction)
+			obj, err := newObject(c, gvk)
+			if err != nil {
+				return true, nil, err
+			}
+			return true, obj, c.Get(ctx, client.ObjectKey{Namespace: get.GetNamespace(), Name: get.GetName()}, obj)
+		case "list":
+			list, err := c.Scheme().New(gvk.GroupVersion().WithKind(gvk.Kind + "List"))
+			if err != nil {
+				return true, nil, err
+			}
+			objectList, ok := list.(client.ObjectList)
+			if !ok {
+				return true, nil, fmt.Errorf("%s is not a list", gvk.Kind)
+			}
+			opts := []client.ListOption{client.InNamespace(action.GetNamespace())}
+			if selector := action.(clienttesting.ListAction).GetListRestrictions().Labels; selector != nil {
+				opts = append(opts, client.MatchingLabelsSelector{Selector: selector})
+			}
+			return true, objectList, c.List(ctx, objectList, opts...)
+		case "patch":
+			patch := action.(clienttesting.PatchAction)
+			if patch.GetPatchType() != types.ApplyPatchType {
+				return true, nil, fmt.Errorf("unsupported patch type %s", patch.GetPatchType())
+			}
+			obj, err := newObject(c, gvk)
+			if err != nil {
+				return true, nil, err
+			}
+			if err := json.Unmarshal(patch.GetPatch(), obj); err != nil {
+				return true, nil, err
+			}
+			obj.SetNamespace(patch.GetNamespace())
+			return true, obj, c.Patch(ctx, obj, client.Apply)
+		case "delete":
+			obj, err := newObject(c, gvk)
+			if err != nil {
+				return true, nil, err
+			}
+			obj.SetNamespace(action.GetNamespace())
+			obj.SetName(action.(clienttesting.DeleteAction).GetName())
+			return true, nil, c.Delete(ctx, obj)
+		}
+		return true, nil, fmt.Errorf("unsupported action %s", action.GetVerb())
+	})
+}
+
+// kindFor returns the kind of the given resource registered in the scheme. The fake client has no REST mapper,
+// so resources are guessed from kinds, except for plurals, which the guess gets wrong (e.g. gatewaies).
+func kindFor(scheme *runtime.Scheme, gvr schema.GroupVersionResource) (schema.GroupVersionKind, error) {
+	for gvk := range scheme.AllKnownTypes() {
+		plural, singular := meta.UnsafeGuessKindToResource(gvk)
+		if plural == gvr || singular.GroupVersion().WithResource(singular.Resource+"s") == gvr {
+			return gvk, nil
+		}
+	}
+	return schema.GroupVersionKind{}, fmt.Errorf("no kind registered for %s", gvr)
+}
+
+func newObject(c client.Client, gvk schema.GroupVersionKind) (client.Object, error) {
+	obj, err := c.Scheme().New(gvk)
+	if err != nil {
+		return nil, err
+	}
+	clientObj, ok := obj.(client.Object)
+	if !ok {
+		return nil, fmt.Errorf("%s is not an object", gvk.Kind)
+	}
+	return clientObj, nil
+}
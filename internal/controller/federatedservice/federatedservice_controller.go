@@ -16,35 +16,453 @@ package federatedservice
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
 
+	"istio.io/client-go/pkg/apis/networking/v1alpha3"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+	v1 "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/util/workqueue"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	federationv1alpha1 "github.com/openshift-service-mesh/federation/api/v1alpha1"
+	"github.com/openshift-service-mesh/federation/internal/api/federation/v1alpha1"
+	"github.com/openshift-service-mesh/federation/internal/controller"
+	"github.com/openshift-service-mesh/federation/internal/pkg/common"
+	"github.com/openshift-service-mesh/federation/internal/pkg/config"
+	"github.com/openshift-service-mesh/federation/internal/pkg/istio"
+	"github.com/openshift-service-mesh/federation/internal/pkg/legacy/fds"
+	"github.com/openshift-service-mesh/federation/internal/pkg/networking"
 )
 
+// errInvalidImport indicates that the FederatedService can't be imported until its spec is fixed.
+var errInvalidImport = errors.New("invalid import")
+
 // +kubebuilder:rbac:groups=federation.openshift-service-mesh.io,resources=federatedservices,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=federation.openshift-service-mesh.io,resources=federatedservices/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=federation.openshift-service-mesh.io,resources=federatedservices/finalizers,verbs=update
+// +kubebuilder:rbac:groups=networking.istio.io,resources=serviceentries;workloadentries;destinationrules;virtualservices,verbs=get;list;watch;create;update;patch;delete
 
 // Reconciler ensure that cluster is configured according to the spec defined in FederatedService
 type Reconciler struct {
 	client.Client
+	cfg                  config.Federation
+	peers                *fds.PeerRegistry
+	serviceLister        v1.ServiceLister
+	namespaceLister      v1.NamespaceLister
+	importedServiceStore *fds.ImportedServiceStore
 }
 
-func NewReconciler(c client.Client) *Reconciler {
-	return &Reconciler{Client: c}
+func NewReconciler(c client.Client, cfg config.Federation, peers *fds.PeerRegistry, serviceLister v1.ServiceLister, namespaceLister v1.NamespaceLister,
+	importedServiceStore *fds.ImportedServiceStore,
+) *Reconciler {
+	return &Reconciler{
+		Client:               c,
+		cfg:                  cfg,
+		peers:                peers,
+		serviceLister:        serviceLister,
+		namespaceLister:      namespaceLister,
+		importedServiceStore: importedServiceStore,
+	}
 }
 
+// Reconcile imports a service exported by the remote peer. The service is imported as a ServiceEntry,
+// or as WorkloadEntries if the service also exists in the local mesh, unless traffic is split between local endpoints
+// and the remote service by a VirtualService. Generated resources are created in the namespace
+// of the FederatedService and are owned by it, so they are garbage collected when the FederatedService is deleted.
+// The status reports the service as received from remote peers and resources generated for it.
+// FederatedServices discovered by the Inventory only report the service and objects generated for it in legacy mode.
 func (r *Reconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
-	log.FromContext(ctx).Info("Reconciling object", "name", req.Name, "namespace", req.Namespace)
+	federatedService := &federationv1alpha1.FederatedService{}
+	if err := r.Client.Get(ctx, req.NamespacedName, federatedService); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	status := federatedService.Status.DeepCopy()
+	status.Imported = r.importedService(federatedService)
+
+	var resources []federationv1alpha1.ResourceReference
+	var errReconcile error
+	if isDiscovered(federatedService) {
+		resources, errReconcile = r.generatedResources(ctx, federatedService)
+	} else {
+		resources, errReconcile = r.reconcileObjects(ctx, federatedService)
+	}
+	if errReconcile == nil {
+		status.Resources = resources
+	}
+	meta.SetStatusCondition(&status.Conditions, readyCondition(federatedService, errReconcile))
+
+	if errStatus := r.updateStatus(ctx, federatedService, status); errStatus != nil {
+		return ctrl.Result{}, errors.Join(errReconcile, errStatus)
+	}
+	if errors.Is(errReconcile, errInvalidImport) {
+		// There is no point in retrying until the spec is changed.
+		return ctrl.Result{}, nil
+	}
+	if errReconcile != nil {
+		return ctrl.Result{}, errReconcile
+	}
+
 	return ctrl.Result{}, nil
 }
 
-// SetupWithManager sets up the controller with the Manager.
+// reconcileObjects applies resources importing the service and removes these which are not desired anymore.
+// It returns references to all applied resources.
+func (r *Reconciler) reconcileObjects(ctx context.Context, federatedService *federationv1alpha1.FederatedService) ([]federationv1alpha1.ResourceReference, error) {
+	desired, errGenerate := r.generateObjects(federatedService)
+	if errGenerate != nil {
+		return nil, errGenerate
+	}
+
+	var resources []federationv1alpha1.ResourceReference
+	desiredKeys := sets.New[types.NamespacedName]()
+	for _, obj := range desired {
+		if errApply := controller.Apply(ctx, r.Client, federatedService, obj); errApply != nil {
+			return nil, errApply
+		}
+		desiredKeys.Insert(client.ObjectKeyFromObject(obj))
+		resources = append(resources, federationv1alpha1.ResourceReference{
+			Kind:      obj.GetObjectKind().GroupVersionKind().Kind,
+			Namespace: obj.GetNamespace(),
+			Name:      obj.GetName(),
+		})
+	}
+
+	for _, list := range []client.ObjectList{
+		&v1alpha3.ServiceEntryList{},
+		&v1alpha3.WorkloadEntryList{},
+		&v1alpha3.DestinationRuleList{},
+		&v1alpha3.VirtualServiceList{},
+	} {
+		if errPrune := r.prune(ctx, federatedService, list, desiredKeys); errPrune != nil {
+			return nil, errPrune
+		}
+	}
+
+	return resources, nil
+}
+
+// SetupWithManager sets up the controller with the Manager. Changes of services imported from remote peers
+// trigger reconciliation of all FederatedServices, so that their status is up-to-date.
 func (r *Reconciler) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&federationv1alpha1.FederatedService{}).
+		Owns(&v1alpha3.ServiceEntry{}).
+		Owns(&v1alpha3.WorkloadEntry{}).
+		Owns(&v1alpha3.DestinationRule{}).
+		Owns(&v1alpha3.VirtualService{}).
+		Watches(&v1alpha3.ServiceEntry{}, handler.EnqueueRequestsFromMapFunc(r.enqueueDiscoveredServices)).
+		Watches(&v1alpha3.WorkloadEntry{}, handler.EnqueueRequestsFromMapFunc(r.enqueueDiscoveredServices)).
+		Watches(&v1alpha3.DestinationRule{}, handler.EnqueueRequestsFromMapFunc(r.enqueueDiscoveredServices)).
+		Watches(&corev1.Service{}, handler.EnqueueRequestsFromMapFunc(r.enqueueFederatedServices)).
+		WatchesRawSource(source.Func(r.watchImportedServices)).
 		Complete(r)
 }
+
+// watchImportedServices enqueues all FederatedServices whenever services imported from remote peers change.
+func (r *Reconciler) watchImportedServices(ctx context.Context, queue workqueue.TypedRateLimitingInterface[reconcile.Request]) error {
+	changes := r.importedServiceStore.Subscribe()
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-changes:
+				federatedServices := &federationv1alpha1.FederatedServiceList{}
+				if err := r.Client.List(ctx, federatedServices); err != nil {
+					log.FromContext(ctx).Error(err, "failed listing FederatedService objects")
+					continue
+				}
+				for _, federatedService := range federatedServices.Items {
+					queue.Add(reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&federatedService)})
+				}
+			}
+		}
+	}()
+	return nil
+}
+
+// enqueueDiscoveredServices triggers reconciliation of discovered FederatedServices, for which the given object
+// was generated in legacy mode.
+func (r *Reconciler) enqueueDiscoveredServices(_ context.Context, obj client.Object) []reconcile.Request {
+	hostname := obj.GetAnnotations()[common.SourceServiceAnnotation]
+	if !labels.SelectorFromSet(common.OwnerLabels(r.legacyOwner())).Matches(labels.Set(obj.GetLabels())) || hostname == "" {
+		return nil
+	}
+	var requests []reconcile.Request
+	for _, peer := range importedFrom(obj) {
+		requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{
+			Namespace: r.cfg.Namespace(),
+			Name:      discoveredName(peer, hostname),
+		}})
+	}
+	return requests
+}
+
+// enqueueFederatedServices triggers reconciliation of FederatedServices imported under the hostname of the given Service,
+// because its existence determines whether the import is configured as a ServiceEntry or WorkloadEntries.
+func (r *Reconciler) enqueueFederatedServices(ctx context.Context, obj client.Object) []reconcile.Request {
+	federatedServices := &federationv1alpha1.FederatedServiceList{}
+	if err := r.Client.List(ctx, federatedServices); err != nil {
+		log.FromContext(ctx).Error(err, "failed listing FederatedService objects")
+		return nil
+	}
+
+	hostname := fmt.Sprintf("%s.%s.svc.cluster.local", obj.GetName(), obj.GetNamespace())
+	var requests []reconcile.Request
+	for _, federatedService := range federatedServices.Items {
+		if localHostname(&federatedService) == hostname {
+			requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&federatedService)})
+		}
+	}
+
+	return requests
+}
+
+// generateObjects returns Istio resources importing the service defined in the FederatedService.
+func (r *Reconciler) generateObjects(federatedService *federationv1alpha1.FederatedService) ([]client.Object, error) {
+	remote, found := r.peers.Remote(federatedService.Spec.Peer)
+	if !found {
+		return nil, fmt.Errorf("%w: unknown peer %q", errInvalidImport, federatedService.Spec.Peer)
+	}
+
+	importedSvc := &v1alpha1.FederatedService{
+		Hostname: localHostname(federatedService),
+	}
+	for _, port := range federatedService.Spec.Ports {
+		importedSvc.Ports = append(importedSvc.Ports, &v1alpha1.ServicePort{
+			Name:       port.Name,
+			Number:     port.Number,
+			Protocol:   port.Protocol,
+			TargetPort: port.TargetPort,
+		})
+	}
+
+	localSvc, errLocalSvc := r.localService(importedSvc.Hostname)
+	if errLocalSvc != nil {
+		return nil, errLocalSvc
+	}
+	if localSvc == nil && federatedService.Spec.TrafficSplit != nil {
+		return nil, fmt.Errorf("%w: traffic can't be split, because Service %s does not exist locally", errInvalidImport, importedSvc.Hostname)
+	}
+	if localSvc != nil {
+		if federatedService.Spec.Alias != "" {
+			return nil, fmt.Errorf("%w: alias %s conflicts with Service %s/%s", errInvalidImport, federatedService.Spec.Alias, localSvc.Namespace, localSvc.Name)
+		}
+		if localSvc.Namespace != federatedService.Namespace {
+			return nil, fmt.Errorf("%w: Service %s/%s must be imported from namespace %s", errInvalidImport, localSvc.Namespace, localSvc.Name, localSvc.Namespace)
+		}
+		// WorkloadEntries must be selected by the local Service to receive traffic.
+		importedSvc.Labels = localSvc.Spec.Selector
+	}
+
+	istioConfigFactory := istio.NewConfigFactory(r.cfg, common.FederatedServiceMode, r.peers, r.serviceLister, r.namespaceLister, fds.NewImportedServiceStore(), r.cfg.Namespace())
+
+	var objects []client.Object
+	if split := federatedService.Spec.TrafficSplit; split != nil {
+		serviceEntry, destinationRule, virtualService := istioConfigFactory.ImportedServiceSplit(remote, federatedService.Spec.Host, importedSvc, split.Weight)
+		objects = append(objects, serviceEntry, destinationRule, virtualService)
+		return r.withImportMetadata(federatedService, objects), nil
+	}
+
+	serviceEntry, errSE := istioConfigFactory.ImportedServiceEntry(remote, importedSvc)
+	if errSE != nil {
+		return nil, errSE
+	}
+	if serviceEntry != nil {
+		objects = append(objects, serviceEntry)
+	}
+
+	workloadEntries, errWE := istioConfigFactory.ImportedWorkloadEntries(remote, importedSvc)
+	if errWE != nil {
+		return nil, errWE
+	}
+	for _, we := range workloadEntries {
+		objects = append(objects, we)
+	}
+
+	if federatedService.Spec.Alias != "" || remote.IngressType == config.OpenShiftRouter || remote.DefinesFailover() {
+		objects = append(objects, istioConfigFactory.ImportedServiceDestinationRule(remote, federatedService.Spec.Host, importedSvc))
+	}
+
+	return r.withImportMetadata(federatedService, objects), nil
+}
+
+// withImportMetadata moves objects to the namespace of the FederatedService and annotates them with the imported hostname.
+func (r *Reconciler) withImportMetadata(federatedService *federationv1alpha1.FederatedService, objects []client.Object) []client.Object {
+	for _, obj := range objects {
+		obj.SetNamespace(federatedService.Namespace)
+		// Objects are generated for the local hostname, which differs from the exported one if the service is aliased.
+		obj.SetAnnotations(common.ImportAnnotations(federatedService.Spec.Host))
+	}
+	return objects
+}
+
+// legacyOwner returns the owner of objects generated in the legacy mode for discovered FederatedServices.
+func (r *Reconciler) legacyOwner() common.Owner {
+	return common.Owner{Mode: common.LegacyMode, Instance: r.cfg.MeshPeers.Local.Name}
+}
+
+// generatedResources returns references to objects generated in legacy mode for the discovered FederatedService.
+func (r *Reconciler) generatedResources(ctx context.Context, federatedService *federationv1alpha1.FederatedService) ([]federationv1alpha1.ResourceReference, error) {
+	var resources []federationv1alpha1.ResourceReference
+	for _, generated := range []struct {
+		kind string
+		list client.ObjectList
+	}{
+		{kind: "ServiceEntry", list: &v1alpha3.ServiceEntryList{}},
+		{kind: "WorkloadEntry", list: &v1alpha3.WorkloadEntryList{}},
+		{kind: "DestinationRule", list: &v1alpha3.DestinationRuleList{}},
+	} {
+		if err := r.Client.List(ctx, generated.list, client.MatchingLabels(common.OwnerLabels(r.legacyOwner()))); err != nil {
+			return nil, fmt.Errorf("failed to list generated objects: %w", err)
+		}
+		if err := meta.EachListItem(generated.list, func(item runtime.Object) error {
+			obj, ok := item.(client.Object)
+			if !ok || obj.GetAnnotations()[common.SourceServiceAnnotation] != federatedService.Spec.Host ||
+				!slices.Contains(importedFrom(obj), federatedService.Spec.Peer) {
+				return nil
+			}
+			resources = append(resources, federationv1alpha1.ResourceReference{
+				Kind:      generated.kind,
+				Namespace: obj.GetNamespace(),
+				Name:      obj.GetName(),
+			})
+			return nil
+		}); err != nil {
+			return nil, err
+		}
+	}
+	return resources, nil
+}
+
+// importedFrom returns peers, from which the service was imported by the given generated object.
+func importedFrom(obj client.Object) []string {
+	if peer := obj.GetLabels()[common.PeerLabel]; peer != "" {
+		return []string{peer}
+	}
+	if peers := obj.GetAnnotations()[common.PeersAnnotation]; peers != "" {
+		return strings.Split(peers, ",")
+	}
+	return nil
+}
+
+// prune deletes objects of the list's kind owned by the FederatedService, which are not desired anymore.
+func (r *Reconciler) prune(ctx context.Context, owner *federationv1alpha1.FederatedService, list client.ObjectList, desiredKeys sets.Set[types.NamespacedName]) error {
+	if err := r.Client.List(ctx, list, client.InNamespace(owner.Namespace)); err != nil {
+		return fmt.Errorf("failed to list imported objects: %w", err)
+	}
+
+	return meta.EachListItem(list, func(item runtime.Object) error {
+		obj, ok := item.(client.Object)
+		if !ok || !metav1.IsControlledBy(obj, owner) || desiredKeys.Has(client.ObjectKeyFromObject(obj)) {
+			return nil
+		}
+		if err := r.Client.Delete(ctx, obj); client.IgnoreNotFound(err) != nil {
+			return fmt.Errorf("failed to delete %s/%s: %w", obj.GetNamespace(), obj.GetName(), err)
+		}
+		log.FromContext(ctx).Info("Deleted imported object", "namespace", obj.GetNamespace(), "name", obj.GetName())
+		return nil
+	})
+}
+
+func (r *Reconciler) updateStatus(ctx context.Context, federatedService *federationv1alpha1.FederatedService, status *federationv1alpha1.FederatedServiceStatus) error {
+	if equality.Semantic.DeepEqual(&federatedService.Status, status) {
+		return nil
+	}
+
+	_, err := controller.RetryStatusUpdate(ctx, r.Client, federatedService, func(saved *federationv1alpha1.FederatedService) {
+		saved.Status = *status
+	})
+	return err
+}
+
+func readyCondition(federatedService *federationv1alpha1.FederatedService, errReconcile error) metav1.Condition {
+	condition := metav1.Condition{
+		Type:               federationv1alpha1.ConditionTypeReady,
+		Status:             metav1.ConditionTrue,
+		Reason:             "Imported",
+		Message:            fmt.Sprintf("Service %s imported from peer %s", federatedService.Spec.Host, federatedService.Spec.Peer),
+		ObservedGeneration: federatedService.Generation,
+	}
+	if isDiscovered(federatedService) {
+		condition.Reason = "Discovered"
+		condition.Message = fmt.Sprintf("Service %s imported from peer %s according to importedServiceSet", federatedService.Spec.Host, federatedService.Spec.Peer)
+	}
+	if errReconcile != nil {
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = "ImportFailed"
+		condition.Message = errReconcile.Error()
+	}
+	return condition
+}
+
+// importedService returns the service as received from remote peers or nil if no peer exports it.
+// Ports and labels are taken from the peer defined in the spec.
+func (r *Reconciler) importedService(federatedService *federationv1alpha1.FederatedService) *federationv1alpha1.ImportedService {
+	var imported *federationv1alpha1.ImportedService
+	for _, remote := range r.peers.Remotes() {
+		for _, svc := range r.importedServiceStore.From(remote) {
+			if svc.Hostname != federatedService.Spec.Host {
+				continue
+			}
+			if imported == nil {
+				imported = &federationv1alpha1.ImportedService{Hostname: svc.Hostname}
+			}
+			imported.Peers = append(imported.Peers, remote.Name)
+			if remote.Name != federatedService.Spec.Peer {
+				continue
+			}
+			imported.Labels = svc.Labels
+			for _, port := range svc.Ports {
+				imported.Ports = append(imported.Ports, federationv1alpha1.ServicePort{
+					Name:       port.Name,
+					Number:     port.Number,
+					Protocol:   port.Protocol,
+					TargetPort: port.TargetPort,
+				})
+			}
+		}
+	}
+	return imported
+}
+
+// localService returns the Service matching given hostname or nil if it does not exist in the local cluster.
+func (r *Reconciler) localService(hostname string) (*corev1.Service, error) {
+	name, namespace, ok := networking.ParseServiceHostname(hostname)
+	if !ok {
+		return nil, nil
+	}
+	svc, err := r.serviceLister.Services(namespace).Get(name)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get Service %s/%s: %w", namespace, name, err)
+	}
+	return svc, nil
+}
+
+// localHostname returns the hostname under which the imported service is available in the local mesh.
+func localHostname(federatedService *federationv1alpha1.FederatedService) string {
+	if federatedService.Spec.Alias != "" {
+		return federatedService.Spec.Alias
+	}
+	return federatedService.Spec.Host
+}
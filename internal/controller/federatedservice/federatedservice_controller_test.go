@@ -0,0 +1,348 @@
+// Copyright Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the License);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an AS IS BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package federatedservice
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	istionetv1alpha3 "istio.io/api/networking/v1alpha3"
+	"istio.io/client-go/pkg/apis/networking/v1alpha3"
+	istiofake "istio.io/client-go/pkg/clientset/versioned/fake"
+	istiokube "istio.io/istio/pkg/kube"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/util/workqueue"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	federationv1alpha1 "github.com/openshift-service-mesh/federation/api/v1alpha1"
+	"github.com/openshift-service-mesh/federation/internal/api/federation/v1alpha1"
+	"github.com/openshift-service-mesh/federation/internal/controller"
+	"github.com/openshift-service-mesh/federation/internal/controller/controllertest"
+	"github.com/openshift-service-mesh/federation/internal/pkg/common"
+	"github.com/openshift-service-mesh/federation/internal/pkg/config"
+	"github.com/openshift-service-mesh/federation/internal/pkg/istio"
+	"github.com/openshift-service-mesh/federation/internal/pkg/legacy/fds"
+	"github.com/openshift-service-mesh/federation/internal/pkg/legacy/kube"
+	"github.com/openshift-service-mesh/federation/internal/pkg/legacy/xds"
+)
+
+const controllerNamespace = "federation-system"
+
+var (
+	ratings = &v1alpha1.FederatedService{
+		Hostname: "ratings.bookinfo.svc.cluster.local",
+		Ports:    []*v1alpha1.ServicePort{{Name: "http", Number: 9080, Protocol: "HTTP"}},
+		Labels:   map[string]string{"app": "ratings"},
+	}
+	reviews = &v1alpha1.FederatedService{
+		Hostname: "reviews.bookinfo.svc.cluster.local",
+		Ports:    []*v1alpha1.ServicePort{{Name: "http", Number: 9080, Protocol: "HTTP"}},
+	}
+)
+
+type testEnv struct {
+	client client.Client
+	store  *fds.ImportedServiceStore
+	peers  *fds.PeerRegistry
+	cfg    config.Federation
+}
+
+func newTestEnv(t *testing.T, objs ...client.Object) *testEnv {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	cfg := config.Federation{
+		MeshPeers: config.MeshPeers{
+			Local: config.Local{
+				Name:         "east",
+				ControlPlane: config.ControlPlane{Namespace: "istio-system"},
+			},
+		},
+	}
+	t.Setenv("POD_NAMESPACE", controllerNamespace)
+	store := fds.NewImportedServiceStore()
+	peers := fds.NewPeerRegistry("east", store, config.ImportedServiceSet{}, make(chan xds.PushRequest, 100), time.Hour, fds.ImportSafety{}, nil)
+	// The client fails to connect and is stopped together with the test.
+	if err := peers.Start(ctx, config.Remote{Name: "west", Addresses: []string{"127.0.0.1"}}); err != nil {
+		t.Fatalf("failed to register peer: %v", err)
+	}
+	return &testEnv{
+		client: controllertest.NewFakeClient(objs...),
+		store:  store,
+		peers:  peers,
+		cfg:    cfg,
+	}
+}
+
+func (e *testEnv) reconciler() *Reconciler {
+	return NewReconciler(e.client, e.cfg, e.peers, controller.NewServiceLister(e.client), controller.NewNamespaceLister(e.client), e.store)
+}
+
+func (e *testEnv) federatedServiceNames(t *testing.T) []string {
+	t.Helper()
+	federatedServices := &federationv1alpha1.FederatedServiceList{}
+	if err := e.client.List(context.Background(), federatedServices); err != nil {
+		t.Fatalf("failed to list FederatedServices: %v", err)
+	}
+	var names []string
+	for _, federatedService := range federatedServices.Items {
+		names = append(names, federatedService.Namespace+"/"+federatedService.Name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestInventory(t *testing.T) {
+	declared := &federationv1alpha1.FederatedService{
+		ObjectMeta: metav1.ObjectMeta{Name: "reviews", Namespace: "bookinfo"},
+		Spec: federationv1alpha1.FederatedServiceSpec{
+			Peer:  "west",
+			Host:  reviews.Hostname,
+			Ports: []federationv1alpha1.ServicePort{{Name: "http", Number: 9080, Protocol: "HTTP"}},
+		},
+	}
+	env := newTestEnv(t, declared)
+	inventory := NewInventory(env.client, controllerNamespace, env.peers, env.store)
+
+	env.store.Update("west", []*v1alpha1.FederatedService{ratings, reviews})
+	if err := inventory.sync(context.Background()); err != nil {
+		t.Fatalf("sync failed: %v", err)
+	}
+	expected := []string{"bookinfo/reviews", controllerNamespace + "/west-ratings-bookinfo-svc-cluster-local"}
+	if names := env.federatedServiceNames(t); !reflect.DeepEqual(names, expected) {
+		t.Errorf("expected FederatedServices %v to be discovered only for services not imported by users, got: %v", expected, names)
+	}
+
+	env.store.Update("west", []*v1alpha1.FederatedService{reviews})
+	if err := inventory.sync(context.Background()); err != nil {
+		t.Fatalf("sync failed: %v", err)
+	}
+	if names := env.federatedServiceNames(t); !reflect.DeepEqual(names, []string{"bookinfo/reviews"}) {
+		t.Errorf("expected discovered FederatedService to be removed when the service is no longer imported, got: %v", names)
+	}
+}
+
+func TestReconcileDiscoveredService(t *testing.T) {
+	discovered := discoveredService(controllerNamespace, "west", ratings)
+	generatedServiceEntry := &v1alpha3.ServiceEntry{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "import-ratings-bookinfo-svc-cluster-local",
+			Namespace:   "istio-system",
+			Labels:      common.OwnerLabels(common.Owner{Mode: common.LegacyMode, Instance: "east"}),
+			Annotations: common.MergedImportAnnotations(ratings.Hostname, []string{"north", "west"}),
+		},
+		Spec: istionetv1alpha3.ServiceEntry{Hosts: []string{ratings.Hostname}},
+	}
+	otherServiceEntry := &v1alpha3.ServiceEntry{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "import-reviews-bookinfo-svc-cluster-local",
+			Namespace:   "istio-system",
+			Labels:      common.OwnerLabels(common.Owner{Mode: common.LegacyMode, Instance: "east"}),
+			Annotations: common.MergedImportAnnotations(reviews.Hostname, []string{"west"}),
+		},
+		Spec: istionetv1alpha3.ServiceEntry{Hosts: []string{reviews.Hostname}},
+	}
+	env := newTestEnv(t, discovered, generatedServiceEntry, otherServiceEntry)
+	env.store.Update("west", []*v1alpha1.FederatedService{ratings})
+
+	if _, err := env.reconciler().Reconcile(context.Background(), ctrl.Request{NamespacedName: client.ObjectKeyFromObject(discovered)}); err != nil {
+		t.Fatalf("reconcile failed: %v", err)
+	}
+
+	saved := &federationv1alpha1.FederatedService{}
+	if err := env.client.Get(context.Background(), client.ObjectKeyFromObject(discovered), saved); err != nil {
+		t.Fatalf("failed to get FederatedService: %v", err)
+	}
+	expectedResources := []federationv1alpha1.ResourceReference{{Kind: "ServiceEntry", Namespace: "istio-system", Name: generatedServiceEntry.Name}}
+	if !reflect.DeepEqual(saved.Status.Resources, expectedResources) {
+		t.Errorf("expected resources generated in legacy mode %v, got: %v", expectedResources, saved.Status.Resources)
+	}
+	if saved.Status.Imported == nil || !reflect.DeepEqual(saved.Status.Imported.Peers, []string{"west"}) || saved.Status.Imported.Labels["app"] != "ratings" {
+		t.Errorf("expected imported service in status, got: %v", saved.Status.Imported)
+	}
+	if condition := meta.FindStatusCondition(saved.Status.Conditions, federationv1alpha1.ConditionTypeReady); condition == nil || condition.Reason != "Discovered" {
+		t.Errorf("expected Ready condition with reason Discovered, got: %v", condition)
+	}
+	serviceEntries := &v1alpha3.ServiceEntryList{}
+	if err := env.client.List(context.Background(), serviceEntries); err != nil {
+		t.Fatalf("failed to list ServiceEntries: %v", err)
+	}
+	if len(serviceEntries.Items) != 2 {
+		t.Errorf("expected no ServiceEntry to be generated for discovered FederatedService, got: %d", len(serviceEntries.Items))
+	}
+}
+
+func TestReconcileDeclaredService(t *testing.T) {
+	declared := &federationv1alpha1.FederatedService{
+		ObjectMeta: metav1.ObjectMeta{Name: "ratings", Namespace: "bookinfo"},
+		Spec: federationv1alpha1.FederatedServiceSpec{
+			Peer:  "west",
+			Host:  ratings.Hostname,
+			Ports: []federationv1alpha1.ServicePort{{Name: "http", Number: 9080, Protocol: "HTTP"}},
+		},
+	}
+	env := newTestEnv(t, declared)
+
+	result, err := env.reconciler().Reconcile(context.Background(), ctrl.Request{NamespacedName: client.ObjectKeyFromObject(declared)})
+	if err != nil {
+		t.Fatalf("reconcile failed: %v", err)
+	}
+	if result.RequeueAfter != 0 {
+		t.Errorf("expected status to be refreshed on changes of imported services instead of polling, got requeue after %s", result.RequeueAfter)
+	}
+
+	saved := &federationv1alpha1.FederatedService{}
+	if err := env.client.Get(context.Background(), client.ObjectKeyFromObject(declared), saved); err != nil {
+		t.Fatalf("failed to get FederatedService: %v", err)
+	}
+	if len(saved.Status.Resources) != 1 || saved.Status.Resources[0].Kind != "ServiceEntry" {
+		t.Errorf("expected ServiceEntry in status, got: %v", saved.Status.Resources)
+	}
+	if saved.Status.Imported != nil {
+		t.Errorf("expected no imported service in status before the peer exports it, got: %v", saved.Status.Imported)
+	}
+}
+
+type allowDeletions struct{}
+
+func (allowDeletions) DeletionsAllowed() bool {
+	return true
+}
+
+func TestReconcileAlongsideLegacyMode(t *testing.T) {
+	ctx := context.Background()
+	declared := &federationv1alpha1.FederatedService{
+		ObjectMeta: metav1.ObjectMeta{Name: "ratings", Namespace: "bookinfo"},
+		Spec: federationv1alpha1.FederatedServiceSpec{
+			Peer:  "west",
+			Host:  ratings.Hostname,
+			Ports: []federationv1alpha1.ServicePort{{Name: "http", Number: 9080, Protocol: "HTTP"}},
+		},
+	}
+	localService := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: "ratings", Namespace: "bookinfo"},
+		Spec: corev1.ServiceSpec{
+			Selector: map[string]string{"app": "ratings"},
+			Ports:    []corev1.ServicePort{{Name: "http", Port: 9080}},
+		},
+	}
+	env := newTestEnv(t, declared, localService)
+	env.store.Update("west", []*v1alpha1.FederatedService{ratings})
+	r := env.reconciler()
+
+	// Legacy reconcilers import the same service from the same peer and write objects to the same fake API server.
+	istioClient := istiokube.NewFakeClient()
+	controllertest.ShareObjects(istioClient.Istio().(*istiofake.Clientset), env.client)
+	istioConfigFactory := istio.NewConfigFactory(env.cfg, common.LegacyMode, env.peers, r.serviceLister, r.namespaceLister, env.store, "istio-system")
+	legacyReconcilers := []kube.Reconciler{
+		kube.NewServiceEntryReconciler(istioClient, istioConfigFactory, allowDeletions{}),
+		kube.NewWorkloadEntryReconciler(istioClient, istioConfigFactory, allowDeletions{}),
+	}
+	reconcileBothModes := func() map[string]string {
+		if _, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(declared)}); err != nil {
+			t.Fatalf("reconcile failed: %v", err)
+		}
+		for _, legacyReconciler := range legacyReconcilers {
+			if err := legacyReconciler.Reconcile(ctx); err != nil {
+				t.Fatalf("legacy reconcile failed: %v", err)
+			}
+		}
+		return env.generatedObjects(t)
+	}
+
+	generated := reconcileBothModes()
+	if regenerated := reconcileBothModes(); !reflect.DeepEqual(generated, regenerated) {
+		t.Errorf("expected generated objects to be stable, got %v, then %v", generated, regenerated)
+	}
+
+	saved := &federationv1alpha1.FederatedService{}
+	if err := env.client.Get(ctx, client.ObjectKeyFromObject(declared), saved); err != nil {
+		t.Fatalf("failed to get FederatedService: %v", err)
+	}
+	workloadEntries := &v1alpha3.WorkloadEntryList{}
+	if err := env.client.List(ctx, workloadEntries, client.InNamespace("bookinfo")); err != nil {
+		t.Fatalf("failed to list WorkloadEntries: %v", err)
+	}
+	var controlled, legacy int
+	for _, we := range workloadEntries.Items {
+		if metav1.IsControlledBy(we, saved) && we.Labels[common.ModeLabel] == string(common.FederatedServiceMode) {
+			controlled++
+		}
+		if we.Labels[common.ModeLabel] == string(common.LegacyMode) && len(we.OwnerReferences) == 0 {
+			legacy++
+		}
+	}
+	if controlled != 1 || legacy != 1 {
+		t.Errorf("expected a WorkloadEntry controlled by the FederatedService and one generated in the legacy mode, got %d and %d",
+			controlled, legacy)
+	}
+}
+
+// generatedObjects returns labels and owners of all ServiceEntries and WorkloadEntries by their kind and name.
+func (e *testEnv) generatedObjects(t *testing.T) map[string]string {
+	t.Helper()
+	objects := make(map[string]string)
+	for _, list := range []client.ObjectList{&v1alpha3.ServiceEntryList{}, &v1alpha3.WorkloadEntryList{}} {
+		if err := e.client.List(context.Background(), list); err != nil {
+			t.Fatalf("failed to list generated objects: %v", err)
+		}
+		if err := meta.EachListItem(list, func(item runtime.Object) error {
+			obj := item.(client.Object)
+			var owners []string
+			for _, ref := range obj.GetOwnerReferences() {
+				owners = append(owners, ref.Kind+"/"+ref.Name)
+			}
+			objects[fmt.Sprintf("%T %s/%s", obj, obj.GetNamespace(), obj.GetName())] = fmt.Sprintf("labels=%v owners=%v", obj.GetLabels(), owners)
+			return nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return objects
+}
+
+func TestImportedServiceChangesEnqueueFederatedServices(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	declared := &federationv1alpha1.FederatedService{ObjectMeta: metav1.ObjectMeta{Name: "ratings", Namespace: "bookinfo"}}
+	env := newTestEnv(t, declared)
+	queue := workqueue.NewTypedRateLimitingQueue(workqueue.DefaultTypedControllerRateLimiter[reconcile.Request]())
+	defer queue.ShutDown()
+
+	if err := env.reconciler().watchImportedServices(ctx, queue); err != nil {
+		t.Fatalf("failed to start watch: %v", err)
+	}
+	env.store.Update("west", []*v1alpha1.FederatedService{ratings})
+
+	deadline := time.Now().Add(5 * time.Second)
+	for queue.Len() == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if queue.Len() != 1 {
+		t.Fatalf("expected FederatedService to be enqueued after imported services changed, got %d requests", queue.Len())
+	}
+	if req, _ := queue.Get(); req.NamespacedName != client.ObjectKeyFromObject(declared) {
+		t.Errorf("expected request for %v, got: %v", client.ObjectKeyFromObject(declared), req)
+	}
+}
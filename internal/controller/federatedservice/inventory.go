@@ -0,0 +1,158 @@
+// Copyright Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the License);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an AS IS BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package federatedservice
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	federationv1alpha1 "github.com/openshift-service-mesh/federation/api/v1alpha1"
+	"github.com/openshift-service-mesh/federation/internal/api/federation/v1alpha1"
+	"github.com/openshift-service-mesh/federation/internal/controller"
+	"github.com/openshift-service-mesh/federation/internal/pkg/common"
+	"github.com/openshift-service-mesh/federation/internal/pkg/config"
+	"github.com/openshift-service-mesh/federation/internal/pkg/legacy/fds"
+)
+
+// inventorySyncRetryDelay is how long to wait before retrying failed synchronization, if imported services do not change.
+const inventorySyncRetryDelay = 5 * time.Second
+
+// Inventory creates FederatedServices for services imported from remote peers according to the importedServiceSet,
+// unless they are already imported by FederatedServices created by users, and removes them when the services
+// are no longer imported. Discovered FederatedServices only report the service and Istio objects generated for it,
+// so together with FederatedServices created by users, they list all services imported to the local mesh.
+type Inventory struct {
+	client               client.Client
+	namespace            string
+	peers                config.RemoteLister
+	importedServiceStore *fds.ImportedServiceStore
+}
+
+// NewInventory creates an inventory, which maintains discovered FederatedServices in the given namespace.
+func NewInventory(c client.Client, namespace string, peers config.RemoteLister, importedServiceStore *fds.ImportedServiceStore) *Inventory {
+	return &Inventory{
+		client:               c,
+		namespace:            namespace,
+		peers:                peers,
+		importedServiceStore: importedServiceStore,
+	}
+}
+
+// Start synchronizes discovered FederatedServices whenever imported services change. It blocks until the context is done.
+func (i *Inventory) Start(ctx context.Context) error {
+	logger := log.FromContext(ctx).WithName("inventory")
+	changes := i.importedServiceStore.Subscribe()
+	for {
+		var retry <-chan time.Time
+		if err := i.sync(ctx); err != nil {
+			logger.Error(err, "failed to synchronize discovered FederatedServices")
+			retry = time.After(inventorySyncRetryDelay)
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-changes:
+		case <-retry:
+		}
+	}
+}
+
+type importKey struct {
+	peer string
+	host string
+}
+
+// sync applies discovered FederatedServices for all imported services and removes the ones, which are not imported anymore
+// or which are now imported by FederatedServices created by users.
+func (i *Inventory) sync(ctx context.Context) error {
+	federatedServices := &federationv1alpha1.FederatedServiceList{}
+	if err := i.client.List(ctx, federatedServices); err != nil {
+		return fmt.Errorf("failed to list FederatedServices: %w", err)
+	}
+	declared := sets.New[importKey]()
+	var discovered []*federationv1alpha1.FederatedService
+	for idx := range federatedServices.Items {
+		federatedService := &federatedServices.Items[idx]
+		if isDiscovered(federatedService) {
+			discovered = append(discovered, federatedService)
+			continue
+		}
+		declared.Insert(importKey{peer: federatedService.Spec.Peer, host: federatedService.Spec.Host})
+	}
+
+	desired := sets.New[string]()
+	for _, remote := range i.peers.Remotes() {
+		for _, svc := range i.importedServiceStore.From(remote) {
+			if declared.Has(importKey{peer: remote.Name, host: svc.Hostname}) {
+				continue
+			}
+			federatedService := discoveredService(i.namespace, remote.Name, svc)
+			if err := controller.Apply(ctx, i.client, nil, federatedService); err != nil {
+				return err
+			}
+			desired.Insert(federatedService.Name)
+		}
+	}
+
+	for _, federatedService := range discovered {
+		if federatedService.Namespace == i.namespace && desired.Has(federatedService.Name) {
+			continue
+		}
+		if err := i.client.Delete(ctx, federatedService); client.IgnoreNotFound(err) != nil {
+			return fmt.Errorf("failed to delete FederatedService %s/%s: %w", federatedService.Namespace, federatedService.Name, err)
+		}
+	}
+	return nil
+}
+
+// discoveredService returns FederatedService describing the service imported from the given peer.
+func discoveredService(namespace, peer string, svc *v1alpha1.FederatedService) *federationv1alpha1.FederatedService {
+	federatedService := &federationv1alpha1.FederatedService{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      discoveredName(peer, svc.Hostname),
+			Namespace: namespace,
+			Labels:    map[string]string{common.DiscoveredLabel: "true"},
+		},
+		Spec: federationv1alpha1.FederatedServiceSpec{
+			Peer: peer,
+			Host: svc.Hostname,
+		},
+	}
+	for _, port := range svc.Ports {
+		federatedService.Spec.Ports = append(federatedService.Spec.Ports, federationv1alpha1.ServicePort{
+			Name:       port.Name,
+			Number:     port.Number,
+			Protocol:   port.Protocol,
+			TargetPort: port.TargetPort,
+		})
+	}
+	return federatedService
+}
+
+// discoveredName returns the name of the discovered FederatedService for the service imported from the given peer.
+func discoveredName(peer, hostname string) string {
+	return fmt.Sprintf("%s-%s", peer, strings.ReplaceAll(hostname, ".", "-"))
+}
+
+func isDiscovered(federatedService *federationv1alpha1.FederatedService) bool {
+	return federatedService.Labels[common.DiscoveredLabel] == "true"
+}
@@ -0,0 +1,92 @@
+// Copyright Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the License);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an AS IS BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	v1 "k8s.io/client-go/listers/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// NewServiceLister returns ServiceLister reading from the given reader, e.g. the cache of the controller manager,
+// so that config factories see the same state of Services as the watches triggering reconciliation.
+func NewServiceLister(reader client.Reader) v1.ServiceLister {
+	return &serviceLister{reader: reader}
+}
+
+// NewNamespaceLister returns NamespaceLister reading from the given reader.
+func NewNamespaceLister(reader client.Reader) v1.NamespaceLister {
+	return &namespaceLister{reader: reader}
+}
+
+type serviceLister struct {
+	reader    client.Reader
+	namespace string
+}
+
+var _ v1.ServiceLister = (*serviceLister)(nil)
+
+func (l *serviceLister) List(selector labels.Selector) ([]*corev1.Service, error) {
+	services := &corev1.ServiceList{}
+	if err := l.reader.List(context.Background(), services, client.InNamespace(l.namespace), client.MatchingLabelsSelector{Selector: selector}); err != nil {
+		return nil, err
+	}
+	result := make([]*corev1.Service, 0, len(services.Items))
+	for idx := range services.Items {
+		result = append(result, &services.Items[idx])
+	}
+	return result, nil
+}
+
+func (l *serviceLister) Services(namespace string) v1.ServiceNamespaceLister {
+	return &serviceLister{reader: l.reader, namespace: namespace}
+}
+
+func (l *serviceLister) Get(name string) (*corev1.Service, error) {
+	svc := &corev1.Service{}
+	if err := l.reader.Get(context.Background(), client.ObjectKey{Namespace: l.namespace, Name: name}, svc); err != nil {
+		return nil, err
+	}
+	return svc, nil
+}
+
+type namespaceLister struct {
+	reader client.Reader
+}
+
+var _ v1.NamespaceLister = (*namespaceLister)(nil)
+
+func (l *namespaceLister) List(selector labels.Selector) ([]*corev1.Namespace, error) {
+	namespaces := &corev1.NamespaceList{}
+	if err := l.reader.List(context.Background(), namespaces, client.MatchingLabelsSelector{Selector: selector}); err != nil {
+		return nil, err
+	}
+	result := make([]*corev1.Namespace, 0, len(namespaces.Items))
+	for idx := range namespaces.Items {
+		result = append(result, &namespaces.Items[idx])
+	}
+	return result, nil
+}
+
+func (l *namespaceLister) Get(name string) (*corev1.Namespace, error) {
+	ns := &corev1.Namespace{}
+	if err := l.reader.Get(context.Background(), client.ObjectKey{Name: name}, ns); err != nil {
+		return nil, err
+	}
+	return ns, nil
+}
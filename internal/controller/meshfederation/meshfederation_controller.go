@@ -16,35 +16,426 @@ package meshfederation
 
 import (
 	"context"
+	"fmt"
+	"strings"
+	"time"
 
+	routev1 "github.com/openshift/api/route/v1"
+	"istio.io/client-go/pkg/apis/networking/v1alpha3"
+	"istio.io/client-go/pkg/apis/security/v1beta1"
+	"istio.io/istio/pkg/slices"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+	v1 "k8s.io/client-go/listers/core/v1"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	"github.com/openshift-service-mesh/federation/api/v1alpha1"
+	"github.com/openshift-service-mesh/federation/internal/controller"
+	"github.com/openshift-service-mesh/federation/internal/controller/finalizer"
+	"github.com/openshift-service-mesh/federation/internal/pkg/common"
+	"github.com/openshift-service-mesh/federation/internal/pkg/config"
+	"github.com/openshift-service-mesh/federation/internal/pkg/istio"
+	"github.com/openshift-service-mesh/federation/internal/pkg/legacy/fds"
+	"github.com/openshift-service-mesh/federation/internal/pkg/openshift"
+)
+
+const (
+	finalizerName = "federation.openshift-service-mesh.io/mesh-federation"
+
+	// statusRefreshInterval defines how often the status of connections to remote peers is refreshed,
+	// as changes in FDS connections do not trigger reconciliation.
+	statusRefreshInterval = 30 * time.Second
 )
 
 // +kubebuilder:rbac:groups=federation.openshift-service-mesh.io,resources=meshfederations,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=federation.openshift-service-mesh.io,resources=meshfederations/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=federation.openshift-service-mesh.io,resources=meshfederations/finalizers,verbs=update
+// +kubebuilder:rbac:groups="",resources=services;namespaces,verbs=get;list;watch
+// +kubebuilder:rbac:groups=networking.istio.io,resources=gateways;envoyfilters,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=security.istio.io,resources=peerauthentications,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=route.openshift.io,resources=routes;routes/custom-host,verbs=get;list;watch;create;update;patch;delete
 
 // Reconciler ensure that cluster is configured according to the spec defined in MeshFederation object.
 type Reconciler struct {
 	client.Client
+	serviceLister   v1.ServiceLister
+	namespaceLister v1.NamespaceLister
+	peers           *fds.PeerRegistry
+	// trustDomain of FDS certificates, which is empty if FDS connections are secured by the sidecar.
+	trustDomain string
 }
 
-func NewReconciler(c client.Client) *Reconciler {
-	return &Reconciler{Client: c}
+func NewReconciler(c client.Client, serviceLister v1.ServiceLister, namespaceLister v1.NamespaceLister, peers *fds.PeerRegistry, trustDomain string) *Reconciler {
+	return &Reconciler{
+		Client:          c,
+		serviceLister:   serviceLister,
+		namespaceLister: namespaceLister,
+		peers:           peers,
+		trustDomain:     trustDomain,
+	}
 }
 
+// Reconcile generates Istio and OpenShift resources exposing exported services to remote peers
+// and reports the state of federation with remote peers in the status.
+// All generated resources are removed by the finalizer when the MeshFederation is deleted.
 func (r *Reconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
-	log.FromContext(ctx).Info("Reconciling object", "namespace", req.Namespace)
-	return ctrl.Result{}, nil
+	logger := log.FromContext(ctx)
+
+	meshFederation := &v1alpha1.MeshFederation{}
+	if err := r.Client.Get(ctx, req.NamespacedName, meshFederation); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	cfg := federationConfig(meshFederation)
+
+	finalizerHandler := finalizer.NewHandler(r.Client, finalizerName)
+	if finalized, errFinalize := finalizerHandler.Finalize(ctx, meshFederation, func() error {
+		logger.Info("Removing resources generated for MeshFederation")
+		return r.cleanup(ctx, meshFederation.Name)
+	}); finalized {
+		return ctrl.Result{}, errFinalize
+	}
+
+	if finalizerAlreadyExists, errAdd := finalizerHandler.Add(ctx, meshFederation); !finalizerAlreadyExists {
+		return ctrl.Result{}, errAdd
+	}
+
+	errReconcile := r.reconcileResources(ctx, meshFederation, cfg)
+	if errStatus := r.updateStatus(ctx, meshFederation, cfg, errReconcile); errStatus != nil {
+		logger.Error(errStatus, "failed updating MeshFederation status")
+		if errReconcile == nil {
+			return ctrl.Result{}, errStatus
+		}
+	}
+	if errReconcile != nil {
+		return ctrl.Result{}, errReconcile
+	}
+
+	return ctrl.Result{RequeueAfter: statusRefreshInterval}, nil
+}
+
+// reconcileResources applies resources generated for the MeshFederation and removes these which are not desired anymore.
+func (r *Reconciler) reconcileResources(ctx context.Context, meshFederation *v1alpha1.MeshFederation, cfg config.Federation) error {
+	if r.trustDomain != "" && meshFederation.Spec.TrustDomain != r.trustDomain {
+		return fmt.Errorf("trust domain %s does not match trust domain %s of FDS certificates", meshFederation.Spec.TrustDomain, r.trustDomain)
+	}
+
+	istioConfigFactory := istio.NewConfigFactory(cfg, common.MeshFederationMode, r.peers, r.serviceLister, r.namespaceLister, fds.NewImportedServiceStore(), cfg.Namespace())
+
+	gateway, errGateway := istioConfigFactory.IngressGateway()
+	if errGateway != nil {
+		return fmt.Errorf("failed generating ingress gateway: %w", errGateway)
+	}
+	if errReconcile := r.reconcileObjects(ctx, meshFederation, &v1alpha3.GatewayList{}, gateway); errReconcile != nil {
+		return errReconcile
+	}
+
+	envoyFilters := slices.Map(istioConfigFactory.EnvoyFilters(), func(ef *v1alpha3.EnvoyFilter) client.Object {
+		return ef
+	})
+	if errReconcile := r.reconcileObjects(ctx, meshFederation, &v1alpha3.EnvoyFilterList{}, envoyFilters...); errReconcile != nil {
+		return errReconcile
+	}
+
+	if errReconcile := r.reconcileObjects(ctx, meshFederation, &v1beta1.PeerAuthenticationList{}, istioConfigFactory.PeerAuthentication()); errReconcile != nil {
+		return errReconcile
+	}
+
+	var routes []client.Object
+	if cfg.MeshPeers.Local.IngressType == config.OpenShiftRouter {
+		generatedRoutes, errRoutes := openshift.NewConfigFactory(cfg, common.MeshFederationMode, r.serviceLister, r.namespaceLister).Routes()
+		if errRoutes != nil {
+			return fmt.Errorf("failed generating routes: %w", errRoutes)
+		}
+		routes = slices.Map(generatedRoutes, func(route *routev1.Route) client.Object {
+			return route
+		})
+	}
+
+	return r.reconcileObjects(ctx, meshFederation, &routev1.RouteList{}, routes...)
+}
+
+// updateStatus sets conditions and the state of connections to remote peers in the MeshFederation status.
+func (r *Reconciler) updateStatus(ctx context.Context, meshFederation *v1alpha1.MeshFederation, cfg config.Federation, errReconcile error) error {
+	var remotes []v1alpha1.RemoteStatus
+	var disconnected, notExported []string
+	for _, peerStatus := range r.peers.Statuses() {
+		remote := v1alpha1.RemoteStatus{
+			Name:             peerStatus.Name,
+			State:            string(peerStatus.State),
+			ImportedServices: int32(peerStatus.ImportedServices),
+			Subscribers:      int32(peerStatus.Subscribers),
+			ExportError:      peerStatus.ExportError,
+		}
+		if !peerStatus.LastSyncTime.IsZero() {
+			remote.LastSyncTime = &metav1.Time{Time: peerStatus.LastSyncTime}
+		}
+		if peerStatus.LastError != nil {
+			remote.LastError = peerStatus.LastError.Error()
+		}
+		if !peerStatus.WithdrawalRejectedSince.IsZero() {
+			remote.WithdrawalRejectedSince = &metav1.Time{Time: peerStatus.WithdrawalRejectedSince}
+		}
+		if !peerStatus.Connected() {
+			disconnected = append(disconnected, peerStatus.Name)
+		}
+		if peerStatus.ExportError != "" {
+			notExported = append(notExported, peerStatus.Name)
+		}
+		remotes = append(remotes, remote)
+	}
+	conditions := []metav1.Condition{
+		readyCondition(errReconcile),
+		r.exportsPublishedCondition(cfg, notExported),
+		peersConnectedCondition(len(remotes), disconnected),
+	}
+
+	_, err := controller.RetryStatusUpdate(ctx, r.Client, meshFederation, func(saved *v1alpha1.MeshFederation) {
+		for _, condition := range conditions {
+			condition.ObservedGeneration = saved.Generation
+			meta.SetStatusCondition(&saved.Status.Conditions, condition)
+		}
+		saved.Status.Remotes = remotes
+	})
+	return err
+}
+
+func readyCondition(errReconcile error) metav1.Condition {
+	if errReconcile != nil {
+		return metav1.Condition{
+			Type:    v1alpha1.ConditionTypeReady,
+			Status:  metav1.ConditionFalse,
+			Reason:  "ReconcileFailed",
+			Message: errReconcile.Error(),
+		}
+	}
+	return metav1.Condition{
+		Type:    v1alpha1.ConditionTypeReady,
+		Status:  metav1.ConditionTrue,
+		Reason:  "Reconciled",
+		Message: "Federation ingress resources are configured",
+	}
+}
+
+// exportsPublishedCondition reports whether exported services are published, and whether all remote peers have them.
+func (r *Reconciler) exportsPublishedCondition(cfg config.Federation, notExported []string) metav1.Condition {
+	exportedServices, err := common.ExportedServices(r.serviceLister, r.namespaceLister, cfg.ExportedServiceSet.Rules)
+	if err != nil {
+		return metav1.Condition{
+			Type:    v1alpha1.ConditionTypeExportsPublished,
+			Status:  metav1.ConditionFalse,
+			Reason:  "ExportFailed",
+			Message: err.Error(),
+		}
+	}
+	if len(notExported) > 0 {
+		return metav1.Condition{
+			Type:    v1alpha1.ConditionTypeExportsPublished,
+			Status:  metav1.ConditionFalse,
+			Reason:  "NotAccepted",
+			Message: fmt.Sprintf("Exported services are not accepted or not served to peers: %s", strings.Join(notExported, ", ")),
+		}
+	}
+	return metav1.Condition{
+		Type:    v1alpha1.ConditionTypeExportsPublished,
+		Status:  metav1.ConditionTrue,
+		Reason:  "Published",
+		Message: fmt.Sprintf("%d services exported to remote peers", len(exportedServices)),
+	}
 }
 
-// SetupWithManager sets up the controller with the Manager.
+func peersConnectedCondition(remotes int, disconnected []string) metav1.Condition {
+	if len(disconnected) > 0 {
+		return metav1.Condition{
+			Type:    v1alpha1.ConditionTypePeersConnected,
+			Status:  metav1.ConditionFalse,
+			Reason:  "PeersNotSynced",
+			Message: fmt.Sprintf("Services not received from peers: %s", strings.Join(disconnected, ", ")),
+		}
+	}
+	if remotes == 0 {
+		return metav1.Condition{
+			Type:    v1alpha1.ConditionTypePeersConnected,
+			Status:  metav1.ConditionTrue,
+			Reason:  "NoPeers",
+			Message: "No remote peers configured",
+		}
+	}
+	return metav1.Condition{
+		Type:    v1alpha1.ConditionTypePeersConnected,
+		Status:  metav1.ConditionTrue,
+		Reason:  "PeersSynced",
+		Message: fmt.Sprintf("Services received from all %d peers", remotes),
+	}
+}
+
+// SetupWithManager sets up the controller with the Manager. Routes are watched only if their API is installed.
 func (r *Reconciler) SetupWithManager(mgr ctrl.Manager) error {
-	return ctrl.NewControllerManagedBy(mgr).
+	b := ctrl.NewControllerManagedBy(mgr).
 		For(&v1alpha1.MeshFederation{}).
-		Complete(r)
+		Owns(&v1alpha3.Gateway{}).
+		Owns(&v1alpha3.EnvoyFilter{}).
+		Owns(&v1beta1.PeerAuthentication{}).
+		Watches(&corev1.Service{}, handler.EnqueueRequestsFromMapFunc(r.enqueueMeshFederations)).
+		Watches(&corev1.Namespace{}, handler.EnqueueRequestsFromMapFunc(r.enqueueMeshFederations),
+			builder.WithPredicates(predicate.LabelChangedPredicate{}))
+
+	routesInstalled, err := apiInstalled(mgr.GetRESTMapper(), routev1.GroupVersion.WithKind("Route"))
+	if err != nil {
+		return err
+	}
+	if routesInstalled {
+		b = b.Owns(&routev1.Route{})
+	}
+	return b.Complete(r)
+}
+
+// apiInstalled returns true if the cluster serves the given kind.
+func apiInstalled(mapper meta.RESTMapper, gvk schema.GroupVersionKind) (bool, error) {
+	if _, err := mapper.RESTMapping(gvk.GroupKind(), gvk.Version); err != nil {
+		if meta.IsNoMatchError(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to discover %s API: %w", gvk.Kind, err)
+	}
+	return true, nil
+}
+
+// enqueueMeshFederations triggers reconciliation of all MeshFederation objects, because any Service change
+// or change of namespace labels may affect the set of exported services.
+func (r *Reconciler) enqueueMeshFederations(ctx context.Context, _ client.Object) []reconcile.Request {
+	meshFederations := &v1alpha1.MeshFederationList{}
+	if err := r.Client.List(ctx, meshFederations); err != nil {
+		log.FromContext(ctx).Error(err, "failed listing MeshFederation objects")
+		return nil
+	}
+
+	requests := make([]reconcile.Request, 0, len(meshFederations.Items))
+	for _, meshFederation := range meshFederations.Items {
+		requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&meshFederation)})
+	}
+
+	return requests
+}
+
+// reconcileObjects applies desired objects and removes previously generated objects of the same kind,
+// which are not desired anymore.
+func (r *Reconciler) reconcileObjects(ctx context.Context, owner *v1alpha1.MeshFederation, list client.ObjectList, desired ...client.Object) error {
+	desiredKeys := sets.New[types.NamespacedName]()
+	for _, obj := range desired {
+		if err := controller.Apply(ctx, r.Client, owner, obj); err != nil {
+			return err
+		}
+		desiredKeys.Insert(client.ObjectKeyFromObject(obj))
+	}
+
+	return r.prune(ctx, owner.GetName(), list, func(obj client.Object) bool {
+		return !desiredKeys.Has(client.ObjectKeyFromObject(obj))
+	})
+}
+
+// prune deletes objects of the list's kind generated by the given federation instance and matching the given predicate.
+// Kinds that are not installed in the cluster (e.g. OpenShift Routes) are skipped.
+func (r *Reconciler) prune(ctx context.Context, instance string, list client.ObjectList, shouldDelete func(obj client.Object) bool) error {
+	if err := r.Client.List(ctx, list, client.MatchingLabels(common.OwnerLabels(common.Owner{Mode: common.MeshFederationMode, Instance: instance}))); err != nil {
+		if meta.IsNoMatchError(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to list generated objects: %w", err)
+	}
+
+	return meta.EachListItem(list, func(item runtime.Object) error {
+		obj, ok := item.(client.Object)
+		if !ok || !shouldDelete(obj) {
+			return nil
+		}
+		if err := r.Client.Delete(ctx, obj); client.IgnoreNotFound(err) != nil {
+			return fmt.Errorf("failed to delete %s/%s: %w", obj.GetNamespace(), obj.GetName(), err)
+		}
+		log.FromContext(ctx).Info("Deleted generated object", "namespace", obj.GetNamespace(), "name", obj.GetName())
+		return nil
+	})
+}
+
+func (r *Reconciler) cleanup(ctx context.Context, instance string) error {
+	deleteAll := func(client.Object) bool {
+		return true
+	}
+	for _, list := range []client.ObjectList{
+		&v1alpha3.GatewayList{},
+		&v1alpha3.EnvoyFilterList{},
+		&v1beta1.PeerAuthenticationList{},
+		&routev1.RouteList{},
+	} {
+		if err := r.prune(ctx, instance, list, deleteAll); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// federationConfig translates MeshFederation spec to the configuration consumed by config factories.
+func federationConfig(meshFederation *v1alpha1.MeshFederation) config.Federation {
+	spec := meshFederation.Spec
+	cfg := config.Federation{
+		MeshPeers: config.MeshPeers{
+			Local: config.Local{
+				Name: meshFederation.Name,
+				ControlPlane: config.ControlPlane{
+					Namespace: spec.ControlPlaneNamespace,
+				},
+				Gateways: config.Gateways{
+					Ingress: config.LocalGateway{
+						Selector: spec.IngressConfig.GatewayConfig.Selector,
+						Port: &config.GatewayPort{
+							Name:   spec.IngressConfig.GatewayConfig.PortConfig.Name,
+							Number: spec.IngressConfig.GatewayConfig.PortConfig.Number,
+						},
+					},
+				},
+				IngressType: config.IngressType(spec.IngressConfig.Type),
+			},
+		},
+	}
+
+	if spec.ExportRules != nil && spec.ExportRules.ServiceSelectors != nil {
+		selector := spec.ExportRules.ServiceSelectors
+		labelSelectors := config.LabelSelectors{
+			MatchLabels:      selector.MatchLabels,
+			MatchExpressions: matchExpressions(selector.MatchExpressions),
+		}
+		if namespaceSelector := spec.ExportRules.NamespaceSelector; namespaceSelector != nil {
+			labelSelectors.NamespaceSelector = &config.NamespaceSelector{
+				MatchLabels:      namespaceSelector.MatchLabels,
+				MatchExpressions: matchExpressions(namespaceSelector.MatchExpressions),
+			}
+		}
+		cfg.ExportedServiceSet.Rules = []config.Rules{{
+			Type:           config.LabelSelectorRule,
+			LabelSelectors: []config.LabelSelectors{labelSelectors},
+		}}
+	}
+
+	return cfg
+}
+
+func matchExpressions(requirements []metav1.LabelSelectorRequirement) []config.MatchExpressions {
+	return slices.Map(requirements, func(req metav1.LabelSelectorRequirement) config.MatchExpressions {
+		return config.MatchExpressions{
+			Key:      req.Key,
+			Operator: string(req.Operator),
+			Values:   req.Values,
+		}
+	})
 }
@@ -0,0 +1,304 @@
+// Copyright Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the License);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an AS IS BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package meshfederation
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	routev1 "github.com/openshift/api/route/v1"
+	routefake "github.com/openshift/client-go/route/clientset/versioned/fake"
+	"istio.io/client-go/pkg/apis/networking/v1alpha3"
+	"istio.io/client-go/pkg/apis/security/v1beta1"
+	istiofake "istio.io/client-go/pkg/clientset/versioned/fake"
+	istiokube "istio.io/istio/pkg/kube"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/openshift-service-mesh/federation/api/v1alpha1"
+	"github.com/openshift-service-mesh/federation/internal/controller"
+	"github.com/openshift-service-mesh/federation/internal/controller/controllertest"
+	"github.com/openshift-service-mesh/federation/internal/pkg/common"
+	"github.com/openshift-service-mesh/federation/internal/pkg/config"
+	"github.com/openshift-service-mesh/federation/internal/pkg/istio"
+	"github.com/openshift-service-mesh/federation/internal/pkg/legacy/fds"
+	"github.com/openshift-service-mesh/federation/internal/pkg/legacy/kube"
+	"github.com/openshift-service-mesh/federation/internal/pkg/legacy/xds"
+	"github.com/openshift-service-mesh/federation/internal/pkg/openshift"
+)
+
+var exportedService = &corev1.Service{
+	ObjectMeta: metav1.ObjectMeta{
+		Name:      "reviews",
+		Namespace: "bookinfo",
+		Labels:    map[string]string{"export": "true"},
+	},
+	Spec: corev1.ServiceSpec{
+		Ports: []corev1.ServicePort{{Name: "http", Port: 9080}},
+	},
+}
+
+func newMeshFederation(ingressType string) *v1alpha1.MeshFederation {
+	return &v1alpha1.MeshFederation{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "east",
+			Namespace: "istio-system",
+		},
+		Spec: v1alpha1.MeshFederationSpec{
+			Network:               "east-network",
+			TrustDomain:           "cluster.local",
+			ControlPlaneNamespace: "istio-system",
+			IngressConfig: v1alpha1.IngressConfig{
+				Type: ingressType,
+				GatewayConfig: v1alpha1.GatewayConfig{
+					Selector:   map[string]string{"app": "federation-ingress-gateway"},
+					PortConfig: v1alpha1.PortConfig{Name: "tls-passthrough", Number: 15443},
+				},
+			},
+			ExportRules: &v1alpha1.ExportRules{
+				ServiceSelectors: &metav1.LabelSelector{MatchLabels: map[string]string{"export": "true"}},
+			},
+		},
+	}
+}
+
+func newReconciler(c client.Client) *Reconciler {
+	peers := fds.NewPeerRegistry("east", fds.NewImportedServiceStore(), config.ImportedServiceSet{}, make(chan xds.PushRequest, 100),
+		time.Second, fds.ImportSafety{}, nil)
+	return NewReconciler(c, controller.NewServiceLister(c), controller.NewNamespaceLister(c), peers, "")
+}
+
+func TestReconcile(t *testing.T) {
+	testCases := []struct {
+		name                 string
+		ingressType          string
+		expectedEnvoyFilters int
+		expectedRoutes       int
+	}{{
+		name:        "istio ingress should be configured with Gateway and PeerAuthentication",
+		ingressType: string(config.Istio),
+	}, {
+		name:                 "openshift-router ingress should also be configured with EnvoyFilters and Routes",
+		ingressType:          string(config.OpenShiftRouter),
+		expectedEnvoyFilters: 2,
+		expectedRoutes:       2,
+	}}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			meshFederation := newMeshFederation(tc.ingressType)
+			c := controllertest.NewFakeClient(meshFederation, exportedService,
+				&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "bookinfo"}})
+			r := newReconciler(c)
+
+			reconcileTwice(t, r, meshFederation)
+
+			saved := &v1alpha1.MeshFederation{}
+			if err := c.Get(ctx, client.ObjectKeyFromObject(meshFederation), saved); err != nil {
+				t.Fatalf("failed to get MeshFederation: %v", err)
+			}
+			if len(saved.Finalizers) != 1 || saved.Finalizers[0] != finalizerName {
+				t.Errorf("expected finalizer %s, got: %v", finalizerName, saved.Finalizers)
+			}
+			for _, conditionType := range []string{v1alpha1.ConditionTypeReady, v1alpha1.ConditionTypeExportsPublished, v1alpha1.ConditionTypePeersConnected} {
+				if !meta.IsStatusConditionTrue(saved.Status.Conditions, conditionType) {
+					t.Errorf("expected condition %s to be true, got: %v", conditionType, saved.Status.Conditions)
+				}
+			}
+
+			expectGenerated(t, c, saved, &v1alpha3.GatewayList{}, 1)
+			expectGenerated(t, c, saved, &v1beta1.PeerAuthenticationList{}, 1)
+			expectGenerated(t, c, saved, &v1alpha3.EnvoyFilterList{}, tc.expectedEnvoyFilters)
+			expectGenerated(t, c, saved, &routev1.RouteList{}, tc.expectedRoutes)
+		})
+	}
+}
+
+func TestReconcileDeletedMeshFederation(t *testing.T) {
+	ctx := context.Background()
+	meshFederation := newMeshFederation(string(config.OpenShiftRouter))
+	c := controllertest.NewFakeClient(meshFederation, exportedService,
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "bookinfo"}})
+	r := newReconciler(c)
+	reconcileTwice(t, r, meshFederation)
+
+	if err := c.Delete(ctx, meshFederation); err != nil {
+		t.Fatalf("failed to delete MeshFederation: %v", err)
+	}
+	reconcileTwice(t, r, meshFederation)
+
+	if err := c.Get(ctx, client.ObjectKeyFromObject(meshFederation), &v1alpha1.MeshFederation{}); !apierrors.IsNotFound(err) {
+		t.Errorf("expected MeshFederation to be removed after finalization, got: %v", err)
+	}
+	for _, list := range []client.ObjectList{&v1alpha3.GatewayList{}, &v1beta1.PeerAuthenticationList{}, &v1alpha3.EnvoyFilterList{}, &routev1.RouteList{}} {
+		if err := c.List(ctx, list); err != nil {
+			t.Fatalf("failed to list generated objects: %v", err)
+		}
+		if count := meta.LenList(list); count != 0 {
+			t.Errorf("expected all generated objects to be removed, got %d of %T", count, list)
+		}
+	}
+}
+
+func TestReconcileAlongsideLegacyMode(t *testing.T) {
+	ctx := context.Background()
+	meshFederation := newMeshFederation(string(config.OpenShiftRouter))
+	c := controllertest.NewFakeClient(meshFederation, exportedService,
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "bookinfo"}})
+	r := newReconciler(c)
+
+	// Legacy reconcilers generate objects from the same configuration and write them to the same fake API server.
+	istioClient := istiokube.NewFakeClient()
+	controllertest.ShareObjects(istioClient.Istio().(*istiofake.Clientset), c)
+	routeClient := routefake.NewSimpleClientset()
+	controllertest.ShareObjects(routeClient, c)
+	cfg := federationConfig(meshFederation)
+	istioConfigFactory := istio.NewConfigFactory(cfg, common.LegacyMode, config.StaticRemotes{}, r.serviceLister, r.namespaceLister,
+		fds.NewImportedServiceStore(), cfg.Namespace())
+	legacyReconcilers := []kube.Reconciler{
+		kube.NewGatewayResourceReconciler(istioClient, istioConfigFactory),
+		kube.NewPeerAuthResourceReconciler(istioClient, istioConfigFactory),
+		kube.NewEnvoyFilterReconciler(istioClient, istioConfigFactory),
+		kube.NewRouteReconciler(routeClient, openshift.NewConfigFactory(cfg, common.LegacyMode, r.serviceLister, r.namespaceLister)),
+	}
+	reconcileBothModes := func() map[string]string {
+		reconcileTwice(t, r, meshFederation)
+		for _, legacyReconciler := range legacyReconcilers {
+			if err := legacyReconciler.Reconcile(ctx); err != nil {
+				t.Fatalf("legacy reconcile failed: %v", err)
+			}
+		}
+		return generatedObjects(t, c)
+	}
+
+	generated := reconcileBothModes()
+	if regenerated := reconcileBothModes(); !reflect.DeepEqual(generated, regenerated) {
+		t.Errorf("expected generated objects to be stable, got %v, then %v", generated, regenerated)
+	}
+
+	saved := &v1alpha1.MeshFederation{}
+	if err := c.Get(ctx, client.ObjectKeyFromObject(meshFederation), saved); err != nil {
+		t.Fatalf("failed to get MeshFederation: %v", err)
+	}
+	for _, tc := range []struct {
+		list     func() client.ObjectList
+		expected int
+	}{
+		{list: func() client.ObjectList { return &v1alpha3.GatewayList{} }, expected: 1},
+		{list: func() client.ObjectList { return &v1beta1.PeerAuthenticationList{} }, expected: 1},
+		{list: func() client.ObjectList { return &v1alpha3.EnvoyFilterList{} }, expected: 2},
+		{list: func() client.ObjectList { return &routev1.RouteList{} }, expected: 2},
+	} {
+		expectGenerated(t, c, saved, tc.list(), tc.expected)
+		legacyObjects := tc.list()
+		if err := c.List(ctx, legacyObjects, client.MatchingLabels(common.OwnerLabels(istioConfigFactory.Owner()))); err != nil {
+			t.Fatalf("failed to list objects generated in the legacy mode: %v", err)
+		}
+		if count := meta.LenList(legacyObjects); count != tc.expected {
+			t.Errorf("expected %d objects of %T generated in the legacy mode, got %d", tc.expected, legacyObjects, count)
+		}
+	}
+}
+
+// generatedObjects returns labels and owners of all generated objects by their kind and name.
+func generatedObjects(t *testing.T, c client.Client) map[string]string {
+	t.Helper()
+	objects := make(map[string]string)
+	for _, list := range []client.ObjectList{&v1alpha3.GatewayList{}, &v1beta1.PeerAuthenticationList{}, &v1alpha3.EnvoyFilterList{}, &routev1.RouteList{}} {
+		if err := c.List(context.Background(), list); err != nil {
+			t.Fatalf("failed to list generated objects: %v", err)
+		}
+		if err := meta.EachListItem(list, func(item runtime.Object) error {
+			obj := item.(client.Object)
+			key := fmt.Sprintf("%T %s/%s", obj, obj.GetNamespace(), obj.GetName())
+			var owners []string
+			for _, ref := range obj.GetOwnerReferences() {
+				owners = append(owners, ref.Kind+"/"+ref.Name)
+			}
+			objects[key] = fmt.Sprintf("labels=%v owners=%v", obj.GetLabels(), owners)
+			return nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return objects
+}
+
+// reconcileTwice runs reconciliation twice, because the first one only adds the finalizer.
+func reconcileTwice(t *testing.T, r *Reconciler, meshFederation *v1alpha1.MeshFederation) {
+	t.Helper()
+	for i := 0; i < 2; i++ {
+		if _, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: client.ObjectKeyFromObject(meshFederation)}); err != nil {
+			t.Fatalf("reconcile failed: %v", err)
+		}
+	}
+}
+
+func expectGenerated(t *testing.T, c client.Client, owner *v1alpha1.MeshFederation, list client.ObjectList, expected int) {
+	t.Helper()
+	if err := c.List(context.Background(), list, client.MatchingLabels(common.OwnerLabels(common.Owner{Mode: common.MeshFederationMode, Instance: owner.Name}))); err != nil {
+		t.Fatalf("failed to list generated objects: %v", err)
+	}
+	if count := meta.LenList(list); count != expected {
+		t.Errorf("expected %d generated objects of %T, got %d", expected, list, count)
+	}
+	if err := meta.EachListItem(list, func(item runtime.Object) error {
+		if obj := item.(client.Object); obj.GetNamespace() == owner.Namespace && !metav1.IsControlledBy(obj, owner) {
+			t.Errorf("expected %s/%s to be controlled by MeshFederation %s", obj.GetNamespace(), obj.GetName(), owner.Name)
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAPIInstalled(t *testing.T) {
+	routeKind := routev1.GroupVersion.WithKind("Route")
+	withRoutes := meta.NewDefaultRESTMapper(nil)
+	withRoutes.Add(routeKind, meta.RESTScopeNamespace)
+
+	testCases := []struct {
+		name      string
+		mapper    meta.RESTMapper
+		installed bool
+	}{{
+		name:      "Route API is installed",
+		mapper:    withRoutes,
+		installed: true,
+	}, {
+		name:   "Route API is not installed",
+		mapper: meta.NewDefaultRESTMapper(nil),
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			installed, err := apiInstalled(tc.mapper, routeKind)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if installed != tc.installed {
+				t.Errorf("expected installed to be %t, got %t", tc.installed, installed)
+			}
+		})
+	}
+}
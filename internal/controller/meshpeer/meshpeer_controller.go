@@ -0,0 +1,211 @@
+// Copyright Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the License);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an AS IS BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package meshpeer
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	"github.com/openshift-service-mesh/federation/api/v1alpha1"
+	"github.com/openshift-service-mesh/federation/internal/controller"
+	"github.com/openshift-service-mesh/federation/internal/pkg/config"
+	"github.com/openshift-service-mesh/federation/internal/pkg/legacy/fds"
+)
+
+// statusRefreshInterval is how often the state of the peer subscription to exported services is updated in the status.
+const statusRefreshInterval = 30 * time.Second
+
+// +kubebuilder:rbac:groups=federation.openshift-service-mesh.io,resources=meshpeers,verbs=get;list;watch
+// +kubebuilder:rbac:groups=federation.openshift-service-mesh.io,resources=meshpeers/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=federation.openshift-service-mesh.io,resources=meshpeers/finalizers,verbs=update
+
+// Reconciler connects FDS clients to remote peers defined by MeshPeer objects.
+// Only MeshPeers created in the namespace of the controller are taken into account,
+// so that the name of the object uniquely identifies the remote peer.
+// Peers configured statically with the --meshPeers flag can't be overridden by MeshPeers.
+type Reconciler struct {
+	client.Client
+	namespace   string
+	peers       *fds.PeerRegistry
+	staticPeers sets.Set[string]
+
+	mu sync.Mutex
+	// started contains peers connected by this reconciler, which are the only peers it may disconnect.
+	started sets.Set[string]
+}
+
+func NewReconciler(c client.Client, namespace string, peers *fds.PeerRegistry, staticPeers []config.Remote) *Reconciler {
+	staticNames := sets.New[string]()
+	for _, remote := range staticPeers {
+		staticNames.Insert(remote.Name)
+	}
+	return &Reconciler{
+		Client:      c,
+		namespace:   namespace,
+		peers:       peers,
+		staticPeers: staticNames,
+		started:     sets.New[string](),
+	}
+}
+
+// Reconcile starts FDS client when MeshPeer is created, restarts it when the spec has changed
+// and stops it when MeshPeer is deleted.
+func (r *Reconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+
+	meshPeer := &v1alpha1.MeshPeer{}
+	if err := r.Client.Get(ctx, req.NamespacedName, meshPeer); err != nil {
+		if apierrors.IsNotFound(err) {
+			r.stop(ctx, req.Name)
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, err
+	}
+
+	if !meshPeer.DeletionTimestamp.IsZero() {
+		r.stop(ctx, meshPeer.Name)
+		return ctrl.Result{}, nil
+	}
+
+	condition := metav1.Condition{
+		Type:               v1alpha1.ConditionTypeReady,
+		Status:             metav1.ConditionTrue,
+		Reason:             "ClientStarted",
+		Message:            fmt.Sprintf("Connecting to peer %s", meshPeer.Name),
+		ObservedGeneration: meshPeer.Generation,
+	}
+	var errStart error
+	if r.staticPeers.Has(meshPeer.Name) {
+		logger.Info("Ignoring MeshPeer, because the peer is configured statically")
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = "ConflictsWithStaticPeer"
+		condition.Message = fmt.Sprintf("Peer %s is configured with the --meshPeers flag", meshPeer.Name)
+	} else if errStart = r.start(ctx, remoteConfig(meshPeer)); errStart != nil {
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = "ClientFailed"
+		condition.Message = errStart.Error()
+	}
+	conditions := []metav1.Condition{condition, r.exportsAcceptedCondition(meshPeer)}
+	changed := false
+	for _, c := range conditions {
+		changed = meta.SetStatusCondition(&meshPeer.Status.Conditions, c) || changed
+	}
+	if changed {
+		if _, errStatus := controller.RetryStatusUpdate(ctx, r.Client, meshPeer, func(saved *v1alpha1.MeshPeer) {
+			for _, c := range conditions {
+				meta.SetStatusCondition(&saved.Status.Conditions, c)
+			}
+		}); errStatus != nil {
+			return ctrl.Result{}, errStatus
+		}
+	}
+	if errStart != nil {
+		return ctrl.Result{}, errStart
+	}
+
+	// Subscriptions of the peer to exported services are not observed by the controller, so the status is refreshed periodically.
+	return ctrl.Result{RequeueAfter: statusRefreshInterval}, nil
+}
+
+// exportsAcceptedCondition reports whether the peer subscribed to exported services and has their current version.
+func (r *Reconciler) exportsAcceptedCondition(meshPeer *v1alpha1.MeshPeer) metav1.Condition {
+	condition := metav1.Condition{
+		Type:               v1alpha1.ConditionTypeExportsAccepted,
+		Status:             metav1.ConditionUnknown,
+		Reason:             "NotSubscribed",
+		Message:            fmt.Sprintf("Peer %s has not subscribed to exported services", meshPeer.Name),
+		ObservedGeneration: meshPeer.Generation,
+	}
+	for _, peerStatus := range r.peers.Statuses() {
+		if peerStatus.Name != meshPeer.Name || peerStatus.Subscribers == 0 {
+			continue
+		}
+		if peerStatus.ExportError != "" {
+			condition.Status = metav1.ConditionFalse
+			condition.Reason = "NotAccepted"
+			condition.Message = peerStatus.ExportError
+		} else {
+			condition.Status = metav1.ConditionTrue
+			condition.Reason = "Accepted"
+			condition.Message = fmt.Sprintf("Peer %s has the current exported services", meshPeer.Name)
+		}
+	}
+	return condition
+}
+
+// start connects FDS client to the peer and records that the peer is managed by MeshPeer.
+func (r *Reconciler) start(ctx context.Context, remote config.Remote) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// The context is bound to the lifetime of the controller, so the client is stopped together with the manager.
+	if err := r.peers.Start(ctx, remote); err != nil {
+		return err
+	}
+	r.started.Insert(remote.Name)
+	return nil
+}
+
+// stop disconnects FDS client from the peer, unless the peer was not connected by this reconciler.
+func (r *Reconciler) stop(ctx context.Context, name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !r.started.Has(name) {
+		return
+	}
+	log.FromContext(ctx).Info("Disconnecting from removed peer")
+	r.peers.Stop(ctx, name)
+	r.started.Delete(name)
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *Reconciler) SetupWithManager(mgr ctrl.Manager) error {
+	inControllerNamespace := predicate.NewPredicateFuncs(func(obj client.Object) bool {
+		return obj.GetNamespace() == r.namespace
+	})
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&v1alpha1.MeshPeer{}, builder.WithPredicates(inControllerNamespace, predicate.GenerationChangedPredicate{})).
+		Complete(r)
+}
+
+// remoteConfig translates MeshPeer spec to the configuration consumed by FDS clients and config factories.
+func remoteConfig(meshPeer *v1alpha1.MeshPeer) config.Remote {
+	return config.Remote{
+		Name:                meshPeer.Name,
+		Addresses:           meshPeer.Spec.Addresses,
+		IngressType:         config.IngressType(meshPeer.Spec.IngressType),
+		Port:                meshPeer.Spec.Port,
+		Network:             meshPeer.Spec.Network,
+		TrustDomain:         meshPeer.Spec.TrustDomain,
+		SubjectAltNames:     meshPeer.Spec.SubjectAltNames,
+		AllowedIdentities:   meshPeer.Spec.AllowedIdentities,
+		ControllerNamespace: meshPeer.Spec.ControllerNamespace,
+		Locality:            meshPeer.Spec.Locality,
+		Priority:            meshPeer.Spec.Priority,
+	}
+}
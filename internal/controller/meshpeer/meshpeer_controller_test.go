@@ -0,0 +1,128 @@
+// Copyright Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the License);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an AS IS BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package meshpeer
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/openshift-service-mesh/federation/api/v1alpha1"
+	"github.com/openshift-service-mesh/federation/internal/controller/controllertest"
+	"github.com/openshift-service-mesh/federation/internal/pkg/config"
+	"github.com/openshift-service-mesh/federation/internal/pkg/legacy/fds"
+	"github.com/openshift-service-mesh/federation/internal/pkg/legacy/xds"
+)
+
+var staticPeer = config.Remote{
+	Name:      "west",
+	Addresses: []string{"192.168.0.1"},
+	Network:   "west-network",
+}
+
+func newMeshPeer(name string, addresses ...string) *v1alpha1.MeshPeer {
+	return &v1alpha1.MeshPeer{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "federation-system"},
+		Spec: v1alpha1.MeshPeerSpec{
+			Addresses: addresses,
+			Network:   name + "-network",
+		},
+	}
+}
+
+func newPeerRegistry(t *testing.T) *fds.PeerRegistry {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	peers := fds.NewPeerRegistry("east", fds.NewImportedServiceStore(), config.ImportedServiceSet{}, make(chan xds.PushRequest, 100),
+		time.Hour, fds.ImportSafety{}, nil)
+	// Clients fail to connect to the peers and are stopped together with the test.
+	if err := peers.Start(ctx, staticPeer); err != nil {
+		t.Fatalf("failed to start static peer: %v", err)
+	}
+	return peers
+}
+
+func TestReconcile(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	north := newMeshPeer("north", "192.168.0.2")
+	c := controllertest.NewFakeClient(north)
+	peers := newPeerRegistry(t)
+	r := NewReconciler(c, "federation-system", peers, []config.Remote{staticPeer})
+
+	reconcileMeshPeer(ctx, t, r, north)
+	if remote, found := peers.Remote("north"); !found || !reflect.DeepEqual(remote.Addresses, north.Spec.Addresses) {
+		t.Errorf("expected peer north to be registered, got: %v", remote)
+	}
+	expectReadyCondition(t, c, north, metav1.ConditionTrue)
+
+	if err := c.Delete(ctx, north); err != nil {
+		t.Fatalf("failed to delete MeshPeer: %v", err)
+	}
+	reconcileMeshPeer(ctx, t, r, north)
+	if _, found := peers.Remote("north"); found {
+		t.Errorf("expected peer north to be removed together with its MeshPeer")
+	}
+	if _, found := peers.Remote("west"); !found {
+		t.Errorf("expected static peer west to stay registered")
+	}
+}
+
+func TestReconcileMeshPeerConflictingWithStaticPeer(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	west := newMeshPeer("west", "10.0.0.1")
+	c := controllertest.NewFakeClient(west)
+	peers := newPeerRegistry(t)
+	r := NewReconciler(c, "federation-system", peers, []config.Remote{staticPeer})
+
+	reconcileMeshPeer(ctx, t, r, west)
+	if remote, _ := peers.Remote("west"); !reflect.DeepEqual(remote, staticPeer) {
+		t.Errorf("expected static peer not to be overridden by MeshPeer, got: %v", remote)
+	}
+	expectReadyCondition(t, c, west, metav1.ConditionFalse)
+
+	if err := c.Delete(ctx, west); err != nil {
+		t.Fatalf("failed to delete MeshPeer: %v", err)
+	}
+	reconcileMeshPeer(ctx, t, r, west)
+	if _, found := peers.Remote("west"); !found {
+		t.Errorf("expected static peer not to be stopped when MeshPeer with the same name is removed")
+	}
+}
+
+func reconcileMeshPeer(ctx context.Context, t *testing.T, r *Reconciler, meshPeer *v1alpha1.MeshPeer) {
+	t.Helper()
+	if _, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(meshPeer)}); err != nil {
+		t.Fatalf("reconcile failed: %v", err)
+	}
+}
+
+func expectReadyCondition(t *testing.T, c client.Client, meshPeer *v1alpha1.MeshPeer, status metav1.ConditionStatus) {
+	t.Helper()
+	saved := &v1alpha1.MeshPeer{}
+	if err := c.Get(context.Background(), client.ObjectKeyFromObject(meshPeer), saved); err != nil {
+		t.Fatalf("failed to get MeshPeer: %v", err)
+	}
+	if condition := meta.FindStatusCondition(saved.Status.Conditions, v1alpha1.ConditionTypeReady); condition == nil || condition.Status != status {
+		t.Errorf("expected Ready condition with status %s, got: %v", status, condition)
+	}
+}
@@ -15,18 +15,109 @@
 package common
 
 import (
+	"fmt"
+	"slices"
+	"sort"
+
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/labels"
+	v1 "k8s.io/client-go/listers/core/v1"
 
 	"github.com/openshift-service-mesh/federation/internal/pkg/config"
 )
 
-func MatchExportRules(svc *corev1.Service, exportedLabelSelectors []config.LabelSelectors) bool {
+// MatchExportRules returns true if the Service matches any of the export rules.
+func MatchExportRules(svc *corev1.Service, namespaceLister v1.NamespaceLister, rules []config.Rules) (bool, error) {
+	for _, rule := range rules {
+		var (
+			matched bool
+			err     error
+		)
+		switch rule.Type {
+		case config.LabelSelectorRule:
+			matched, err = matchLabelSelectors(svc, namespaceLister, rule.LabelSelectors)
+		case config.ServiceNameRule:
+			matched = slices.ContainsFunc(rule.ServiceNames, func(name config.ServiceName) bool {
+				return name.Name == svc.Name && name.Namespace == svc.Namespace
+			})
+		case config.NamespaceRule:
+			matched = slices.Contains(rule.Namespaces, svc.Namespace)
+		case config.HostnameRule:
+			matched = rule.MatchHostname(fmt.Sprintf("%s.%s.svc.cluster.local", svc.Name, svc.Namespace))
+		default:
+			err = fmt.Errorf("unknown rule type %q", rule.Type)
+		}
+		if err != nil || matched {
+			return matched, err
+		}
+	}
+	return false, nil
+}
+
+// matchLabelSelectors returns true if the Service matches any of the label selectors.
+// Namespace labels are read only for selectors restricting namespaces, and Services from namespaces
+// which do not exist anymore never match such selectors.
+func matchLabelSelectors(svc *corev1.Service, namespaceLister v1.NamespaceLister, exportedLabelSelectors []config.LabelSelectors) (bool, error) {
 	for _, selectors := range exportedLabelSelectors {
-		if labels.SelectorFromSet(selectors.MatchLabels).Matches(labels.Set(svc.GetLabels())) {
-			return true
+		serviceSelector, err := selectors.Selector()
+		if err != nil {
+			return false, fmt.Errorf("invalid service selector: %w", err)
+		}
+		if !serviceSelector.Matches(labels.Set(svc.GetLabels())) {
+			continue
+		}
+		if selectors.NamespaceSelector == nil {
+			return true, nil
+		}
+
+		namespaceSelector, err := selectors.NamespaceSelector.Selector()
+		if err != nil {
+			return false, fmt.Errorf("invalid namespace selector: %w", err)
+		}
+		ns, err := namespaceLister.Get(svc.Namespace)
+		if err != nil {
+			if errors.IsNotFound(err) {
+				continue
+			}
+			return false, fmt.Errorf("failed to get namespace %s: %w", svc.Namespace, err)
+		}
+		if namespaceSelector.Matches(labels.Set(ns.GetLabels())) {
+			return true, nil
 		}
-		// TODO: Handle matchExpressions
 	}
-	return false
+	return false, nil
+}
+
+// ExportedServices returns Services matching any of the export rules sorted by namespace and name.
+// Every Service is returned once, even if it matches multiple rules.
+func ExportedServices(serviceLister v1.ServiceLister, namespaceLister v1.NamespaceLister, rules []config.Rules) ([]*corev1.Service, error) {
+	if len(rules) == 0 {
+		return nil, nil
+	}
+
+	services, err := serviceLister.List(labels.Everything())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list services: %w", err)
+	}
+
+	var exported []*corev1.Service
+	for _, svc := range services {
+		matched, errMatch := MatchExportRules(svc, namespaceLister, rules)
+		if errMatch != nil {
+			return nil, errMatch
+		}
+		if matched {
+			exported = append(exported, svc)
+		}
+	}
+	// ServiceLister.List is not idempotent, so services are sorted to generate resources in the same order.
+	sort.Slice(exported, func(i, j int) bool {
+		if exported[i].Namespace != exported[j].Namespace {
+			return exported[i].Namespace < exported[j].Namespace
+		}
+		return exported[i].Name < exported[j].Name
+	})
+
+	return exported, nil
 }
@@ -0,0 +1,107 @@
+// Copyright Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the License);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an AS IS BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/selection"
+)
+
+const (
+	// InstanceLabel identifies the federation controller instance, which generated the object. Its value is the name
+	// of the local mesh, so that controllers of different meshes in the same cluster do not prune each other's objects.
+	InstanceLabel = "federation.openshift-service-mesh.io/instance"
+	// ModeLabel identifies the mode of the federation controller, which generated the object, so that objects generated
+	// in the legacy mode, for a MeshFederation and for a FederatedService are not pruned by each other,
+	// even if the instance names are the same.
+	ModeLabel = "federation.openshift-service-mesh.io/mode"
+	// PeerLabel identifies the remote peer, for which the object was generated, e.g. the peer exporting the imported service.
+	PeerLabel = "federation.openshift-service-mesh.io/peer"
+	// SourceServiceAnnotation is the hostname under which the imported service is exported by the remote peer.
+	SourceServiceAnnotation = "federation.openshift-service-mesh.io/source-service"
+	// DiscoveredLabel marks FederatedServices created by the controller for services imported according to
+	// the importedServiceSet, so that all imported services are listed as FederatedServices.
+	DiscoveredLabel = "federation.openshift-service-mesh.io/discovered"
+	// PeersAnnotation lists remote peers, from which endpoints of an object merged from several peers are imported.
+	PeersAnnotation = "federation.openshift-service-mesh.io/peers"
+
+	// legacyPeer was the value of the peer label set on all generated objects before they were labeled with the owner.
+	legacyPeer = "todo"
+)
+
+// Mode is the mode of the federation controller generating objects.
+type Mode string
+
+const (
+	// LegacyMode generates objects from the configuration passed in program arguments.
+	LegacyMode Mode = "legacy"
+	// MeshFederationMode generates objects for a MeshFederation.
+	MeshFederationMode Mode = "mesh-federation"
+	// FederatedServiceMode generates objects for a FederatedService. These objects are controlled by the FederatedService.
+	FederatedServiceMode Mode = "federated-service"
+)
+
+// Owner identifies the federation instance generating objects.
+type Owner struct {
+	Mode Mode
+	// Instance is the name of the local mesh in the legacy mode or of the owning resource otherwise.
+	Instance string
+}
+
+// OwnerLabels returns labels set on all objects generated by the given owner.
+func OwnerLabels(owner Owner) map[string]string {
+	return map[string]string{InstanceLabel: owner.Instance, ModeLabel: string(owner.Mode)}
+}
+
+// ObjectName returns the name of an object generated by the given owner. Objects generated in the legacy mode keep
+// the given name, while names of objects generated by controllers are suffixed with the mode, so that the legacy mode
+// and controllers enabled side by side do not apply the same objects.
+func ObjectName(owner Owner, name string) string {
+	if owner.Mode == LegacyMode {
+		return name
+	}
+	return fmt.Sprintf("%s-%s", name, owner.Mode)
+}
+
+// ImportLabels returns labels set on objects generated by the given owner for a service imported from the peer.
+func ImportLabels(owner Owner, peer string) map[string]string {
+	return map[string]string{InstanceLabel: owner.Instance, ModeLabel: string(owner.Mode), PeerLabel: peer}
+}
+
+// ImportAnnotations returns annotations set on objects generated for the service imported under the given hostname.
+func ImportAnnotations(sourceHostname string) map[string]string {
+	return map[string]string{SourceServiceAnnotation: sourceHostname}
+}
+
+// MergedImportAnnotations returns annotations set on objects generated for the service imported from several peers.
+func MergedImportAnnotations(sourceHostname string, peers []string) map[string]string {
+	return map[string]string{SourceServiceAnnotation: sourceHostname, PeersAnnotation: strings.Join(peers, ",")}
+}
+
+// OwnerSelector matches objects generated by the given owner.
+func OwnerSelector(owner Owner) string {
+	return labels.SelectorFromSet(OwnerLabels(owner)).String()
+}
+
+// LegacyOwnerSelector matches objects generated by controllers, which did not label objects with their owner.
+// Such objects are adopted by the instance reconciling them first.
+func LegacyOwnerSelector() string {
+	peerRequirement, _ := labels.NewRequirement(PeerLabel, selection.Equals, []string{legacyPeer})
+	instanceRequirement, _ := labels.NewRequirement(InstanceLabel, selection.DoesNotExist, nil)
+	return labels.NewSelector().Add(*peerRequirement, *instanceRequirement).String()
+}
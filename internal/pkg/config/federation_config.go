@@ -14,10 +14,21 @@
 
 package config
 
-import "fmt"
+import (
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+
+	"github.com/openshift-service-mesh/federation/internal/pkg/networking"
+)
 
 const (
-	defaultGatewayPort = 15443
+	defaultGatewayPort         = 15443
+	defaultControllerNamespace = "istio-system"
 )
 
 type Federation struct {
@@ -41,14 +52,16 @@ type MeshPeers struct {
 	Remotes []Remote `json:"remotes"`
 }
 
-func (m MeshPeers) AnyRemotePeerWithOpenshiftRouterIngress() bool {
-	for _, remote := range m.Remotes {
-		if remote.IngressType == OpenShiftRouter {
-			return true
-		}
-	}
+// RemoteLister lists remote peers known to the controller.
+type RemoteLister interface {
+	Remotes() []Remote
+}
 
-	return false
+// StaticRemotes lists remote peers defined in the static configuration.
+type StaticRemotes []Remote
+
+func (r StaticRemotes) Remotes() []Remote {
+	return r
 }
 
 type Local struct {
@@ -64,6 +77,21 @@ type Remote struct {
 	IngressType IngressType `json:"ingressType"`
 	Port        *uint32     `json:"port,omitempty"`
 	Network     string      `json:"network"`
+	// TrustDomain of the remote mesh. Defaults to the local trust domain.
+	TrustDomain string `json:"trustDomain,omitempty"`
+	// SubjectAltNames of the remote FDS server certificate. If not set, any SPIFFE ID in the remote trust domain is accepted.
+	SubjectAltNames []string `json:"subjectAltNames,omitempty"`
+	// AllowedIdentities are SPIFFE IDs allowed to subscribe to exported services as this remote.
+	// If not set, only the federation controller of the remote mesh is allowed, see DefaultIdentity.
+	AllowedIdentities []string `json:"allowedIdentities,omitempty"`
+	// ControllerNamespace is the namespace of the federation controller in the remote mesh. Defaults to istio-system.
+	ControllerNamespace string `json:"controllerNamespace,omitempty"`
+	// Locality of the remote mesh in the form region/zone/subzone, e.g. us-east-1/us-east-1a.
+	// Endpoints of imported services are assigned this locality, so that the nearest remote mesh is preferred.
+	Locality string `json:"locality,omitempty"`
+	// Priority of the remote mesh in failover from local endpoints. Remote meshes with lower values are preferred,
+	// and meshes with the same priority are ordered by locality.
+	Priority uint32 `json:"priority,omitempty"`
 }
 
 func (r *Remote) ServiceName() string {
@@ -85,6 +113,50 @@ func (r *Remote) GetPort() uint32 {
 	return defaultGatewayPort
 }
 
+// DiscoverySNI returns SNI, which routes connections through the auto-passthrough ingress gateway of the remote peer
+// to its discovery service.
+func (r *Remote) DiscoverySNI() string {
+	if r.IngressType == OpenShiftRouter {
+		return fmt.Sprintf("%s-%d.istio-system.svc.cluster.local", r.ServiceName(), r.ServicePort())
+	}
+	return fmt.Sprintf("outbound_.%d_._.%s", r.ServicePort(), r.ServiceFQDN())
+}
+
+// GetTrustDomain returns the trust domain of the remote mesh or the given local trust domain if not set.
+func (r *Remote) GetTrustDomain(local string) string {
+	if r.TrustDomain != "" {
+		return r.TrustDomain
+	}
+	return local
+}
+
+// DefinesFailover returns true if the locality or priority of the remote mesh is set, which enables failover
+// from local endpoints to endpoints imported from remote meshes.
+func (r *Remote) DefinesFailover() bool {
+	return r.Locality != "" || r.Priority > 0
+}
+
+// GetControllerNamespace returns the namespace of the federation controller in the remote mesh.
+func (r *Remote) GetControllerNamespace() string {
+	if r.ControllerNamespace != "" {
+		return r.ControllerNamespace
+	}
+	return defaultControllerNamespace
+}
+
+// DefaultIdentity returns the SPIFFE ID of the federation controller service account in the remote mesh.
+func (r *Remote) DefaultIdentity(localTrustDomain string) string {
+	return fmt.Sprintf("spiffe://%s/ns/%s/sa/federation-controller", r.GetTrustDomain(localTrustDomain), r.GetControllerNamespace())
+}
+
+// AllowsIdentity returns true if the given SPIFFE ID is allowed to subscribe to exported services as this remote.
+func (r *Remote) AllowsIdentity(spiffeID, localTrustDomain string) bool {
+	if len(r.AllowedIdentities) > 0 {
+		return slices.Contains(r.AllowedIdentities, spiffeID)
+	}
+	return spiffeID == r.DefaultIdentity(localTrustDomain)
+}
+
 type ControlPlane struct {
 	Namespace string `json:"namespace"`
 }
@@ -112,31 +184,296 @@ type ExportedServiceSet struct {
 	Rules []Rules `json:"rules"`
 }
 
-func (s *ExportedServiceSet) GetLabelSelectors() []LabelSelectors {
-	if s == nil || len(s.Rules) == 0 {
-		return []LabelSelectors{}
+// SelectsNamespaceLabels returns true if any of the rules depends on namespace labels.
+func (s *ExportedServiceSet) SelectsNamespaceLabels() bool {
+	for _, rule := range s.Rules {
+		if rule.Type != LabelSelectorRule {
+			continue
+		}
+		for _, selectors := range rule.LabelSelectors {
+			if selectors.NamespaceSelector != nil {
+				return true
+			}
+		}
 	}
-	return s.Rules[0].LabelSelectors
+	return false
 }
 
+// Validate returns an error if any of the rules has unknown type or does not specify services to select.
+func (s *ExportedServiceSet) Validate() error {
+	for idx, rule := range s.Rules {
+		if err := rule.Validate(); err != nil {
+			return fmt.Errorf("invalid rule %d: %w", idx, err)
+		}
+	}
+	return nil
+}
+
+// RulesFor returns rules exporting services to the peer identified by any of the given names.
+func (s *ExportedServiceSet) RulesFor(names ...string) []Rules {
+	var rules []Rules
+	for _, rule := range s.Rules {
+		if rule.AppliesTo(names...) {
+			rules = append(rules, rule)
+		}
+	}
+	return rules
+}
+
+// ImportedServiceSet restricts services imported from remote peers. All services exported by remote peers
+// are imported if no rules are defined. Otherwise, a service is imported if it matches any of the rules.
 type ImportedServiceSet struct {
 	Rules []Rules `json:"rules"`
+	// ConflictPolicy defines how to import a service exported by several remote peers with different ports.
+	// Defaults to Reject.
+	ConflictPolicy ConflictPolicy `json:"conflictPolicy,omitempty"`
+	// Aliases expose imported services under local hostnames instead of hostnames exported by remote peers.
+	// The first matching alias is applied.
+	Aliases []Alias `json:"aliases,omitempty"`
+}
+
+// LocalHostname returns the hostname, under which the service exported by the peer is imported.
+func (s *ImportedServiceSet) LocalHostname(peer, hostname string) string {
+	for _, alias := range s.Aliases {
+		if localHostname, matched := alias.Apply(peer, hostname); matched {
+			return localHostname
+		}
+	}
+	return hostname
 }
 
+// Alias defines the local hostname of imported services.
+type Alias struct {
+	// Hostnames are patterns of hostnames exported by remote peers, e.g. *.payments.svc.cluster.local.
+	// Services with any hostname are matched if not set.
+	Hostnames []string `json:"hostnames,omitempty"`
+	// Peers restricts the alias to services exported by the given peers.
+	Peers []string `json:"peers,omitempty"`
+	// Hostname is the template of the local hostname, in which {name} and {namespace} are replaced with the name
+	// and namespace of the service in the remote mesh, and {peer} is replaced with the name of the peer,
+	// e.g. {name}.{peer}.global or {name}.remote-{peer}.svc.cluster.local.
+	Hostname string `json:"hostname"`
+}
+
+// Apply returns the local hostname of the service exported by the peer and true, if the alias matches the service.
+// Templates with the service name or namespace match only hostnames of Kubernetes services.
+func (a *Alias) Apply(peer, hostname string) (string, bool) {
+	if len(a.Peers) > 0 && !slices.Contains(a.Peers, peer) {
+		return "", false
+	}
+	if len(a.Hostnames) > 0 && !(&Rules{Hostnames: a.Hostnames}).MatchHostname(hostname) {
+		return "", false
+	}
+	name, namespace, ok := networking.ParseServiceHostname(hostname)
+	if !ok && (strings.Contains(a.Hostname, "{name}") || strings.Contains(a.Hostname, "{namespace}")) {
+		return "", false
+	}
+	return strings.NewReplacer("{name}", name, "{namespace}", namespace, "{peer}", peer).Replace(a.Hostname), true
+}
+
+// GetConflictPolicy returns the conflict policy or the default policy if not set.
+func (s *ImportedServiceSet) GetConflictPolicy() ConflictPolicy {
+	if s.ConflictPolicy == "" {
+		return RejectConflicts
+	}
+	return s.ConflictPolicy
+}
+
+// Validate returns an error if any of the rules cannot be applied to services received from remote peers.
+// Imported services are identified only by hostname and labels, so the rules cannot select namespaces.
+func (s *ImportedServiceSet) Validate() error {
+	switch s.ConflictPolicy {
+	case "", RejectConflicts, FirstWins, Union:
+	default:
+		return fmt.Errorf("unknown conflict policy %q", s.ConflictPolicy)
+	}
+	for idx, alias := range s.Aliases {
+		if alias.Hostname == "" {
+			return fmt.Errorf("invalid alias %d: hostname must not be empty", idx)
+		}
+		for _, pattern := range alias.Hostnames {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return fmt.Errorf("invalid alias %d: invalid hostname pattern %q: %w", idx, pattern, err)
+			}
+		}
+	}
+	for idx, rule := range s.Rules {
+		if err := rule.Validate(); err != nil {
+			return fmt.Errorf("invalid rule %d: %w", idx, err)
+		}
+		switch rule.Type {
+		case LabelSelectorRule:
+			for _, selectors := range rule.LabelSelectors {
+				if selectors.NamespaceSelector != nil {
+					return fmt.Errorf("invalid rule %d: namespaceSelector is not supported for imported services", idx)
+				}
+			}
+		case HostnameRule:
+		default:
+			return fmt.Errorf("invalid rule %d: rule of type %s is not supported for imported services", idx, rule.Type)
+		}
+	}
+	return nil
+}
+
+// ConflictPolicy defines how a service exported by several remote peers under the same hostname is imported,
+// when the peers do not export the same ports. Peers are ordered by name.
+type ConflictPolicy string
+
+const (
+	// RejectConflicts does not import the service until all peers export the same ports.
+	RejectConflicts ConflictPolicy = "Reject"
+	// FirstWins imports ports exported by the first peer and endpoints of peers exporting the same ports.
+	FirstWins ConflictPolicy = "FirstWins"
+	// Union imports ports exported by any of the peers and endpoints of all peers. If peers export the same port number
+	// with different names, the port is defined by the first peer, but the service is not imported until they export it
+	// with the same protocol. Requests to any port are balanced across endpoints of all peers, including peers,
+	// which do not export that port, so requests routed to them fail. Union is meant for peers exporting different
+	// ports only temporarily, e.g. during a rollout of a new port.
+	Union ConflictPolicy = "Union"
+)
+
+type RuleType string
+
+const (
+	// LabelSelectorRule selects services by labels of services and optionally their namespaces.
+	LabelSelectorRule RuleType = "LabelSelector"
+	// ServiceNameRule selects services by namespace and name.
+	ServiceNameRule RuleType = "ServiceName"
+	// NamespaceRule selects all services from the given namespaces.
+	NamespaceRule RuleType = "Namespace"
+	// HostnameRule selects services by hostname patterns, e.g. *.bookinfo.svc.cluster.local.
+	HostnameRule RuleType = "Hostname"
+)
+
+// Rules selects services using the criteria specific to the rule type.
+// Only the field matching the type is taken into account.
 type Rules struct {
-	Type           string           `json:"type"`
-	LabelSelectors []LabelSelectors `json:"labelSelectors"`
+	Type           RuleType         `json:"type"`
+	LabelSelectors []LabelSelectors `json:"labelSelectors,omitempty"`
+	ServiceNames   []ServiceName    `json:"serviceNames,omitempty"`
+	Namespaces     []string         `json:"namespaces,omitempty"`
+	Hostnames      []string         `json:"hostnames,omitempty"`
+	// Peers restricts the rule to the given remote peers: in export rules, services are exported only to these peers,
+	// and in import rules, only services received from these peers are matched. Peers are identified by name
+	// or SPIFFE identity, e.g. spiffe://west.local/ns/istio-system/sa/federation-controller.
+	// The rule applies to all peers if not set.
+	Peers []string `json:"peers,omitempty"`
+}
+
+// AppliesTo returns true if the rule applies to the peer identified by any of the given names.
+func (r *Rules) AppliesTo(names ...string) bool {
+	if len(r.Peers) == 0 {
+		return true
+	}
+	for _, name := range names {
+		if slices.Contains(r.Peers, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchHostname returns true if the hostname matches any of the hostname patterns.
+func (r *Rules) MatchHostname(hostname string) bool {
+	for _, pattern := range r.Hostnames {
+		if matched, _ := path.Match(pattern, hostname); matched {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Rules) Validate() error {
+	switch r.Type {
+	case LabelSelectorRule:
+		if len(r.LabelSelectors) == 0 {
+			return fmt.Errorf("rule of type %s requires labelSelectors", r.Type)
+		}
+		for _, selectors := range r.LabelSelectors {
+			if _, err := selectors.Selector(); err != nil {
+				return fmt.Errorf("invalid label selector: %w", err)
+			}
+			if selectors.NamespaceSelector != nil {
+				if _, err := selectors.NamespaceSelector.Selector(); err != nil {
+					return fmt.Errorf("invalid namespace selector: %w", err)
+				}
+			}
+		}
+	case ServiceNameRule:
+		if len(r.ServiceNames) == 0 {
+			return fmt.Errorf("rule of type %s requires serviceNames", r.Type)
+		}
+		for _, name := range r.ServiceNames {
+			if name.Name == "" || name.Namespace == "" {
+				return fmt.Errorf("service name and namespace must not be empty")
+			}
+		}
+	case NamespaceRule:
+		if len(r.Namespaces) == 0 {
+			return fmt.Errorf("rule of type %s requires namespaces", r.Type)
+		}
+	case HostnameRule:
+		if len(r.Hostnames) == 0 {
+			return fmt.Errorf("rule of type %s requires hostnames", r.Type)
+		}
+		for _, pattern := range r.Hostnames {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return fmt.Errorf("invalid hostname pattern %q: %w", pattern, err)
+			}
+		}
+	default:
+		return fmt.Errorf("unknown rule type %q", r.Type)
+	}
+	return nil
+}
+
+type ServiceName struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
 }
 
 type LabelSelectors struct {
 	MatchLabels      map[string]string  `json:"matchLabels,omitempty"`
 	MatchExpressions []MatchExpressions `json:"matchExpressions,omitempty"`
+	// NamespaceSelector restricts matched services to namespaces with matching labels.
+	// Services from all namespaces are matched if it is not set.
+	NamespaceSelector *NamespaceSelector `json:"namespaceSelector,omitempty"`
+}
+
+// Selector returns a selector matching service labels.
+func (s LabelSelectors) Selector() (labels.Selector, error) {
+	return asSelector(s.MatchLabels, s.MatchExpressions)
+}
+
+type NamespaceSelector struct {
+	MatchLabels      map[string]string  `json:"matchLabels,omitempty"`
+	MatchExpressions []MatchExpressions `json:"matchExpressions,omitempty"`
+}
+
+// Selector returns a selector matching namespace labels.
+func (s NamespaceSelector) Selector() (labels.Selector, error) {
+	return asSelector(s.MatchLabels, s.MatchExpressions)
+}
+
+func asSelector(matchLabels map[string]string, matchExpressions []MatchExpressions) (labels.Selector, error) {
+	selector := &metav1.LabelSelector{
+		MatchLabels: matchLabels,
+	}
+	for _, expr := range matchExpressions {
+		selector.MatchExpressions = append(selector.MatchExpressions, metav1.LabelSelectorRequirement{
+			Key:      expr.Key,
+			Operator: metav1.LabelSelectorOperator(expr.Operator),
+			Values:   expr.Values,
+		})
+	}
+	return metav1.LabelSelectorAsSelector(selector)
 }
 
 type MatchExpressions struct {
 	Key      string   `json:"key"`
 	Operator string   `json:"operator"`
-	Values   []string `json:"values"`
+	Values   []string `json:"values,omitempty"`
 }
 
 type IngressType string
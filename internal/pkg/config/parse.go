@@ -34,10 +34,16 @@ func ParseArgs(meshPeers, exportedServiceSet, importedServiceSet string) (*Feder
 	if err := unmarshalJSON(exportedServiceSet, &exported); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal exported services: %w", err)
 	}
+	if err := exported.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid exported services: %w", err)
+	}
 	if importedServiceSet != "" {
 		if err := unmarshalJSON(importedServiceSet, &imported); err != nil {
 			return nil, fmt.Errorf("failed to unmarshal imported services: %w", err)
 		}
+		if err := imported.Validate(); err != nil {
+			return nil, fmt.Errorf("invalid imported services: %w", err)
+		}
 	}
 
 	return &Federation{
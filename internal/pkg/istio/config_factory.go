@@ -21,17 +21,20 @@ import (
 
 	"google.golang.org/protobuf/types/known/structpb"
 	istionetv1alpha3 "istio.io/api/networking/v1alpha3"
+	securityv1beta1 "istio.io/api/security/v1beta1"
+	typev1beta1 "istio.io/api/type/v1beta1"
 	"istio.io/client-go/pkg/apis/networking/v1alpha3"
+	"istio.io/client-go/pkg/apis/security/v1beta1"
 	istiolog "istio.io/istio/pkg/log"
 	"istio.io/istio/pkg/maps"
 	"istio.io/istio/pkg/slices"
 	"istio.io/istio/pkg/util/protomarshal"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/labels"
 	v1 "k8s.io/client-go/listers/core/v1"
 
 	"github.com/openshift-service-mesh/federation/internal/api/federation/v1alpha1"
+	"github.com/openshift-service-mesh/federation/internal/pkg/common"
 	"github.com/openshift-service-mesh/federation/internal/pkg/config"
 	"github.com/openshift-service-mesh/federation/internal/pkg/legacy/fds"
 	"github.com/openshift-service-mesh/federation/internal/pkg/networking"
@@ -43,7 +46,10 @@ const (
 
 type ConfigFactory struct {
 	cfg                  config.Federation
+	mode                 common.Mode
+	remotes              config.RemoteLister
 	serviceLister        v1.ServiceLister
+	namespaceLister      v1.NamespaceLister
 	importedServiceStore *fds.ImportedServiceStore
 	namespace            string
 	log                  *istiolog.Scope
@@ -51,41 +57,54 @@ type ConfigFactory struct {
 
 func NewConfigFactory(
 	cfg config.Federation,
+	mode common.Mode,
+	remotes config.RemoteLister,
 	serviceLister v1.ServiceLister,
+	namespaceLister v1.NamespaceLister,
 	importedServiceStore *fds.ImportedServiceStore,
 	namespace string,
 ) *ConfigFactory {
 	return &ConfigFactory{
 		cfg:                  cfg,
+		mode:                 mode,
+		remotes:              remotes,
 		serviceLister:        serviceLister,
+		namespaceLister:      namespaceLister,
 		importedServiceStore: importedServiceStore,
 		namespace:            namespace,
 		log:                  istiolog.RegisterScope("istio-cfg-factory", "Istio Resources Config Factory").WithLabels("namespace", namespace),
 	}
 }
 
+// Owner returns the federation instance, which owns generated objects.
+func (cf *ConfigFactory) Owner() common.Owner {
+	return common.Owner{Mode: cf.mode, Instance: cf.cfg.MeshPeers.Local.Name}
+}
+
 // DestinationRules customize SNI in the client mTLS connection when the remote ingress is openshift-router,
 // because that ingress requires hosts compatible with https://datatracker.ietf.org/doc/html/rfc952.
+// They also customize SNI for services imported under a local alias, because remote gateways route requests
+// by SNI derived from the exported hostname.
+// A service exported by several peers gets a single DestinationRule for all ports exported by these peers.
+// If any of these peers defines locality or priority, the DestinationRule also configures failover from local endpoints.
 func (cf *ConfigFactory) DestinationRules() []*v1alpha3.DestinationRule {
 	var destinationRules []*v1alpha3.DestinationRule
-	destinationRulesAlreadyCreated := make(map[string]bool, len(cf.cfg.MeshPeers.Remotes))
+	createObjectMeta := func(hostname string) metav1.ObjectMeta {
+		return metav1.ObjectMeta{
+			Name:      common.ObjectName(cf.Owner(), fmt.Sprintf("mtls-sni-%s", separateWithDash(hostname))),
+			Namespace: cf.cfg.MeshPeers.Local.ControlPlane.Namespace,
+		}
+	}
 
-	for _, remote := range cf.cfg.MeshPeers.Remotes {
+	for _, remote := range cf.remotes.Remotes() {
 		if remote.IngressType != config.OpenShiftRouter {
 			// Skipping peers which are not using openshift-router
 			continue
 		}
-
-		createObjectMeta := func(hostname string) metav1.ObjectMeta {
-			return metav1.ObjectMeta{
-				Name:      fmt.Sprintf("mtls-sni-%s", separateWithDash(hostname)),
-				Namespace: cf.cfg.MeshPeers.Local.ControlPlane.Namespace,
-				Labels:    map[string]string{"federation.openshift-service-mesh.io/peer": "todo"},
-			}
-		}
-
+		drMeta := createObjectMeta(fmt.Sprintf("%s.%s.svc.cluster.local", remote.ServiceName(), "istio-system"))
+		drMeta.Labels = common.ImportLabels(cf.Owner(), remote.Name)
 		destinationRules = append(destinationRules, &v1alpha3.DestinationRule{
-			ObjectMeta: createObjectMeta(fmt.Sprintf("%s.%s.svc.cluster.local", remote.ServiceName(), "istio-system")),
+			ObjectMeta: drMeta,
 			Spec: istionetv1alpha3.DestinationRule{
 				Host: remote.ServiceFQDN(),
 				TrafficPolicy: &istionetv1alpha3.TrafficPolicy{
@@ -96,47 +115,120 @@ func (cf *ConfigFactory) DestinationRules() []*v1alpha3.DestinationRule {
 				},
 			},
 		})
+	}
 
-		for _, svc := range cf.importedServiceStore.From(remote) {
-			// Currently it's assumed that the same service (name+ns) exported by multiple remotes
-			// is configured exactly the same, therefore we create DestinationRule only once.
-			drMeta := createObjectMeta(svc.GetHostname())
-			if !destinationRulesAlreadyCreated[drMeta.Name] {
-				dr := &v1alpha3.DestinationRule{
-					ObjectMeta: drMeta,
-					Spec: istionetv1alpha3.DestinationRule{
-						Host: svc.GetHostname(),
-						TrafficPolicy: &istionetv1alpha3.TrafficPolicy{
-							PortLevelSettings: []*istionetv1alpha3.TrafficPolicy_PortTrafficPolicy{},
-						},
-					},
-				}
-				for _, port := range svc.Ports {
-					svcName, svcNs := getServiceNameAndNs(svc.GetHostname())
-					dr.Spec.TrafficPolicy.PortLevelSettings = append(dr.Spec.TrafficPolicy.PortLevelSettings, &istionetv1alpha3.TrafficPolicy_PortTrafficPolicy{
-						Port: &istionetv1alpha3.PortSelector{Number: port.Number},
-						Tls: &istionetv1alpha3.ClientTLSSettings{
-							Mode: istionetv1alpha3.ClientTLSSettings_ISTIO_MUTUAL,
-							Sni:  routerCompatibleSNI(svcName, svcNs, port.Number),
-						},
-					})
+	hostnames, imports := cf.importsByHostname()
+	for _, hostname := range hostnames {
+		routerImports := slices.Filter(imports[hostname], func(imp peerImport) bool {
+			return imp.remote.IngressType == config.OpenShiftRouter
+		})
+		failover := definesFailover(imports[hostname])
+		remoteHostname, err := sourceHostname(imports[hostname])
+		if err != nil {
+			cf.log.Errorf("skipping import of %s: %v", hostname, err)
+			continue
+		}
+		aliased := hostname != remoteHostname
+		if len(routerImports) == 0 && !aliased && !failover {
+			continue
+		}
+		sniRemote := imports[hostname][0].remote
+		if len(routerImports) > 0 {
+			sniRemote = routerImports[0].remote
+			if len(routerImports) < len(imports[hostname]) {
+				// Port-level TLS settings apply to endpoints of all peers.
+				cf.log.Warnf("%s is exported by peers with different ingress types; SNI compatible with openshift-router is used for all peers", hostname)
+			}
+		}
+
+		peers := slices.Map(imports[hostname], func(imp peerImport) string {
+			return imp.remote.Name
+		})
+		drMeta := createObjectMeta(hostname)
+		drMeta.Labels = common.OwnerLabels(cf.Owner())
+		drMeta.Annotations = common.MergedImportAnnotations(remoteHostname, peers)
+		dr := &v1alpha3.DestinationRule{
+			ObjectMeta: drMeta,
+			Spec: istionetv1alpha3.DestinationRule{
+				Host: hostname,
+				TrafficPolicy: &istionetv1alpha3.TrafficPolicy{
+					PortLevelSettings: []*istionetv1alpha3.TrafficPolicy_PortTrafficPolicy{},
+				},
+			},
+		}
+		// SNI depends only on the port number, so ports exported by any of the peers are configured.
+		// Without an alias, SNI set by the client already matches gateways other than openshift-router.
+		sniImports := routerImports
+		if aliased {
+			sniImports = imports[hostname]
+		}
+		configured := make(map[uint32]bool)
+		for _, imp := range sniImports {
+			for _, port := range imp.svc.Ports {
+				if configured[port.Number] {
+					continue
 				}
-				destinationRules = append(destinationRules, dr)
-			} else {
-				cf.log.Warnf("Destination rule %s already created (requesting peer %v)", drMeta.Name, remote)
+				configured[port.Number] = true
+				dr.Spec.TrafficPolicy.PortLevelSettings = append(dr.Spec.TrafficPolicy.PortLevelSettings, &istionetv1alpha3.TrafficPolicy_PortTrafficPolicy{
+					Port: &istionetv1alpha3.PortSelector{Number: port.Number},
+					Tls: &istionetv1alpha3.ClientTLSSettings{
+						Mode: istionetv1alpha3.ClientTLSSettings_ISTIO_MUTUAL,
+						Sni:  remoteSNI(sniRemote, remoteHostname, port.Number),
+					},
+				})
 			}
 		}
+		if failover {
+			setFailover(dr.Spec.TrafficPolicy, slices.Map(imports[hostname], func(imp peerImport) config.Remote {
+				return imp.remote
+			}))
+		}
+		destinationRules = append(destinationRules, dr)
 	}
 
 	return destinationRules
 }
 
+// ImportedServiceDestinationRule customizes SNI in the client mTLS connection for a service imported from the given remote,
+// so that the remote ingress gateway can route requests to the service exposed under its original hostname.
+// It is required when the service is imported under a local alias or when the remote ingress is openshift-router.
+// If the remote defines locality or priority, the DestinationRule also configures failover from local endpoints.
+func (cf *ConfigFactory) ImportedServiceDestinationRule(remote config.Remote, remoteHostname string, importedSvc *v1alpha1.FederatedService) *v1alpha3.DestinationRule {
+	dr := &v1alpha3.DestinationRule{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        common.ObjectName(cf.Owner(), fmt.Sprintf("mtls-sni-%s", separateWithDash(importedSvc.GetHostname()))),
+			Namespace:   cf.cfg.MeshPeers.Local.ControlPlane.Namespace,
+			Labels:      common.ImportLabels(cf.Owner(), remote.Name),
+			Annotations: common.ImportAnnotations(remoteHostname),
+		},
+		Spec: istionetv1alpha3.DestinationRule{
+			Host: importedSvc.GetHostname(),
+			TrafficPolicy: &istionetv1alpha3.TrafficPolicy{
+				PortLevelSettings: []*istionetv1alpha3.TrafficPolicy_PortTrafficPolicy{},
+			},
+		},
+	}
+	for _, port := range importedSvc.Ports {
+		dr.Spec.TrafficPolicy.PortLevelSettings = append(dr.Spec.TrafficPolicy.PortLevelSettings, &istionetv1alpha3.TrafficPolicy_PortTrafficPolicy{
+			Port: &istionetv1alpha3.PortSelector{Number: port.Number},
+			Tls: &istionetv1alpha3.ClientTLSSettings{
+				Mode: istionetv1alpha3.ClientTLSSettings_ISTIO_MUTUAL,
+				Sni:  remoteSNI(remote, remoteHostname, port.Number),
+			},
+		})
+	}
+	if remote.DefinesFailover() {
+		setFailover(dr.Spec.TrafficPolicy, []config.Remote{remote})
+	}
+	return dr
+}
+
 func (cf *ConfigFactory) IngressGateway() (*v1alpha3.Gateway, error) {
 	gateway := &v1alpha3.Gateway{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      federationIngressGatewayName,
+			Name:      common.ObjectName(cf.Owner(), federationIngressGatewayName),
 			Namespace: cf.cfg.MeshPeers.Local.ControlPlane.Namespace,
-			Labels:    map[string]string{"federation.openshift-service-mesh.io/peer": "todo"},
+			Labels:    common.OwnerLabels(cf.Owner()),
 		},
 		Spec: istionetv1alpha3.Gateway{
 			Selector: cf.cfg.MeshPeers.Local.Gateways.Ingress.Selector,
@@ -155,18 +247,14 @@ func (cf *ConfigFactory) IngressGateway() (*v1alpha3.Gateway, error) {
 	}
 
 	hosts := []string{fmt.Sprintf("federation-discovery-service-%s.%s.svc.cluster.local", cf.cfg.MeshPeers.Local.Name, cf.namespace)}
-	for _, exportLabelSelector := range cf.cfg.ExportedServiceSet.GetLabelSelectors() {
-		matchLabels := labels.SelectorFromSet(exportLabelSelector.MatchLabels)
-		services, err := cf.serviceLister.List(matchLabels)
-		if err != nil {
-			return nil, fmt.Errorf("error listing services (selector=%s): %w", matchLabels, err)
-		}
-		for _, svc := range services {
-			hosts = append(hosts, fmt.Sprintf("%s.%s.svc.cluster.local", svc.Name, svc.Namespace))
-		}
+	services, err := common.ExportedServices(cf.serviceLister, cf.namespaceLister, cf.cfg.ExportedServiceSet.Rules)
+	if err != nil {
+		return nil, err
+	}
+	for _, svc := range services {
+		hosts = append(hosts, fmt.Sprintf("%s.%s.svc.cluster.local", svc.Name, svc.Namespace))
 	}
-	// ServiceLister.List is not idempotent, so to avoid redundant XDS push from Istio to proxies,
-	// we must return hostnames in the same order.
+	// To avoid redundant XDS push from Istio to proxies, we must return hostnames in the same order.
 	sort.Strings(hosts)
 	gateway.Spec.Servers[0].Hosts = hosts
 
@@ -191,9 +279,9 @@ func (cf *ConfigFactory) EnvoyFilters() []*v1alpha3.EnvoyFilter {
 		}
 		return &v1alpha3.EnvoyFilter{
 			ObjectMeta: metav1.ObjectMeta{
-				Name:      fmt.Sprintf("sni-%s-%s-%d", svcName, svcNamespace, port),
+				Name:      common.ObjectName(cf.Owner(), fmt.Sprintf("sni-%s-%s-%d", svcName, svcNamespace, port)),
 				Namespace: cf.cfg.MeshPeers.Local.ControlPlane.Namespace,
-				Labels:    map[string]string{"federation.openshift-service-mesh.io/peer": "todo"},
+				Labels:    common.OwnerLabels(cf.Owner()),
 			},
 			Spec: istionetv1alpha3.EnvoyFilter{
 				WorkloadSelector: &istionetv1alpha3.WorkloadSelector{
@@ -223,143 +311,219 @@ func (cf *ConfigFactory) EnvoyFilters() []*v1alpha3.EnvoyFilter {
 	envoyFilters := []*v1alpha3.EnvoyFilter{
 		createEnvoyFilter(fmt.Sprintf("federation-discovery-service-%s", cf.cfg.MeshPeers.Local.Name), "istio-system", 15080),
 	}
-	for _, exportLabelSelector := range cf.cfg.ExportedServiceSet.GetLabelSelectors() {
-		matchLabels := labels.SelectorFromSet(exportLabelSelector.MatchLabels)
-		services, err := cf.serviceLister.List(matchLabels)
-		if err != nil {
-			fmt.Printf("error listing services (selector=%s): %v", matchLabels, err)
-		}
-		for _, svc := range services {
-			for _, port := range svc.Spec.Ports {
-				envoyFilters = append(envoyFilters, createEnvoyFilter(svc.Name, svc.Namespace, port.Port))
-			}
+	services, err := common.ExportedServices(cf.serviceLister, cf.namespaceLister, cf.cfg.ExportedServiceSet.Rules)
+	if err != nil {
+		cf.log.Errorf("error listing exported services: %v", err)
+	}
+	for _, svc := range services {
+		for _, port := range svc.Spec.Ports {
+			envoyFilters = append(envoyFilters, createEnvoyFilter(svc.Name, svc.Namespace, port.Port))
 		}
 	}
 	return envoyFilters
 }
 
+// ServiceEntries returns ServiceEntries for discovery services of remote peers and for imported services,
+// which do not exist locally. A service exported by several peers is imported as a single ServiceEntry
+// with endpoints of all peers. Services are imported under their local hostnames, if they match an alias.
 func (cf *ConfigFactory) ServiceEntries() ([]*v1alpha3.ServiceEntry, error) {
-
 	var serviceEntries []*v1alpha3.ServiceEntry
-	serviceEntriesByName := make(map[string]*v1alpha3.ServiceEntry, len(cf.cfg.MeshPeers.Remotes))
-
-	for _, remote := range cf.cfg.MeshPeers.Remotes {
+	for _, remote := range cf.remotes.Remotes() {
 		if len(remote.Addresses) == 0 {
 			continue
 		}
-
 		serviceEntries = append(serviceEntries, cf.serviceEntryForRemoteFederationController(remote))
+	}
 
-		var resolution istionetv1alpha3.ServiceEntry_Resolution
-		if networking.IsIP(remote.Addresses[0]) {
-			resolution = istionetv1alpha3.ServiceEntry_STATIC
-		} else {
-			resolution = istionetv1alpha3.ServiceEntry_DNS
+	hostnames, imports := cf.importsByHostname()
+	for _, hostname := range hostnames {
+		reachable := slices.Filter(imports[hostname], func(imp peerImport) bool {
+			return len(imp.remote.Addresses) > 0
+		})
+		if len(reachable) == 0 {
+			continue
+		}
+		remoteHostname, err := sourceHostname(reachable)
+		if err != nil {
+			cf.log.Errorf("skipping import of %s: %v", hostname, err)
+			continue
+		}
+		if exists, err := cf.existsLocally(hostname); err != nil {
+			return nil, err
+		} else if exists {
+			continue
 		}
 
-		for _, importedSvc := range cf.importedServiceStore.From(remote) {
-			svcName, svcNs := getServiceNameAndNs(importedSvc.GetHostname())
-			_, err := cf.serviceLister.Services(svcNs).Get(svcName)
-			if err != nil {
-				if !errors.IsNotFound(err) {
-					return nil, fmt.Errorf("failed to get Service %s/%s: %w", svcNs, svcName, err)
-				}
-				// Service doesn't exist - create ServiceEntry.
-
-				// TODO(multi-peer) handle naming clash & different resolution strategy
-				// https://github.com/openshift-service-mesh/federation/issues/123
-				var ports []*istionetv1alpha3.ServicePort
-				for _, port := range importedSvc.Ports {
-					ports = append(ports, &istionetv1alpha3.ServicePort{
-						Name:       port.Name,
-						Number:     port.Number,
-						Protocol:   port.Protocol,
-						TargetPort: port.TargetPort,
-					})
-				}
+		ports, included, err := mergePorts(cf.cfg.ImportedServiceSet.GetConflictPolicy(), reachable)
+		if err != nil {
+			cf.log.Errorf("skipping import of %s: %v", hostname, err)
+			continue
+		}
+		peers := slices.Map(included, func(imp peerImport) string {
+			return imp.remote.Name
+		})
+		serviceEntries = append(serviceEntries, importedServiceEntry(metav1.ObjectMeta{
+			Name:        common.ObjectName(cf.Owner(), fmt.Sprintf("import-%s", separateWithDash(hostname))),
+			Namespace:   cf.cfg.MeshPeers.Local.ControlPlane.Namespace,
+			Labels:      common.OwnerLabels(cf.Owner()),
+			Annotations: common.MergedImportAnnotations(remoteHostname, peers),
+		}, hostname, ports, included, definesFailover(reachable)))
+	}
 
-				endpoints := slices.Map(remote.Addresses, func(addr string) *istionetv1alpha3.WorkloadEntry {
-					return &istionetv1alpha3.WorkloadEntry{
-						Address: addr,
-						Labels:  maps.MergeCopy(importedSvc.Labels, map[string]string{"security.istio.io/tlsMode": "istio"}),
-						Ports:   makePortsMap(importedSvc.Ports, remote.GetPort()),
-						Network: remote.Network,
-					}
-				})
+	return serviceEntries, nil
+}
 
-				svcEntryName := fmt.Sprintf("import-%s-%s", separateWithDash(importedSvc.GetHostname()), remote.Name)
-				serviceEntry, exists := serviceEntriesByName[svcEntryName]
-				if exists {
-					// If the ServiceEntry already exists due to multiple remotes exporting the same service,
-					// append endpoints to ensure all remotes are reachable under the shared host.
-					serviceEntry.Spec.Endpoints = append(serviceEntry.Spec.Endpoints, endpoints...)
-				} else {
-					serviceEntry = &v1alpha3.ServiceEntry{
-						ObjectMeta: metav1.ObjectMeta{
-							Name:      svcEntryName,
-							Namespace: cf.cfg.MeshPeers.Local.ControlPlane.Namespace,
-							Labels:    map[string]string{"federation.openshift-service-mesh.io/peer": "todo"},
-						},
-						Spec: istionetv1alpha3.ServiceEntry{
-							Hosts:      []string{importedSvc.GetHostname()},
-							Ports:      ports,
-							Endpoints:  endpoints,
-							Location:   istionetv1alpha3.ServiceEntry_MESH_INTERNAL,
-							Resolution: resolution,
-						},
-					}
-				}
+// ImportedServiceEntry returns ServiceEntry for a service imported from the given remote peer.
+// It returns nil if the service exists locally, because then it must be imported as WorkloadEntries.
+func (cf *ConfigFactory) ImportedServiceEntry(remote config.Remote, importedSvc *v1alpha1.FederatedService) (*v1alpha3.ServiceEntry, error) {
+	if len(remote.Addresses) == 0 {
+		return nil, nil
+	}
+	if exists, err := cf.existsLocally(importedSvc.GetHostname()); err != nil || exists {
+		return nil, err
+	}
+
+	return importedServiceEntry(metav1.ObjectMeta{
+		Name:        common.ObjectName(cf.Owner(), fmt.Sprintf("import-%s-%s", separateWithDash(importedSvc.GetHostname()), remote.Name)),
+		Namespace:   cf.cfg.MeshPeers.Local.ControlPlane.Namespace,
+		Labels:      common.ImportLabels(cf.Owner(), remote.Name),
+		Annotations: common.ImportAnnotations(importedSvc.GetHostname()),
+	}, importedSvc.GetHostname(), importedSvc.Ports, []peerImport{{remote: remote, svc: importedSvc}}, remote.DefinesFailover()), nil
+}
 
-				serviceEntriesByName[svcEntryName] = serviceEntry
+// importedServiceEntry returns ServiceEntry with given ports and endpoints of all given imports.
+// Resolution is STATIC only if all remote addresses are IPs, because DNS resolution also accepts IP addresses.
+// If failover is enabled, endpoints are labeled with the failover priority of their remote.
+func importedServiceEntry(objectMeta metav1.ObjectMeta, hostname string, ports []*v1alpha1.ServicePort, imports []peerImport, failover bool) *v1alpha3.ServiceEntry {
+	se := &v1alpha3.ServiceEntry{
+		ObjectMeta: objectMeta,
+		Spec: istionetv1alpha3.ServiceEntry{
+			Hosts:      []string{hostname},
+			Location:   istionetv1alpha3.ServiceEntry_MESH_INTERNAL,
+			Resolution: istionetv1alpha3.ServiceEntry_STATIC,
+		},
+	}
+	for _, port := range ports {
+		se.Spec.Ports = append(se.Spec.Ports, &istionetv1alpha3.ServicePort{
+			Name:       port.Name,
+			Number:     port.Number,
+			Protocol:   port.Protocol,
+			TargetPort: port.TargetPort,
+		})
+	}
+	for _, imp := range imports {
+		for _, addr := range imp.remote.Addresses {
+			if !networking.IsIP(addr) {
+				se.Spec.Resolution = istionetv1alpha3.ServiceEntry_DNS
 			}
+			se.Spec.Endpoints = append(se.Spec.Endpoints, &istionetv1alpha3.WorkloadEntry{
+				Address:  addr,
+				Labels:   endpointLabels(imp.svc.Labels, imp.remote, failover),
+				Ports:    makePortsMap(ports, imp.remote.GetPort()),
+				Network:  imp.remote.Network,
+				Locality: imp.remote.Locality,
+			})
 		}
 	}
-
-	serviceEntries = append(serviceEntries, maps.Values(serviceEntriesByName)...)
-
-	return serviceEntries, nil
+	return se
 }
 
 func (cf *ConfigFactory) WorkloadEntries() ([]*v1alpha3.WorkloadEntry, error) {
 	var workloadEntries []*v1alpha3.WorkloadEntry
 
-	for _, remote := range cf.cfg.MeshPeers.Remotes {
-		for _, importedSvc := range cf.importedServiceStore.From(remote) {
-			svcName, svcNs := getServiceNameAndNs(importedSvc.GetHostname())
-			_, err := cf.serviceLister.Services(svcNs).Get(svcName)
+	hostnames, imports := cf.importsByHostname()
+	for _, hostname := range hostnames {
+		failover := definesFailover(imports[hostname])
+		for _, imp := range imports[hostname] {
+			importedWorkloadEntries, err := cf.importedWorkloadEntries(imp.remote, imp.hostname, imp.svc, failover)
 			if err != nil {
-				if !errors.IsNotFound(err) {
-					return nil, fmt.Errorf("failed to get Service %s/%s: %w", svcNs, svcName, err)
-				}
-			} else {
-				// Service already exists - create WorkloadEntries.
-				for idx, ip := range networking.Resolve(remote.Addresses...) {
-					workloadEntries = append(workloadEntries, &v1alpha3.WorkloadEntry{
-						ObjectMeta: metav1.ObjectMeta{
-							Name:      fmt.Sprintf("import-%s-%s-%d", remote.Name, svcName, idx),
-							Namespace: svcNs,
-							Labels:    map[string]string{"federation.openshift-service-mesh.io/peer": "todo"},
-						},
-						Spec: istionetv1alpha3.WorkloadEntry{
-							Address: ip,
-							Labels:  maps.MergeCopy(importedSvc.Labels, map[string]string{"security.istio.io/tlsMode": "istio"}),
-							Ports:   makePortsMap(importedSvc.Ports, remote.GetPort()),
-							Network: remote.Network,
-						},
-					})
-				}
+				return nil, err
 			}
+			workloadEntries = append(workloadEntries, importedWorkloadEntries...)
 		}
 	}
 	return workloadEntries, nil
 }
 
+// ImportedWorkloadEntries returns WorkloadEntries for a service imported from the given remote peer.
+// It returns nil if the service does not exist locally, because then it must be imported as a ServiceEntry.
+func (cf *ConfigFactory) ImportedWorkloadEntries(remote config.Remote, importedSvc *v1alpha1.FederatedService) ([]*v1alpha3.WorkloadEntry, error) {
+	return cf.importedWorkloadEntries(remote, importedSvc.GetHostname(), importedSvc, remote.DefinesFailover())
+}
+
+// importedWorkloadEntries returns WorkloadEntries for the service imported under the given local hostname.
+func (cf *ConfigFactory) importedWorkloadEntries(remote config.Remote, hostname string, importedSvc *v1alpha1.FederatedService, failover bool) ([]*v1alpha3.WorkloadEntry, error) {
+	if exists, err := cf.existsLocally(hostname); err != nil || !exists {
+		return nil, err
+	}
+	svcName, svcNs, _ := networking.ParseServiceHostname(hostname)
+
+	// Service already exists - create WorkloadEntries.
+	var workloadEntries []*v1alpha3.WorkloadEntry
+	for idx, ip := range networking.Resolve(remote.Addresses...) {
+		workloadEntries = append(workloadEntries, &v1alpha3.WorkloadEntry{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        common.ObjectName(cf.Owner(), fmt.Sprintf("import-%s-%s-%d", remote.Name, svcName, idx)),
+				Namespace:   svcNs,
+				Labels:      common.ImportLabels(cf.Owner(), remote.Name),
+				Annotations: common.ImportAnnotations(importedSvc.GetHostname()),
+			},
+			Spec: istionetv1alpha3.WorkloadEntry{
+				Address:  ip,
+				Labels:   endpointLabels(importedSvc.Labels, remote, failover),
+				Ports:    makePortsMap(importedSvc.Ports, remote.GetPort()),
+				Network:  remote.Network,
+				Locality: remote.Locality,
+			},
+		})
+	}
+	return workloadEntries, nil
+}
+
+// PeerAuthentication enforces strict mTLS for the federation controller, so that FDS is exposed to remote peers
+// only through the mTLS connection established by the federation ingress gateway.
+func (cf *ConfigFactory) PeerAuthentication() *v1beta1.PeerAuthentication {
+	return &v1beta1.PeerAuthentication{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      common.ObjectName(cf.Owner(), "fds-strict-mtls"),
+			Namespace: cf.namespace,
+			Labels:    common.OwnerLabels(cf.Owner()),
+		},
+		Spec: securityv1beta1.PeerAuthentication{
+			Selector: &typev1beta1.WorkloadSelector{
+				MatchLabels: map[string]string{
+					"app.kubernetes.io/name": "federation-controller",
+				},
+			},
+			Mtls: &securityv1beta1.PeerAuthentication_MutualTLS{
+				Mode: securityv1beta1.PeerAuthentication_MutualTLS_STRICT,
+			},
+		},
+	}
+}
+
+// existsLocally returns true if the service with given hostname exists in the local cluster.
+// Hostnames of services other than Kubernetes services, e.g. local aliases, never exist locally.
+func (cf *ConfigFactory) existsLocally(hostname string) (bool, error) {
+	svcName, svcNs, ok := networking.ParseServiceHostname(hostname)
+	if !ok {
+		return false, nil
+	}
+	if _, err := cf.serviceLister.Services(svcNs).Get(svcName); err != nil {
+		if errors.IsNotFound(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to get Service %s/%s: %w", svcNs, svcName, err)
+	}
+	return true, nil
+}
+
 func (cf *ConfigFactory) serviceEntryForRemoteFederationController(remote config.Remote) *v1alpha3.ServiceEntry {
 	se := &v1alpha3.ServiceEntry{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      remote.ServiceName(),
+			Name:      common.ObjectName(cf.Owner(), remote.ServiceName()),
 			Namespace: cf.cfg.MeshPeers.Local.ControlPlane.Namespace,
-			Labels:    map[string]string{"federation.openshift-service-mesh.io/peer": "todo"},
+			Labels:    common.ImportLabels(cf.Owner(), remote.Name),
 		},
 		Spec: istionetv1alpha3.ServiceEntry{
 			Hosts: []string{remote.ServiceFQDN()},
@@ -392,6 +556,26 @@ func routerCompatibleSNI(svcName, svcNs string, port uint32) string {
 	return fmt.Sprintf("%s-%d.%s.svc.cluster.local", svcName, port, svcNs)
 }
 
+// remoteSNI returns SNI expected by the auto-passthrough gateway of the remote peer for the service
+// with the given exported hostname.
+func remoteSNI(remote config.Remote, hostname string, port uint32) string {
+	if remote.IngressType == config.OpenShiftRouter {
+		if svcName, svcNs, ok := networking.ParseServiceHostname(hostname); ok {
+			return routerCompatibleSNI(svcName, svcNs, port)
+		}
+	}
+	return fmt.Sprintf("outbound_.%d_._.%s", port, hostname)
+}
+
+// endpointLabels returns labels of endpoints imported from the remote with the given service labels.
+func endpointLabels(svcLabels map[string]string, remote config.Remote, failover bool) map[string]string {
+	labels := maps.MergeCopy(svcLabels, map[string]string{"security.istio.io/tlsMode": "istio"})
+	if failover {
+		labels = maps.MergeCopy(labels, failoverLabels(remote))
+	}
+	return labels
+}
+
 func makePortsMap(ports []*v1alpha1.ServicePort, remotePort uint32) map[string]uint32 {
 	m := make(map[string]uint32, len(ports))
 	for _, p := range ports {
@@ -404,10 +588,3 @@ func separateWithDash(hostname string) string {
 	domainLabels := strings.Split(hostname, ".")
 	return strings.Join(domainLabels, "-")
 }
-
-// TODO: this may not work as expected when aliasing will be supported,
-// because there will be no be guarantee that namespace is included.
-func getServiceNameAndNs(hostname string) (string, string) {
-	domainLabels := strings.Split(hostname, ".")
-	return domainLabels[0], domainLabels[1]
-}
@@ -17,6 +17,7 @@ package istio
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"os"
 	"path/filepath"
 	"reflect"
@@ -32,6 +33,7 @@ import (
 	"k8s.io/client-go/kubernetes/fake"
 
 	"github.com/openshift-service-mesh/federation/internal/api/federation/v1alpha1"
+	"github.com/openshift-service-mesh/federation/internal/pkg/common"
 	"github.com/openshift-service-mesh/federation/internal/pkg/config"
 	"github.com/openshift-service-mesh/federation/internal/pkg/legacy/fds"
 	"github.com/openshift-service-mesh/federation/internal/pkg/legacy/informer"
@@ -154,7 +156,7 @@ func TestIngressGateway(t *testing.T) {
 			ObjectMeta: v1.ObjectMeta{
 				Name:      "federation-ingress-gateway",
 				Namespace: "istio-system",
-				Labels:    map[string]string{"federation.openshift-service-mesh.io/peer": "todo"},
+				Labels:    map[string]string{"federation.openshift-service-mesh.io/instance": "east", "federation.openshift-service-mesh.io/mode": "legacy"},
 			},
 			Spec: istionetv1alpha3.Gateway{
 				Selector: map[string]string{"app": "federation-ingress-gateway"},
@@ -182,7 +184,7 @@ func TestIngressGateway(t *testing.T) {
 			ObjectMeta: v1.ObjectMeta{
 				Name:      "federation-ingress-gateway",
 				Namespace: "istio-system",
-				Labels:    map[string]string{"federation.openshift-service-mesh.io/peer": "todo"},
+				Labels:    map[string]string{"federation.openshift-service-mesh.io/instance": "east", "federation.openshift-service-mesh.io/mode": "legacy"},
 			},
 			Spec: istionetv1alpha3.Gateway{
 				Selector: map[string]string{"app": "federation-ingress-gateway"},
@@ -208,6 +210,7 @@ func TestIngressGateway(t *testing.T) {
 			informerFactory := informers.NewSharedInformerFactory(client, 0)
 			serviceInformer := informerFactory.Core().V1().Services().Informer()
 			serviceLister := informerFactory.Core().V1().Services().Lister()
+			namespaceLister := informerFactory.Core().V1().Namespaces().Lister()
 			stopCh := make(chan struct{})
 			informerFactory.Start(stopCh)
 
@@ -223,7 +226,7 @@ func TestIngressGateway(t *testing.T) {
 			}
 			serviceController.RunAndWait(stopCh)
 
-			factory := NewConfigFactory(exportConfig, serviceLister, fds.NewImportedServiceStore(), "istio-system")
+			factory := NewConfigFactory(exportConfig, common.LegacyMode, config.StaticRemotes(exportConfig.MeshPeers.Remotes), serviceLister, namespaceLister, fds.NewImportedServiceStore(), "istio-system")
 			actual, err := factory.IngressGateway()
 			if err != nil {
 				t.Errorf("got unexpected error: %s", err)
@@ -263,6 +266,7 @@ func TestEnvoyFilters(t *testing.T) {
 			informerFactory := informers.NewSharedInformerFactory(client, 0)
 			serviceInformer := informerFactory.Core().V1().Services().Informer()
 			serviceLister := informerFactory.Core().V1().Services().Lister()
+			namespaceLister := informerFactory.Core().V1().Namespaces().Lister()
 			stopCh := make(chan struct{})
 			informerFactory.Start(stopCh)
 
@@ -281,7 +285,7 @@ func TestEnvoyFilters(t *testing.T) {
 			cfg := copyConfig(&exportConfig)
 			cfg.MeshPeers.Local.IngressType = tc.localIngressType
 
-			factory := NewConfigFactory(*cfg, serviceLister, fds.NewImportedServiceStore(), "istio-system")
+			factory := NewConfigFactory(*cfg, common.LegacyMode, config.StaticRemotes(cfg.MeshPeers.Remotes), serviceLister, namespaceLister, fds.NewImportedServiceStore(), "istio-system")
 			envoyFilters := factory.EnvoyFilters()
 			compareResources(t, "envoy-filters", tc.expectedEnvoyFilterFiles, envoyFilters)
 		})
@@ -303,11 +307,54 @@ func TestServiceEntries(t *testing.T) {
 		Network:   "west-network",
 	}}
 
+	importConfigMultiPeer := copyConfig(&exportConfig)
+	importConfigMultiPeer.MeshPeers.Remotes = []config.Remote{{
+		Name:      "west",
+		Addresses: []string{"1.1.1.1", "2.2.2.2"},
+		Network:   "west-network",
+	}, {
+		Name:      "north",
+		Addresses: []string{"north-ingress.net"},
+		Network:   "north-network",
+	}}
+	importConfigFailover := copyConfig(importConfigMultiPeer)
+	importConfigFailover.MeshPeers.Remotes[0].Locality = "us-west-1"
+	importConfigFailover.MeshPeers.Remotes[1].Locality = "eu-north-1/eu-north-1a"
+	importConfigFailover.MeshPeers.Remotes[1].Priority = 1
+	withConflictPolicy := func(policy config.ConflictPolicy) config.Federation {
+		cfg := copyConfig(importConfigMultiPeer)
+		cfg.ImportedServiceSet.ConflictPolicy = policy
+		return *cfg
+	}
+	importConfigAliased := copyConfig(importConfigRemoteIP)
+	importConfigAliased.ImportedServiceSet.Aliases = []config.Alias{{
+		Hostnames: []string{"*.ns1.svc.cluster.local"},
+		Hostname:  "{name}.{peer}.global",
+	}}
+	// Peers export the same hostname with different ports.
+	multiPeerImports := map[string][]*v1alpha1.FederatedService{
+		"west": {importedSvcB_ns1, importedSvcA_ns2},
+		"north": {importedSvcB_ns1, {
+			Hostname: importedSvcA_ns2.Hostname,
+			Labels:   importedSvcA_ns2.Labels,
+			Ports:    []*v1alpha1.ServicePort{importedHttpPort, importedHttpsPort},
+		}},
+	}
+	// Peers export the same port number with different protocols.
+	conflictingProtocolImports := map[string][]*v1alpha1.FederatedService{
+		"west": {importedSvcB_ns1, importedSvcA_ns2},
+		"north": {importedSvcB_ns1, {
+			Hostname: importedSvcA_ns2.Hostname,
+			Labels:   importedSvcA_ns2.Labels,
+			Ports:    []*v1alpha1.ServicePort{{Name: "grpc", Number: importedHttpPort.Number, TargetPort: 9090, Protocol: "GRPC"}, importedHttpsPort},
+		}},
+	}
+
 	testCases := []struct {
 		name                      string
 		cfg                       config.Federation
 		localServices             []*corev1.Service
-		importedServices          []*v1alpha1.FederatedService
+		importedServices          map[string][]*v1alpha1.FederatedService
 		expectedServiceEntryFiles []string
 	}{{
 		name:                      "no ServiceEntry is created if remote addresses are empty",
@@ -318,15 +365,46 @@ func TestServiceEntries(t *testing.T) {
 			"resolution type should be STATIC when remote addresses are IPs",
 		cfg:                       *importConfigRemoteIP,
 		localServices:             []*corev1.Service{svcA_ns1},
-		importedServices:          []*v1alpha1.FederatedService{importedSvcA_ns1, importedSvcB_ns1, importedSvcA_ns2},
+		importedServices:          map[string][]*v1alpha1.FederatedService{"west": {importedSvcA_ns1, importedSvcB_ns1, importedSvcA_ns2}},
 		expectedServiceEntryFiles: []string{"ip/fds.yaml", "ip/svc-b-ns-1.yaml", "ip/svc-a-ns-2.yaml"},
 	}, {
 		name: "ServiceEntries should be created only for services, which do not exist locally; " +
 			"resolution type should be DNS when remote address is a DNS name",
 		cfg:                       *importConfigRemoteDNS,
 		localServices:             []*corev1.Service{svcA_ns1},
-		importedServices:          []*v1alpha1.FederatedService{importedSvcA_ns1, importedSvcB_ns1, importedSvcA_ns2},
+		importedServices:          map[string][]*v1alpha1.FederatedService{"west": {importedSvcA_ns1, importedSvcB_ns1, importedSvcA_ns2}},
 		expectedServiceEntryFiles: []string{"dns/fds.yaml", "dns/svc-b-ns-1.yaml", "dns/svc-a-ns-2.yaml"},
+	}, {
+		name: "services exported by multiple peers should be merged into a single ServiceEntry; " +
+			"services with conflicting ports should not be imported by default",
+		cfg:                       *importConfigMultiPeer,
+		importedServices:          multiPeerImports,
+		expectedServiceEntryFiles: []string{"ip/fds.yaml", "multi-peer/fds-north.yaml", "multi-peer/svc-b-ns-1.yaml"},
+	}, {
+		name:                      "endpoints should have locality and failover priority of their peers when any peer defines failover",
+		cfg:                       *importConfigFailover,
+		importedServices:          map[string][]*v1alpha1.FederatedService{"west": {importedSvcB_ns1}, "north": {importedSvcB_ns1}},
+		expectedServiceEntryFiles: []string{"ip/fds.yaml", "multi-peer/fds-north.yaml", "failover/svc-b-ns-1.yaml"},
+	}, {
+		name:                      "services with conflicting ports should be imported only from the first peer with FirstWins policy",
+		cfg:                       withConflictPolicy(config.FirstWins),
+		importedServices:          multiPeerImports,
+		expectedServiceEntryFiles: []string{"ip/fds.yaml", "multi-peer/fds-north.yaml", "multi-peer/svc-b-ns-1.yaml", "multi-peer/svc-a-ns-2-first-wins.yaml"},
+	}, {
+		name:                      "services with conflicting ports should be imported with ports and endpoints of all peers with Union policy",
+		cfg:                       withConflictPolicy(config.Union),
+		importedServices:          multiPeerImports,
+		expectedServiceEntryFiles: []string{"ip/fds.yaml", "multi-peer/fds-north.yaml", "multi-peer/svc-b-ns-1.yaml", "multi-peer/svc-a-ns-2-union.yaml"},
+	}, {
+		name:                      "services exporting the same port with different protocols should not be imported with Union policy",
+		cfg:                       withConflictPolicy(config.Union),
+		importedServices:          conflictingProtocolImports,
+		expectedServiceEntryFiles: []string{"ip/fds.yaml", "multi-peer/fds-north.yaml", "multi-peer/svc-b-ns-1.yaml"},
+	}, {
+		name:                      "services matching an alias should be imported under the local hostname",
+		cfg:                       *importConfigAliased,
+		importedServices:          map[string][]*v1alpha1.FederatedService{"west": {importedSvcB_ns1, importedSvcA_ns2}},
+		expectedServiceEntryFiles: []string{"ip/fds.yaml", "alias/svc-b-west.yaml", "ip/svc-a-ns-2.yaml"},
 	}}
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
@@ -334,6 +412,7 @@ func TestServiceEntries(t *testing.T) {
 			informerFactory := informers.NewSharedInformerFactory(client, 0)
 			serviceInformer := informerFactory.Core().V1().Services().Informer()
 			serviceLister := informerFactory.Core().V1().Services().Lister()
+			namespaceLister := informerFactory.Core().V1().Namespaces().Lister()
 			stopCh := make(chan struct{})
 			informerFactory.Start(stopCh)
 
@@ -350,9 +429,11 @@ func TestServiceEntries(t *testing.T) {
 			serviceController.RunAndWait(stopCh)
 
 			importedServiceStore := fds.NewImportedServiceStore()
-			importedServiceStore.Update("west", tc.importedServices)
+			for peer, importedServices := range tc.importedServices {
+				importedServiceStore.Update(peer, importedServices)
+			}
 
-			factory := NewConfigFactory(tc.cfg, serviceLister, importedServiceStore, "istio-system")
+			factory := NewConfigFactory(tc.cfg, common.LegacyMode, config.StaticRemotes(tc.cfg.MeshPeers.Remotes), serviceLister, namespaceLister, importedServiceStore, "istio-system")
 			serviceEntries, err := factory.ServiceEntries()
 			if err != nil {
 				t.Fatalf("error getting ServiceEntries: %v", err)
@@ -362,6 +443,99 @@ func TestServiceEntries(t *testing.T) {
 	}
 }
 
+func TestDestinationRules(t *testing.T) {
+	istioRemotes := []config.Remote{{
+		Name:      "west",
+		Addresses: []string{"1.1.1.1"},
+	}, {
+		Name:      "north",
+		Addresses: []string{"north-ingress.net"},
+	}}
+	failoverRemotes := []config.Remote{{
+		Name:      "west",
+		Addresses: []string{"1.1.1.1"},
+		Locality:  "us-west-1",
+	}, {
+		Name:      "north",
+		Addresses: []string{"north-ingress.net"},
+		Locality:  "eu-north-1/eu-north-1a",
+		Priority:  1,
+	}}
+	westAlias := []config.Alias{{
+		Peers:    []string{"west"},
+		Hostname: "{name}.{peer}.global",
+	}}
+
+	testCases := []struct {
+		name                         string
+		remotes                      []config.Remote
+		aliases                      []config.Alias
+		importedServices             map[string][]*v1alpha1.FederatedService
+		expectedDestinationRuleFiles []string
+	}{{
+		name:                         "no DestinationRule is created if remotes use istio ingress and do not define failover",
+		remotes:                      istioRemotes,
+		importedServices:             map[string][]*v1alpha1.FederatedService{"west": {importedSvcB_ns1}, "north": {importedSvcB_ns1}},
+		expectedDestinationRuleFiles: []string{},
+	}, {
+		name:                         "DestinationRules should prefer local endpoints and fail over to remotes in order of priority",
+		remotes:                      failoverRemotes,
+		importedServices:             map[string][]*v1alpha1.FederatedService{"west": {importedSvcB_ns1, importedSvcA_ns2}, "north": {importedSvcB_ns1}},
+		expectedDestinationRuleFiles: []string{"failover/svc-b-ns-1.yaml", "failover/svc-a-ns-2.yaml"},
+	}, {
+		name:                         "DestinationRules should set SNI of the exported hostname for services imported under an alias",
+		remotes:                      istioRemotes,
+		aliases:                      westAlias,
+		importedServices:             map[string][]*v1alpha1.FederatedService{"west": {importedSvcB_ns1}, "north": {importedSvcB_ns1}},
+		expectedDestinationRuleFiles: []string{"alias/svc-b-west.yaml"},
+	}}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			importedServiceStore := fds.NewImportedServiceStore()
+			for peer, importedServices := range tc.importedServices {
+				importedServiceStore.Update(peer, importedServices)
+			}
+
+			cfg := copyConfig(&exportConfig)
+			cfg.ImportedServiceSet.Aliases = tc.aliases
+			factory := NewConfigFactory(*cfg, common.LegacyMode, config.StaticRemotes(tc.remotes), nil, nil, importedServiceStore, "istio-system")
+			compareResources(t, "destination-rules", tc.expectedDestinationRuleFiles, factory.DestinationRules())
+		})
+	}
+}
+
+func TestImportedServiceSplit(t *testing.T) {
+	remote := config.Remote{
+		Name:      "west",
+		Addresses: []string{"1.1.1.1"},
+		Network:   "west-network",
+	}
+	importedSvc := &v1alpha1.FederatedService{
+		Hostname: "b.ns1.svc.cluster.local",
+		Labels:   map[string]string{"app": "b"},
+		Ports: []*v1alpha1.ServicePort{importedHttpPort, importedHttpsPort, {
+			Name:   "tcp",
+			Number: 9080,
+		}},
+	}
+
+	factory := NewConfigFactory(exportConfig, common.LegacyMode, config.StaticRemotes{remote}, nil, nil, fds.NewImportedServiceStore(), "istio-system")
+	serviceEntry, destinationRule, virtualService := factory.ImportedServiceSplit(remote, importedSvc.Hostname, importedSvc, 10)
+
+	if hosts := serviceEntry.Spec.Hosts; !reflect.DeepEqual(hosts, []string{"b.ns1.west.federation.internal"}) {
+		t.Errorf("expected ServiceEntry for the internal hostname, got hosts: %v", hosts)
+	}
+	if destinationRule.Spec.Host != "b.ns1.west.federation.internal" {
+		t.Errorf("expected DestinationRule for the internal hostname, got host: %s", destinationRule.Spec.Host)
+	}
+	for _, portSettings := range destinationRule.Spec.TrafficPolicy.PortLevelSettings {
+		if expectedSNI := fmt.Sprintf("outbound_.%d_._.b.ns1.svc.cluster.local", portSettings.Port.Number); portSettings.Tls.Sni != expectedSNI {
+			t.Errorf("expected SNI %s, got: %s", expectedSNI, portSettings.Tls.Sni)
+		}
+	}
+	compareResources(t, "virtual-services", []string{"split.yaml"}, []*v1alpha3.VirtualService{virtualService})
+}
+
 func export(svc *corev1.Service) *corev1.Service {
 	exported := svc.DeepCopy()
 	if exported.Labels == nil {
@@ -0,0 +1,88 @@
+// Copyright Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the License);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an AS IS BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package istio
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+	istionetv1alpha3 "istio.io/api/networking/v1alpha3"
+
+	"github.com/openshift-service-mesh/federation/internal/pkg/config"
+)
+
+// failoverLabelPrefix is the prefix of the label, which determines the failover priority of imported endpoints.
+const failoverLabelPrefix = "federation.openshift-service-mesh.io/failover-priority-"
+
+// definesFailover returns true if any of the remotes exporting the service defines locality or priority.
+func definesFailover(imports []peerImport) bool {
+	for _, imp := range imports {
+		if imp.remote.DefinesFailover() {
+			return true
+		}
+	}
+	return false
+}
+
+// failoverLabels returns labels of endpoints imported from the remote, which determine their priority in failover.
+func failoverLabels(remote config.Remote) map[string]string {
+	return map[string]string{failoverLabel(remote.Priority): "true"}
+}
+
+func failoverLabel(priority uint32) string {
+	return fmt.Sprintf("%s%d", failoverLabelPrefix, priority)
+}
+
+// setFailover configures the traffic policy to prefer local endpoints and to fail over to endpoints imported from
+// the remotes in order of their priority. Endpoints with the same priority are ordered by locality.
+//
+// Istio lowers the priority of endpoints, which labels differ from labels of the client proxy, the more,
+// the earlier the label is listed in failoverPriority. Neither proxies nor local endpoints have failover labels,
+// so listing them from the highest priority value makes local endpoints preferred over all remotes,
+// and remotes with lower priority values preferred over remotes with higher values.
+func setFailover(policy *istionetv1alpha3.TrafficPolicy, remotes []config.Remote) {
+	priorities := make(map[uint32]bool)
+	for _, remote := range remotes {
+		priorities[remote.Priority] = true
+	}
+	values := make([]uint32, 0, len(priorities))
+	for priority := range priorities {
+		values = append(values, priority)
+	}
+	sort.Slice(values, func(i, j int) bool {
+		return values[i] > values[j]
+	})
+
+	var failoverPriority []string
+	for _, priority := range values {
+		failoverPriority = append(failoverPriority, failoverLabel(priority))
+	}
+	policy.LoadBalancer = &istionetv1alpha3.LoadBalancerSettings{
+		LocalityLbSetting: &istionetv1alpha3.LocalityLoadBalancerSetting{
+			Enabled:          wrapperspb.Bool(true),
+			FailoverPriority: failoverPriority,
+		},
+	}
+	// Failover requires outlier detection. All endpoints of a remote mesh can be ejected, as other meshes take over then.
+	policy.OutlierDetection = &istionetv1alpha3.OutlierDetection{
+		Consecutive_5XxErrors: wrapperspb.UInt32(5),
+		Interval:              durationpb.New(10 * time.Second),
+		BaseEjectionTime:      durationpb.New(30 * time.Second),
+		MaxEjectionPercent:    100,
+	}
+}
@@ -0,0 +1,142 @@
+// Copyright Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the License);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an AS IS BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package istio
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/openshift-service-mesh/federation/internal/api/federation/v1alpha1"
+	"github.com/openshift-service-mesh/federation/internal/pkg/config"
+)
+
+// peerImport is a service imported from the remote peer.
+type peerImport struct {
+	remote config.Remote
+	svc    *v1alpha1.FederatedService
+	// hostname is the local hostname of the imported service, which differs from the exported one if the service is aliased.
+	hostname string
+}
+
+// importsByHostname returns sorted local hostnames of services imported from all remotes and the imports of each hostname.
+// Imports of the same hostname are ordered by the remote name, so that the first peer is always the same.
+func (cf *ConfigFactory) importsByHostname() ([]string, map[string][]peerImport) {
+	remotes := append([]config.Remote(nil), cf.remotes.Remotes()...)
+	sort.SliceStable(remotes, func(i, j int) bool {
+		return remotes[i].Name < remotes[j].Name
+	})
+	imports := make(map[string][]peerImport)
+	for _, remote := range remotes {
+		for _, svc := range cf.importedServiceStore.From(remote) {
+			hostname := cf.cfg.ImportedServiceSet.LocalHostname(remote.Name, svc.GetHostname())
+			imports[hostname] = append(imports[hostname], peerImport{remote: remote, svc: svc, hostname: hostname})
+		}
+	}
+	hostnames := make([]string, 0, len(imports))
+	for hostname := range imports {
+		hostnames = append(hostnames, hostname)
+	}
+	sort.Strings(hostnames)
+	return hostnames, imports
+}
+
+// sourceHostname returns the hostname exported by all peers of the given imports. Imports of services with different
+// hostnames can't be merged under one local hostname, because the remote gateways route requests by SNI derived from it.
+func sourceHostname(imports []peerImport) (string, error) {
+	hostname := imports[0].svc.GetHostname()
+	for _, imp := range imports[1:] {
+		if imp.svc.GetHostname() != hostname {
+			return "", fmt.Errorf("peers %s and %s export different services %s and %s under the same local hostname",
+				imports[0].remote.Name, imp.remote.Name, hostname, imp.svc.GetHostname())
+		}
+	}
+	return hostname, nil
+}
+
+// mergePorts returns ports of the service exported by the given peers and the imports, which endpoints should be included
+// in the merged service. If the peers do not export the same ports, the result depends on the conflict policy,
+// and an error is returned if conflicts are rejected. Ports with the same number and different protocols
+// are rejected by any policy, because requests of one protocol would be routed to endpoints expecting the other.
+func mergePorts(policy config.ConflictPolicy, imports []peerImport) ([]*v1alpha1.ServicePort, []peerImport, error) {
+	first := imports[0]
+	var conflicting []string
+	for _, imp := range imports[1:] {
+		if !samePorts(first.svc.Ports, imp.svc.Ports) {
+			conflicting = append(conflicting, imp.remote.Name)
+		}
+	}
+	if len(conflicting) == 0 {
+		return first.svc.Ports, imports, nil
+	}
+
+	switch policy {
+	case config.FirstWins:
+		included := []peerImport{first}
+		for _, imp := range imports[1:] {
+			if samePorts(first.svc.Ports, imp.svc.Ports) {
+				included = append(included, imp)
+			}
+		}
+		return first.svc.Ports, included, nil
+	case config.Union:
+		var ports []*v1alpha1.ServicePort
+		exportedBy := make(map[uint32]peerImport)
+		for _, imp := range imports {
+			for _, port := range imp.svc.Ports {
+				other, found := exportedBy[port.Number]
+				if !found {
+					exportedBy[port.Number] = imp
+					ports = append(ports, port)
+					continue
+				}
+				if protocol := portProtocol(other.svc.Ports, port.Number); protocol != port.Protocol {
+					return nil, nil, fmt.Errorf("peers %s and %s export port %d with different protocols %s and %s",
+						other.remote.Name, imp.remote.Name, port.Number, protocol, port.Protocol)
+				}
+			}
+		}
+		return ports, imports, nil
+	default:
+		return nil, nil, fmt.Errorf("ports exported by peers %v differ from ports exported by peer %s", conflicting, first.remote.Name)
+	}
+}
+
+// portProtocol returns the protocol of the port with the given number.
+func portProtocol(ports []*v1alpha1.ServicePort, number uint32) string {
+	for _, port := range ports {
+		if port.Number == number {
+			return port.Protocol
+		}
+	}
+	return ""
+}
+
+// samePorts returns true if both services define the same ports regardless of their order.
+func samePorts(a, b []*v1alpha1.ServicePort) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	byNumber := make(map[uint32]*v1alpha1.ServicePort, len(a))
+	for _, port := range a {
+		byNumber[port.Number] = port
+	}
+	for _, port := range b {
+		other, found := byNumber[port.Number]
+		if !found || other.Name != port.Name || other.Protocol != port.Protocol || other.TargetPort != port.TargetPort {
+			return false
+		}
+	}
+	return true
+}
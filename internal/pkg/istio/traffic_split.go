@@ -0,0 +1,102 @@
+// Copyright Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the License);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an AS IS BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package istio
+
+import (
+	"fmt"
+	"strings"
+
+	istionetv1alpha3 "istio.io/api/networking/v1alpha3"
+	"istio.io/client-go/pkg/apis/networking/v1alpha3"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/openshift-service-mesh/federation/internal/api/federation/v1alpha1"
+	"github.com/openshift-service-mesh/federation/internal/pkg/common"
+	"github.com/openshift-service-mesh/federation/internal/pkg/config"
+	"github.com/openshift-service-mesh/federation/internal/pkg/networking"
+)
+
+// ImportedServiceSplit returns objects sending the given percentage of requests for the local service to the service
+// imported from the remote, and the remaining requests to local endpoints. Imported endpoints are not merged with
+// local endpoints, but are exposed under an internal hostname, to which the VirtualService routes requests.
+func (cf *ConfigFactory) ImportedServiceSplit(
+	remote config.Remote, remoteHostname string, importedSvc *v1alpha1.FederatedService, weight int32,
+) (*v1alpha3.ServiceEntry, *v1alpha3.DestinationRule, *v1alpha3.VirtualService) {
+	hostname := importedSvc.GetHostname()
+	remoteSvc := importedSvc.DeepCopy()
+	remoteSvc.Hostname = splitHostname(remote, hostname)
+
+	serviceEntry := importedServiceEntry(metav1.ObjectMeta{
+		Name:        common.ObjectName(cf.Owner(), fmt.Sprintf("import-%s-%s", separateWithDash(hostname), remote.Name)),
+		Namespace:   cf.cfg.MeshPeers.Local.ControlPlane.Namespace,
+		Labels:      common.ImportLabels(cf.Owner(), remote.Name),
+		Annotations: common.ImportAnnotations(remoteHostname),
+	}, remoteSvc.Hostname, remoteSvc.Ports, []peerImport{{remote: remote, svc: remoteSvc}}, false)
+
+	virtualService := &v1alpha3.VirtualService{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        common.ObjectName(cf.Owner(), fmt.Sprintf("split-%s", separateWithDash(hostname))),
+			Namespace:   cf.cfg.MeshPeers.Local.ControlPlane.Namespace,
+			Labels:      common.ImportLabels(cf.Owner(), remote.Name),
+			Annotations: common.ImportAnnotations(remoteHostname),
+		},
+		Spec: istionetv1alpha3.VirtualService{
+			Hosts: []string{hostname},
+		},
+	}
+	for _, port := range importedSvc.Ports {
+		localDestination := &istionetv1alpha3.Destination{Host: hostname, Port: &istionetv1alpha3.PortSelector{Number: port.Number}}
+		remoteDestination := &istionetv1alpha3.Destination{Host: remoteSvc.Hostname, Port: &istionetv1alpha3.PortSelector{Number: port.Number}}
+		// Requests are routed by the protocol, which must be known to the client proxy.
+		switch strings.ToUpper(port.Protocol) {
+		case "HTTP", "HTTP2", "GRPC":
+			virtualService.Spec.Http = append(virtualService.Spec.Http, &istionetv1alpha3.HTTPRoute{
+				Name:  port.Name,
+				Match: []*istionetv1alpha3.HTTPMatchRequest{{Port: port.Number}},
+				Route: []*istionetv1alpha3.HTTPRouteDestination{
+					{Destination: localDestination, Weight: 100 - weight},
+					{Destination: remoteDestination, Weight: weight},
+				},
+			})
+		case "HTTPS", "TLS":
+			virtualService.Spec.Tls = append(virtualService.Spec.Tls, &istionetv1alpha3.TLSRoute{
+				Match: []*istionetv1alpha3.TLSMatchAttributes{{Port: port.Number, SniHosts: []string{hostname}}},
+				Route: []*istionetv1alpha3.RouteDestination{
+					{Destination: localDestination, Weight: 100 - weight},
+					{Destination: remoteDestination, Weight: weight},
+				},
+			})
+		default:
+			virtualService.Spec.Tcp = append(virtualService.Spec.Tcp, &istionetv1alpha3.TCPRoute{
+				Match: []*istionetv1alpha3.L4MatchAttributes{{Port: port.Number}},
+				Route: []*istionetv1alpha3.RouteDestination{
+					{Destination: localDestination, Weight: 100 - weight},
+					{Destination: remoteDestination, Weight: weight},
+				},
+			})
+		}
+	}
+
+	return serviceEntry, cf.ImportedServiceDestinationRule(remote, remoteHostname, remoteSvc), virtualService
+}
+
+// splitHostname returns the internal hostname of the service imported from the remote, when requests for the service
+// are split between local endpoints and the remote service.
+func splitHostname(remote config.Remote, hostname string) string {
+	if svcName, svcNs, ok := networking.ParseServiceHostname(hostname); ok {
+		return fmt.Sprintf("%s.%s.%s.federation.internal", svcName, svcNs, remote.Name)
+	}
+	return fmt.Sprintf("%s.%s.federation.internal", hostname, remote.Name)
+}
@@ -0,0 +1,205 @@
+// Copyright Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the License);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an AS IS BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fds
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/openshift-service-mesh/federation/internal/api/federation/v1alpha1"
+)
+
+const (
+	// CheckpointConfigMapName is the name of the ConfigMap storing services imported from remote peers.
+	CheckpointConfigMapName = "federation-imported-services"
+
+	checkpointInterval = 10 * time.Second
+)
+
+// maxCheckpointSize is the maximum size of data stored in a ConfigMap.
+const maxCheckpointSize = corev1.MaxSecretSize
+
+// checkpointEntry is the format of services imported from a single peer stored under the peer name in the ConfigMap.
+// Entries are compressed with gzip and stored in binary data, while entries saved by previous versions are stored
+// as plain JSON in data.
+type checkpointEntry struct {
+	SyncTime time.Time         `json:"syncTime"`
+	Services []json.RawMessage `json:"services"`
+}
+
+// Checkpointer stores imported services in a ConfigMap, so that they can be restored after the controller restarts,
+// and Istio configs generated for imported services are not deleted before remote peers are connected again.
+type Checkpointer struct {
+	client    kubernetes.Interface
+	namespace string
+	store     *ImportedServiceStore
+	// savedGeneration is the generation of the store saved most recently.
+	savedGeneration uint64
+}
+
+func NewCheckpointer(client kubernetes.Interface, namespace string, store *ImportedServiceStore) *Checkpointer {
+	return &Checkpointer{
+		client:    client,
+		namespace: namespace,
+		store:     store,
+	}
+}
+
+// Restore loads imported services from the checkpoint into the store. A missing checkpoint is not an error.
+func (c *Checkpointer) Restore(ctx context.Context) error {
+	cm, err := c.client.CoreV1().ConfigMaps(c.namespace).Get(ctx, CheckpointConfigMapName, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		log.Infof("checkpoint of imported services not found, starting with empty state")
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get checkpoint of imported services: %w", err)
+	}
+
+	entries := make(map[string][]byte, len(cm.Data)+len(cm.BinaryData))
+	for source, data := range cm.Data {
+		entries[source] = []byte(data)
+	}
+	for source, compressed := range cm.BinaryData {
+		data, err := decompress(compressed)
+		if err != nil {
+			return fmt.Errorf("failed to decompress checkpoint of services imported from %s: %w", source, err)
+		}
+		entries[source] = data
+	}
+
+	for source, data := range entries {
+		var entry checkpointEntry
+		if err := json.Unmarshal(data, &entry); err != nil {
+			return fmt.Errorf("failed to parse checkpoint of services imported from %s: %w", source, err)
+		}
+		services := make([]*v1alpha1.FederatedService, 0, len(entry.Services))
+		for _, raw := range entry.Services {
+			svc := &v1alpha1.FederatedService{}
+			if err := protojson.Unmarshal(raw, svc); err != nil {
+				return fmt.Errorf("failed to parse service imported from %s: %w", source, err)
+			}
+			services = append(services, svc)
+		}
+		log.Infof("restored %d services imported from %s, last synced at %s", len(services), source, entry.SyncTime.Format(time.RFC3339))
+		c.store.Restore(source, services, entry.SyncTime)
+	}
+	return nil
+}
+
+// Run saves imported services whenever they change until the context is done.
+func (c *Checkpointer) Run(ctx context.Context) {
+	c.savedGeneration = c.store.currentGeneration()
+
+	ticker := time.NewTicker(checkpointInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := c.save(ctx); err != nil {
+				log.Errorf("failed to save checkpoint of imported services: %v", err)
+			}
+		}
+	}
+}
+
+func (c *Checkpointer) save(ctx context.Context) error {
+	generation, services, syncTimes := c.store.snapshot()
+	if generation == c.savedGeneration {
+		return nil
+	}
+
+	data := make(map[string][]byte, len(services))
+	size := 0
+	for source, sourceServices := range services {
+		entry := checkpointEntry{SyncTime: syncTimes[source]}
+		for _, svc := range sourceServices {
+			raw, err := protojson.Marshal(svc)
+			if err != nil {
+				return fmt.Errorf("failed to serialize service %s imported from %s: %w", svc.Hostname, source, err)
+			}
+			entry.Services = append(entry.Services, raw)
+		}
+		serialized, err := json.Marshal(entry)
+		if err != nil {
+			return fmt.Errorf("failed to serialize services imported from %s: %w", source, err)
+		}
+		if data[source], err = compress(serialized); err != nil {
+			return fmt.Errorf("failed to compress services imported from %s: %w", source, err)
+		}
+		size += len(source) + len(data[source])
+	}
+	if size > maxCheckpointSize {
+		// Saving is not retried until imported services change, as the checkpoint would be rejected again.
+		c.savedGeneration = generation
+		return fmt.Errorf("checkpoint of imported services has %d bytes, which exceeds the limit of %d bytes", size, maxCheckpointSize)
+	}
+
+	configMaps := c.client.CoreV1().ConfigMaps(c.namespace)
+	cm, err := configMaps.Get(ctx, CheckpointConfigMapName, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		_, err = configMaps.Create(ctx, &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      CheckpointConfigMapName,
+				Namespace: c.namespace,
+			},
+			BinaryData: data,
+		}, metav1.CreateOptions{})
+	} else if err == nil {
+		cm.Data = nil
+		cm.BinaryData = data
+		_, err = configMaps.Update(ctx, cm, metav1.UpdateOptions{})
+	}
+	if err != nil {
+		return err
+	}
+
+	c.savedGeneration = generation
+	return nil
+}
+
+func compress(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	writer := gzip.NewWriter(&buf)
+	if _, err := writer.Write(data); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func decompress(data []byte) ([]byte, error) {
+	reader, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return io.ReadAll(reader)
+}
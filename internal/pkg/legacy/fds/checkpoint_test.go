@@ -0,0 +1,131 @@
+// Copyright Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the License);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an AS IS BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fds
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/openshift-service-mesh/federation/internal/api/federation/v1alpha1"
+	"github.com/openshift-service-mesh/federation/internal/pkg/config"
+)
+
+func TestCheckpointRestoresImportedServices(t *testing.T) {
+	ctx := context.Background()
+	client := fake.NewSimpleClientset()
+
+	store := NewImportedServiceStore()
+	store.Update("west", []*v1alpha1.FederatedService{{
+		Hostname: "ratings.bookinfo.svc.cluster.local",
+		Ports:    []*v1alpha1.ServicePort{{Name: "http", Number: 9080, Protocol: "HTTP"}},
+		Labels:   map[string]string{"app": "ratings"},
+	}})
+	if err := NewCheckpointer(client, "istio-system", store).save(ctx); err != nil {
+		t.Fatalf("failed to save checkpoint: %v", err)
+	}
+
+	restored := NewImportedServiceStore()
+	if err := NewCheckpointer(client, "istio-system", restored).Restore(ctx); err != nil {
+		t.Fatalf("failed to restore checkpoint: %v", err)
+	}
+
+	services := restored.From(config.Remote{Name: "west"})
+	if len(services) != 1 {
+		t.Fatalf("expected 1 restored service but got %d", len(services))
+	}
+	if services[0].Hostname != "ratings.bookinfo.svc.cluster.local" || services[0].Ports[0].Number != 9080 || services[0].Labels["app"] != "ratings" {
+		t.Errorf("restored service does not match the saved one: %v", services[0])
+	}
+	if restored.SyncTime("west").IsZero() {
+		t.Errorf("expected sync time to be restored")
+	}
+	if awaiting := restored.AwaitingSync(); len(awaiting) != 1 || awaiting[0] != "west" {
+		t.Errorf("expected west to await sync but got %v", awaiting)
+	}
+
+	restored.Update("west", services)
+	if awaiting := restored.AwaitingSync(); len(awaiting) != 0 {
+		t.Errorf("expected no peers awaiting sync after update but got %v", awaiting)
+	}
+}
+
+func TestCheckpointRestoresUncompressedEntries(t *testing.T) {
+	ctx := context.Background()
+	client := fake.NewSimpleClientset(&corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: CheckpointConfigMapName, Namespace: "istio-system"},
+		Data: map[string]string{
+			"west": `{"syncTime":"2024-06-01T10:00:00Z","services":[{"hostname":"ratings.bookinfo.svc.cluster.local"}]}`,
+		},
+	})
+
+	restored := NewImportedServiceStore()
+	if err := NewCheckpointer(client, "istio-system", restored).Restore(ctx); err != nil {
+		t.Fatalf("failed to restore checkpoint: %v", err)
+	}
+	if hostnames := restored.Hostnames("west"); len(hostnames) != 1 || hostnames[0] != "ratings.bookinfo.svc.cluster.local" {
+		t.Errorf("expected service saved by the previous version to be restored, got %v", hostnames)
+	}
+
+	// The checkpoint is replaced by compressed entries when it is saved again.
+	if err := NewCheckpointer(client, "istio-system", restored).save(ctx); err != nil {
+		t.Fatalf("failed to save checkpoint: %v", err)
+	}
+	cm, err := client.CoreV1().ConfigMaps("istio-system").Get(ctx, CheckpointConfigMapName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("failed to get checkpoint: %v", err)
+	}
+	if len(cm.Data) != 0 || len(cm.BinaryData) != 1 {
+		t.Errorf("expected only compressed entries, got %d uncompressed and %d compressed", len(cm.Data), len(cm.BinaryData))
+	}
+}
+
+func TestCheckpointExceedingSizeLimitIsNotSaved(t *testing.T) {
+	ctx := context.Background()
+	client := fake.NewSimpleClientset()
+
+	// Random labels cannot be compressed, so that the checkpoint exceeds the limit.
+	services := make([]*v1alpha1.FederatedService, 0, 3000)
+	for i := range cap(services) {
+		random := make([]byte, 512)
+		if _, err := rand.Read(random); err != nil {
+			t.Fatalf("failed to generate label: %v", err)
+		}
+		services = append(services, &v1alpha1.FederatedService{
+			Hostname: fmt.Sprintf("svc-%d.bookinfo.svc.cluster.local", i),
+			Labels:   map[string]string{"random": base64.StdEncoding.EncodeToString(random)},
+		})
+	}
+	store := NewImportedServiceStore()
+	store.Update("west", services)
+
+	checkpointer := NewCheckpointer(client, "istio-system", store)
+	if err := checkpointer.save(ctx); err == nil {
+		t.Fatal("expected error saving checkpoint exceeding the size limit")
+	}
+	if _, err := client.CoreV1().ConfigMaps("istio-system").Get(ctx, CheckpointConfigMapName, metav1.GetOptions{}); !apierrors.IsNotFound(err) {
+		t.Errorf("expected checkpoint not to be saved, got %v", err)
+	}
+	if err := checkpointer.save(ctx); err != nil {
+		t.Errorf("expected saving not to be retried until imported services change, got %v", err)
+	}
+}
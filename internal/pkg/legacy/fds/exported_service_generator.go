@@ -18,12 +18,13 @@ import (
 	"fmt"
 	"strings"
 
+	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
 	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/types/known/anypb"
-	"k8s.io/apimachinery/pkg/labels"
 	v1 "k8s.io/client-go/listers/core/v1"
 
 	"github.com/openshift-service-mesh/federation/internal/api/federation/v1alpha1"
+	"github.com/openshift-service-mesh/federation/internal/pkg/common"
 	"github.com/openshift-service-mesh/federation/internal/pkg/config"
 	"github.com/openshift-service-mesh/federation/internal/pkg/legacy/xds"
 	"github.com/openshift-service-mesh/federation/internal/pkg/legacy/xds/adss"
@@ -32,14 +33,16 @@ import (
 var _ adss.RequestHandler = (*ExportedServicesGenerator)(nil)
 
 type ExportedServicesGenerator struct {
-	cfg           config.Federation
-	serviceLister v1.ServiceLister
+	cfg             config.Federation
+	serviceLister   v1.ServiceLister
+	namespaceLister v1.NamespaceLister
 }
 
-func NewExportedServicesGenerator(cfg config.Federation, serviceLister v1.ServiceLister) *ExportedServicesGenerator {
+func NewExportedServicesGenerator(cfg config.Federation, serviceLister v1.ServiceLister, namespaceLister v1.NamespaceLister) *ExportedServicesGenerator {
 	return &ExportedServicesGenerator{
-		cfg:           cfg,
-		serviceLister: serviceLister,
+		cfg:             cfg,
+		serviceLister:   serviceLister,
+		namespaceLister: namespaceLister,
 	}
 }
 
@@ -47,34 +50,34 @@ func (g *ExportedServicesGenerator) GetTypeUrl() string {
 	return xds.ExportedServiceTypeUrl
 }
 
-func (g *ExportedServicesGenerator) GenerateResponse() ([]*anypb.Any, error) {
+// GenerateResponse returns services exported to the given subscriber. Rules restricted to other peers are not applied.
+func (g *ExportedServicesGenerator) GenerateResponse(subscriber adss.Identity) ([]*discovery.Resource, error) {
+	rules := g.cfg.ExportedServiceSet.RulesFor(subscriber.Names()...)
+	services, err := common.ExportedServices(g.serviceLister, g.namespaceLister, rules)
+	if err != nil {
+		return nil, err
+	}
+
 	var exportedServices []*v1alpha1.FederatedService
-	for _, exportLabelSelector := range g.cfg.ExportedServiceSet.GetLabelSelectors() {
-		matchExported := labels.SelectorFromSet(exportLabelSelector.MatchLabels)
-		services, err := g.serviceLister.List(matchExported)
-		if err != nil {
-			return nil, fmt.Errorf("failed to list services: %w", err)
-		}
-		for _, svc := range services {
-			var ports []*v1alpha1.ServicePort
-			for _, port := range svc.Spec.Ports {
-				servicePort := &v1alpha1.ServicePort{
-					Name:   port.Name,
-					Number: uint32(port.Port),
-				}
-				if port.TargetPort.IntVal != 0 {
-					servicePort.TargetPort = uint32(port.TargetPort.IntVal)
-				}
-				servicePort.Protocol = detectProtocol(port.Name)
-				ports = append(ports, servicePort)
+	for _, svc := range services {
+		var ports []*v1alpha1.ServicePort
+		for _, port := range svc.Spec.Ports {
+			servicePort := &v1alpha1.ServicePort{
+				Name:   port.Name,
+				Number: uint32(port.Port),
 			}
-			exportedService := &v1alpha1.FederatedService{
-				Hostname: fmt.Sprintf("%s.%s.svc.cluster.local", svc.Name, svc.Namespace),
-				Ports:    ports,
-				Labels:   svc.Labels,
+			if port.TargetPort.IntVal != 0 {
+				servicePort.TargetPort = uint32(port.TargetPort.IntVal)
 			}
-			exportedServices = append(exportedServices, exportedService)
+			servicePort.Protocol = detectProtocol(port.Name)
+			ports = append(ports, servicePort)
+		}
+		exportedService := &v1alpha1.FederatedService{
+			Hostname: fmt.Sprintf("%s.%s.svc.cluster.local", svc.Name, svc.Namespace),
+			Ports:    ports,
+			Labels:   svc.Labels,
 		}
+		exportedServices = append(exportedServices, exportedService)
 	}
 	return serialize(exportedServices)
 }
@@ -97,14 +100,19 @@ func detectProtocol(portName string) string {
 	return "TCP"
 }
 
-func serialize(exportedServices []*v1alpha1.FederatedService) ([]*anypb.Any, error) {
-	var serializedServices []*anypb.Any
+// serialize returns exported services as XDS resources named by hostname. Serialization is deterministic,
+// so that versions derived from the content of resources do not change if services did not change.
+func serialize(exportedServices []*v1alpha1.FederatedService) ([]*discovery.Resource, error) {
+	var serializedServices []*discovery.Resource
 	for _, exportedService := range exportedServices {
 		serializedExportedService := &anypb.Any{}
-		if err := anypb.MarshalFrom(serializedExportedService, exportedService, proto.MarshalOptions{}); err != nil {
-			return []*anypb.Any{}, fmt.Errorf("failed to serialize ExportedService %s to protobuf message: %w", exportedService.Hostname, err)
+		if err := anypb.MarshalFrom(serializedExportedService, exportedService, proto.MarshalOptions{Deterministic: true}); err != nil {
+			return []*discovery.Resource{}, fmt.Errorf("failed to serialize ExportedService %s to protobuf message: %w", exportedService.Hostname, err)
 		}
-		serializedServices = append(serializedServices, serializedExportedService)
+		serializedServices = append(serializedServices, &discovery.Resource{
+			Name:     exportedService.Hostname,
+			Resource: serializedExportedService,
+		})
 	}
 	return serializedServices, nil
 }
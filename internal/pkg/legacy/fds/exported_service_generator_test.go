@@ -18,16 +18,17 @@ import (
 	"reflect"
 	"testing"
 
-	"golang.org/x/net/context"
-	"google.golang.org/protobuf/types/known/anypb"
+	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
 	corev1 "k8s.io/api/core/v1"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/informers"
 	"k8s.io/client-go/kubernetes/fake"
 
 	"github.com/openshift-service-mesh/federation/internal/api/federation/v1alpha1"
 	"github.com/openshift-service-mesh/federation/internal/pkg/config"
 	"github.com/openshift-service-mesh/federation/internal/pkg/legacy/informer"
+	"github.com/openshift-service-mesh/federation/internal/pkg/legacy/xds/adss"
 )
 
 var (
@@ -88,6 +89,9 @@ var (
 func TestNewExportedServicesGenerator(t *testing.T) {
 	testCases := []struct {
 		name                     string
+		exportRules              []config.Rules
+		subscriber               adss.Identity
+		existingNamespaces       []*corev1.Namespace
 		existingServices         []*corev1.Service
 		expectedExportedServices []*v1alpha1.FederatedService
 	}{{
@@ -136,21 +140,231 @@ func TestNewExportedServicesGenerator(t *testing.T) {
 				"export": "true",
 			},
 		}},
+	}, {
+		name: "match expressions select services by label set",
+		exportRules: []config.Rules{{
+			Type: config.LabelSelectorRule,
+			LabelSelectors: []config.LabelSelectors{{
+				MatchExpressions: []config.MatchExpressions{{
+					Key:      "tier",
+					Operator: "In",
+					Values:   []string{"public", "partner"},
+				}, {
+					Key:      "internal",
+					Operator: "DoesNotExist",
+				}},
+			}},
+		}},
+		existingServices: []*corev1.Service{{
+			ObjectMeta: v1.ObjectMeta{
+				Name:      "a",
+				Namespace: "ns1",
+				Labels: map[string]string{
+					"tier": "public",
+				},
+			},
+		}, {
+			ObjectMeta: v1.ObjectMeta{
+				Name:      "b",
+				Namespace: "ns1",
+				Labels: map[string]string{
+					"tier":     "partner",
+					"internal": "true",
+				},
+			},
+		}, {
+			ObjectMeta: v1.ObjectMeta{
+				Name:      "c",
+				Namespace: "ns1",
+				Labels: map[string]string{
+					"tier": "backend",
+				},
+			},
+		}},
+		expectedExportedServices: []*v1alpha1.FederatedService{{
+			Hostname: "a.ns1.svc.cluster.local",
+			Labels: map[string]string{
+				"tier": "public",
+			},
+		}},
+	}, {
+		name: "namespace selector excludes services from sandbox namespaces",
+		exportRules: []config.Rules{{
+			Type: config.LabelSelectorRule,
+			LabelSelectors: []config.LabelSelectors{{
+				MatchLabels: map[string]string{
+					"tier": "public",
+				},
+				NamespaceSelector: &config.NamespaceSelector{
+					MatchExpressions: []config.MatchExpressions{{
+						Key:      "sandbox",
+						Operator: "DoesNotExist",
+					}},
+				},
+			}},
+		}},
+		existingNamespaces: []*corev1.Namespace{{
+			ObjectMeta: v1.ObjectMeta{
+				Name: "prod",
+			},
+		}, {
+			ObjectMeta: v1.ObjectMeta{
+				Name: "dev",
+				Labels: map[string]string{
+					"sandbox": "true",
+				},
+			},
+		}},
+		existingServices: []*corev1.Service{{
+			ObjectMeta: v1.ObjectMeta{
+				Name:      "a",
+				Namespace: "prod",
+				Labels: map[string]string{
+					"tier": "public",
+				},
+			},
+		}, {
+			ObjectMeta: v1.ObjectMeta{
+				Name:      "a",
+				Namespace: "dev",
+				Labels: map[string]string{
+					"tier": "public",
+				},
+			},
+		}, {
+			ObjectMeta: v1.ObjectMeta{
+				Name:      "a",
+				Namespace: "unknown",
+				Labels: map[string]string{
+					"tier": "public",
+				},
+			},
+		}},
+		expectedExportedServices: []*v1alpha1.FederatedService{{
+			Hostname: "a.prod.svc.cluster.local",
+			Labels: map[string]string{
+				"tier": "public",
+			},
+		}},
+	}, {
+		name: "services matched by multiple rules are exported once",
+		exportRules: []config.Rules{{
+			Type: config.LabelSelectorRule,
+			LabelSelectors: []config.LabelSelectors{{
+				MatchLabels: map[string]string{
+					"export": "true",
+				},
+			}},
+		}, {
+			Type: config.ServiceNameRule,
+			ServiceNames: []config.ServiceName{{
+				Name:      "a",
+				Namespace: "ns1",
+			}, {
+				Name:      "b",
+				Namespace: "ns1",
+			}},
+		}, {
+			Type:       config.NamespaceRule,
+			Namespaces: []string{"ns2"},
+		}},
+		existingServices: []*corev1.Service{{
+			ObjectMeta: v1.ObjectMeta{
+				Name:      "a",
+				Namespace: "ns1",
+				Labels: map[string]string{
+					"export": "true",
+				},
+			},
+		}, {
+			ObjectMeta: v1.ObjectMeta{
+				Name:      "b",
+				Namespace: "ns1",
+			},
+		}, {
+			ObjectMeta: v1.ObjectMeta{
+				Name:      "c",
+				Namespace: "ns1",
+			},
+		}, {
+			ObjectMeta: v1.ObjectMeta{
+				Name:      "a",
+				Namespace: "ns2",
+				Labels: map[string]string{
+					"export": "true",
+				},
+			},
+		}},
+		expectedExportedServices: []*v1alpha1.FederatedService{{
+			Hostname: "a.ns1.svc.cluster.local",
+			Labels: map[string]string{
+				"export": "true",
+			},
+		}, {
+			Hostname: "b.ns1.svc.cluster.local",
+		}, {
+			Hostname: "a.ns2.svc.cluster.local",
+			Labels: map[string]string{
+				"export": "true",
+			},
+		}},
+	}, {
+		name: "rules restricted to other peers do not apply to the subscriber",
+		exportRules: []config.Rules{{
+			Type:       config.NamespaceRule,
+			Namespaces: []string{"public"},
+		}, {
+			Type:       config.NamespaceRule,
+			Namespaces: []string{"partner-a"},
+			Peers:      []string{"mesh-a"},
+		}, {
+			Type:       config.NamespaceRule,
+			Namespaces: []string{"partner-b"},
+			Peers:      []string{"spiffe://mesh-b.local/ns/istio-system/sa/federation-controller"},
+		}},
+		subscriber: adss.Identity{
+			NodeID:   "mesh-b",
+			SpiffeID: "spiffe://mesh-b.local/ns/istio-system/sa/federation-controller",
+		},
+		existingServices: []*corev1.Service{{
+			ObjectMeta: v1.ObjectMeta{
+				Name:      "a",
+				Namespace: "public",
+			},
+		}, {
+			ObjectMeta: v1.ObjectMeta{
+				Name:      "a",
+				Namespace: "partner-a",
+			},
+		}, {
+			ObjectMeta: v1.ObjectMeta{
+				Name:      "a",
+				Namespace: "partner-b",
+			},
+		}},
+		expectedExportedServices: []*v1alpha1.FederatedService{{
+			Hostname: "a.public.svc.cluster.local",
+		}, {
+			Hostname: "a.partner-b.svc.cluster.local",
+		}},
 	}}
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			client := fake.NewSimpleClientset()
+			var objects []runtime.Object
+			for _, ns := range tc.existingNamespaces {
+				objects = append(objects, ns)
+			}
+			for _, svc := range tc.existingServices {
+				objects = append(objects, svc)
+			}
+			client := fake.NewSimpleClientset(objects...)
 			informerFactory := informers.NewSharedInformerFactory(client, 0)
 			serviceInformer := informerFactory.Core().V1().Services().Informer()
 			serviceLister := informerFactory.Core().V1().Services().Lister()
+			namespaceLister := informerFactory.Core().V1().Namespaces().Lister()
 			stopCh := make(chan struct{})
 			informerFactory.Start(stopCh)
-
-			for _, svc := range tc.existingServices {
-				if _, err := client.CoreV1().Services(svc.Namespace).Create(context.Background(), svc, v1.CreateOptions{}); err != nil {
-					t.Fatalf("failed to create service %s/%s: %v", svc.Name, svc.Namespace, err)
-				}
-			}
+			informerFactory.WaitForCacheSync(stopCh)
 
 			serviceController, err := informer.NewResourceController(serviceInformer, corev1.Service{})
 			if err != nil {
@@ -158,9 +372,15 @@ func TestNewExportedServicesGenerator(t *testing.T) {
 			}
 			serviceController.RunAndWait(stopCh)
 
-			generator := NewExportedServicesGenerator(federationConfig, serviceLister)
+			cfg := federationConfig
+			if tc.exportRules != nil {
+				cfg.ExportedServiceSet = config.ExportedServiceSet{
+					Rules: tc.exportRules,
+				}
+			}
+			generator := NewExportedServicesGenerator(cfg, serviceLister, namespaceLister)
 
-			resources, err := generator.GenerateResponse()
+			resources, err := generator.GenerateResponse(tc.subscriber)
 			if err != nil {
 				t.Fatalf("error generating response: %v", err)
 			}
@@ -186,14 +406,17 @@ func TestNewExportedServicesGenerator(t *testing.T) {
 	}
 }
 
-func deserializeExportedServices(t *testing.T, resources []*anypb.Any) []*v1alpha1.FederatedService {
+func deserializeExportedServices(t *testing.T, resources []*discovery.Resource) []*v1alpha1.FederatedService {
 	t.Helper()
 	var out []*v1alpha1.FederatedService
 	for _, res := range resources {
 		var exportedService v1alpha1.FederatedService
-		if err := res.UnmarshalTo(&exportedService); err != nil {
+		if err := res.GetResource().UnmarshalTo(&exportedService); err != nil {
 			t.Errorf("failed to deserialize XDS resource: %v", err)
 		}
+		if res.GetName() != exportedService.Hostname {
+			t.Errorf("expected resource name %s but got %s", exportedService.Hostname, res.GetName())
+		}
 		out = append(out, &exportedService)
 	}
 	return out
@@ -16,26 +16,48 @@ package fds
 
 import (
 	"fmt"
+	"sync"
+	"time"
 
 	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/types/known/anypb"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/utils/clock"
 
 	"github.com/openshift-service-mesh/federation/internal/api/federation/v1alpha1"
+	"github.com/openshift-service-mesh/federation/internal/pkg/config"
 	"github.com/openshift-service-mesh/federation/internal/pkg/legacy/xds"
 	"github.com/openshift-service-mesh/federation/internal/pkg/legacy/xds/adsc"
 )
 
 var _ adsc.ResponseHandler = (*ImportedServiceHandler)(nil)
 
+// ImportedServiceHandler stores services received from remote peers, which match import rules.
 type ImportedServiceHandler struct {
 	store        *ImportedServiceStore
+	importRules  []config.Rules
+	importSafety ImportSafety
 	pushRequests chan<- xds.PushRequest
+	clock        clock.PassiveClock
+
+	mu sync.Mutex
+	// withdrawalRejectedSince is the time of the first of consecutive updates, which were rejected,
+	// because they withdrew too many services.
+	withdrawalRejectedSince time.Time
 }
 
-func NewImportedServiceHandler(store *ImportedServiceStore, pushRequests chan<- xds.PushRequest) *ImportedServiceHandler {
+// NewImportedServiceHandler creates a handler, which rejects updates withdrawing more than the maximum share
+// of services imported from a peer, e.g. an empty snapshot sent by a peer which restarted with incomplete state.
+// Such updates are accepted once the peer keeps sending them for longer than the grace period.
+func NewImportedServiceHandler(store *ImportedServiceStore, importedServiceSet config.ImportedServiceSet, importSafety ImportSafety,
+	pushRequests chan<- xds.PushRequest,
+) *ImportedServiceHandler {
 	return &ImportedServiceHandler{
 		store:        store,
+		importRules:  importedServiceSet.Rules,
+		importSafety: importSafety,
 		pushRequests: pushRequests,
+		clock:        clock.RealClock{},
 	}
 }
 
@@ -46,9 +68,24 @@ func (h *ImportedServiceHandler) Handle(source string, resources []*anypb.Any) e
 		if err := proto.Unmarshal(res.Value, exportedService); err != nil {
 			return fmt.Errorf("unable to unmarshal exported service: %w", err)
 		}
+		matched, err := matchImportRules(source, exportedService, h.importRules)
+		if err != nil {
+			return err
+		}
+		if !matched {
+			log.Debugf("skipping service %s exported by %s, because it does not match import rules", exportedService.Hostname, source)
+			continue
+		}
 		importedServices = append(importedServices, exportedService)
 	}
 
+	if err := checkWithdrawals(source, h.store.Hostnames(source), importedServices, h.importSafety.MaxDropRatio); err != nil {
+		if !h.rejectWithdrawal() {
+			return err
+		}
+		log.Warnf("accepting update from %s, which has kept withdrawing services for longer than %s: %v", source, h.importSafety.GracePeriod, err)
+	}
+	h.resetRejectedWithdrawal()
 	h.store.Update(source, importedServices)
 	// TODO: push only if current state != received imported services (this can happen on reconnection)
 	h.pushRequests <- xds.PushRequest{TypeUrl: xds.ServiceEntryTypeUrl}
@@ -56,3 +93,85 @@ func (h *ImportedServiceHandler) Handle(source string, resources []*anypb.Any) e
 	h.pushRequests <- xds.PushRequest{TypeUrl: xds.DestinationRuleTypeUrl}
 	return nil
 }
+
+// rejectWithdrawal records an update rejected for withdrawing too many services and returns true if the peer
+// has been sending such updates for longer than the grace period, so that the withdrawal is accepted.
+func (h *ImportedServiceHandler) rejectWithdrawal() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	now := h.clock.Now()
+	if h.withdrawalRejectedSince.IsZero() {
+		h.withdrawalRejectedSince = now
+	}
+	return h.importSafety.GracePeriod > 0 && now.Sub(h.withdrawalRejectedSince) >= h.importSafety.GracePeriod
+}
+
+func (h *ImportedServiceHandler) resetRejectedWithdrawal() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.withdrawalRejectedSince = time.Time{}
+}
+
+// WithdrawalRejectedSince returns the time since when updates withdrawing too many services have been rejected,
+// or zero time if the last update was accepted.
+func (h *ImportedServiceHandler) WithdrawalRejectedSince() time.Time {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.withdrawalRejectedSince
+}
+
+// matchImportRules returns true if no import rules are defined or the service received from the given source
+// matches any of the rules applicable to that source.
+func matchImportRules(source string, svc *v1alpha1.FederatedService, rules []config.Rules) (bool, error) {
+	if len(rules) == 0 {
+		return true, nil
+	}
+	for _, rule := range rules {
+		if !rule.AppliesTo(source) {
+			continue
+		}
+		switch rule.Type {
+		case config.LabelSelectorRule:
+			for _, selectors := range rule.LabelSelectors {
+				selector, err := selectors.Selector()
+				if err != nil {
+					return false, fmt.Errorf("invalid service selector: %w", err)
+				}
+				if selector.Matches(labels.Set(svc.Labels)) {
+					return true, nil
+				}
+			}
+		case config.HostnameRule:
+			if rule.MatchHostname(svc.Hostname) {
+				return true, nil
+			}
+		default:
+			return false, fmt.Errorf("rule of type %s is not supported for imported services", rule.Type)
+		}
+	}
+	return false, nil
+}
+
+// checkWithdrawals returns an error if received services do not include more than maxDropRatio of current services.
+// Withdrawing a single service is always allowed, so that peers exporting few services can stop exporting them.
+func checkWithdrawals(source string, current []string, received []*v1alpha1.FederatedService, maxDropRatio float64) error {
+	if len(current) == 0 || maxDropRatio >= 1 {
+		return nil
+	}
+	receivedHostnames := make(map[string]struct{}, len(received))
+	for _, svc := range received {
+		receivedHostnames[svc.Hostname] = struct{}{}
+	}
+	dropped := 0
+	for _, hostname := range current {
+		if _, found := receivedHostnames[hostname]; !found {
+			dropped++
+		}
+	}
+	if dropped > 1 && float64(dropped) > maxDropRatio*float64(len(current)) {
+		return fmt.Errorf("refusing to withdraw %d of %d services imported from %s at once, the maximum share is %.2f",
+			dropped, len(current), source, maxDropRatio)
+	}
+	return nil
+}
@@ -0,0 +1,268 @@
+// Copyright Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the License);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an AS IS BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fds
+
+import (
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/types/known/anypb"
+	testingclock "k8s.io/utils/clock/testing"
+
+	"github.com/openshift-service-mesh/federation/internal/api/federation/v1alpha1"
+	"github.com/openshift-service-mesh/federation/internal/pkg/config"
+	"github.com/openshift-service-mesh/federation/internal/pkg/legacy/xds"
+)
+
+func TestImportedServiceHandler(t *testing.T) {
+	exportedServices := []*v1alpha1.FederatedService{{
+		Hostname: "ratings.bookinfo.svc.cluster.local",
+		Labels:   map[string]string{"tier": "public"},
+	}, {
+		Hostname: "reviews.bookinfo.svc.cluster.local",
+	}, {
+		Hostname: "payments.billing.svc.cluster.local",
+		Labels:   map[string]string{"tier": "internal"},
+	}}
+
+	testCases := []struct {
+		name              string
+		source            string
+		importRules       []config.Rules
+		expectedHostnames []string
+	}{{
+		name:   "all services are imported if no rules are defined",
+		source: "west",
+		expectedHostnames: []string{
+			"ratings.bookinfo.svc.cluster.local",
+			"reviews.bookinfo.svc.cluster.local",
+			"payments.billing.svc.cluster.local",
+		},
+	}, {
+		name:   "services are imported if they match any rule",
+		source: "west",
+		importRules: []config.Rules{{
+			Type: config.LabelSelectorRule,
+			LabelSelectors: []config.LabelSelectors{{
+				MatchLabels: map[string]string{"tier": "public"},
+			}},
+		}, {
+			Type:      config.HostnameRule,
+			Hostnames: []string{"*.billing.svc.cluster.local"},
+		}},
+		expectedHostnames: []string{
+			"ratings.bookinfo.svc.cluster.local",
+			"payments.billing.svc.cluster.local",
+		},
+	}, {
+		name:   "rules restricted to other peers are ignored",
+		source: "east",
+		importRules: []config.Rules{{
+			Type:      config.HostnameRule,
+			Hostnames: []string{"*.bookinfo.svc.cluster.local"},
+			Peers:     []string{"east"},
+		}, {
+			Type:      config.HostnameRule,
+			Hostnames: []string{"*"},
+			Peers:     []string{"west"},
+		}},
+		expectedHostnames: []string{
+			"ratings.bookinfo.svc.cluster.local",
+			"reviews.bookinfo.svc.cluster.local",
+		},
+	}}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			store := NewImportedServiceStore()
+			pushRequests := make(chan xds.PushRequest, 3)
+			handler := NewImportedServiceHandler(store, config.ImportedServiceSet{Rules: tc.importRules}, ImportSafety{MaxDropRatio: 1}, pushRequests)
+
+			var resources []*anypb.Any
+			for _, svc := range exportedServices {
+				res, err := anypb.New(svc)
+				if err != nil {
+					t.Fatalf("failed to serialize exported service: %v", err)
+				}
+				resources = append(resources, res)
+			}
+
+			if err := handler.Handle(tc.source, resources); err != nil {
+				t.Fatalf("failed to handle exported services: %v", err)
+			}
+
+			imported := store.From(config.Remote{Name: tc.source})
+			if len(imported) != len(tc.expectedHostnames) {
+				t.Fatalf("expected %d imported services but got %d", len(tc.expectedHostnames), len(imported))
+			}
+			for idx, svc := range imported {
+				if svc.Hostname != tc.expectedHostnames[idx] {
+					t.Errorf("expected imported service %s but got %s", tc.expectedHostnames[idx], svc.Hostname)
+				}
+			}
+		})
+	}
+}
+
+func TestCheckWithdrawals(t *testing.T) {
+	current := []string{
+		"ratings.bookinfo.svc.cluster.local",
+		"reviews.bookinfo.svc.cluster.local",
+		"details.bookinfo.svc.cluster.local",
+		"payments.billing.svc.cluster.local",
+	}
+
+	testCases := []struct {
+		name         string
+		current      []string
+		received     []string
+		maxDropRatio float64
+		expectErr    bool
+	}{{
+		name:         "withdrawing services up to the maximum share is allowed",
+		current:      current,
+		received:     []string{"ratings.bookinfo.svc.cluster.local", "reviews.bookinfo.svc.cluster.local"},
+		maxDropRatio: 0.5,
+	}, {
+		name:         "withdrawing more than the maximum share is rejected",
+		current:      current,
+		received:     []string{"ratings.bookinfo.svc.cluster.local"},
+		maxDropRatio: 0.5,
+		expectErr:    true,
+	}, {
+		name:         "empty snapshot is rejected",
+		current:      current,
+		maxDropRatio: 0.5,
+		expectErr:    true,
+	}, {
+		name:         "withdrawing a single service is always allowed",
+		current:      current[:1],
+		maxDropRatio: 0.5,
+	}, {
+		name:         "check is disabled with the maximum share of 1",
+		current:      current,
+		maxDropRatio: 1,
+	}, {
+		name:         "first snapshot is always accepted",
+		received:     []string{"ratings.bookinfo.svc.cluster.local"},
+		maxDropRatio: 0,
+	}}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var received []*v1alpha1.FederatedService
+			for _, hostname := range tc.received {
+				received = append(received, &v1alpha1.FederatedService{Hostname: hostname})
+			}
+
+			err := checkWithdrawals("west", tc.current, received, tc.maxDropRatio)
+			if tc.expectErr && err == nil {
+				t.Errorf("expected error but got nil")
+			}
+			if !tc.expectErr && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestRejectedWithdrawalIsAcceptedAfterGracePeriod(t *testing.T) {
+	current := []string{
+		"ratings.bookinfo.svc.cluster.local",
+		"reviews.bookinfo.svc.cluster.local",
+		"details.bookinfo.svc.cluster.local",
+	}
+
+	type update struct {
+		// after is the time elapsed since the previous update.
+		after     time.Duration
+		received  []string
+		expectErr bool
+	}
+	testCases := []struct {
+		name        string
+		gracePeriod time.Duration
+		updates     []update
+	}{{
+		name:        "withdrawal is accepted once the peer keeps sending it for the grace period",
+		gracePeriod: 5 * time.Minute,
+		updates: []update{
+			{expectErr: true},
+			{after: 4 * time.Minute, expectErr: true},
+			{after: time.Minute},
+		},
+	}, {
+		name:        "accepted update restarts the grace period",
+		gracePeriod: 5 * time.Minute,
+		updates: []update{
+			{expectErr: true},
+			{after: 4 * time.Minute, received: current},
+			{after: time.Minute, expectErr: true},
+			{after: 4 * time.Minute, expectErr: true},
+		},
+	}, {
+		name: "withdrawal is never accepted without grace period",
+		updates: []update{
+			{expectErr: true},
+			{after: time.Hour, expectErr: true},
+		},
+	}}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			store := NewImportedServiceStore()
+			store.Update("west", federatedServices(current...))
+			fakeClock := testingclock.NewFakePassiveClock(time.Now())
+			handler := NewImportedServiceHandler(store, config.ImportedServiceSet{},
+				ImportSafety{GracePeriod: tc.gracePeriod, MaxDropRatio: 0.5}, make(chan xds.PushRequest, 3*len(tc.updates)))
+			handler.clock = fakeClock
+
+			var rejectedSince time.Time
+			for i, u := range tc.updates {
+				fakeClock.SetTime(fakeClock.Now().Add(u.after))
+				var resources []*anypb.Any
+				for _, svc := range federatedServices(u.received...) {
+					res, err := anypb.New(svc)
+					if err != nil {
+						t.Fatalf("failed to serialize exported service: %v", err)
+					}
+					resources = append(resources, res)
+				}
+
+				err := handler.Handle("west", resources)
+				if u.expectErr != (err != nil) {
+					t.Fatalf("update %d: expected error: %t, got: %v", i, u.expectErr, err)
+				}
+				if u.expectErr && rejectedSince.IsZero() {
+					rejectedSince = fakeClock.Now()
+				}
+				if !u.expectErr {
+					rejectedSince = time.Time{}
+				}
+				if since := handler.WithdrawalRejectedSince(); !since.Equal(rejectedSince) {
+					t.Errorf("update %d: expected withdrawal rejected since %v, got %v", i, rejectedSince, since)
+				}
+				if expected := len(u.received); !u.expectErr && len(store.Hostnames("west")) != expected {
+					t.Errorf("update %d: expected %d imported services, got %d", i, expected, len(store.Hostnames("west")))
+				}
+			}
+		})
+	}
+}
+
+func federatedServices(hostnames ...string) []*v1alpha1.FederatedService {
+	services := make([]*v1alpha1.FederatedService, 0, len(hostnames))
+	for _, hostname := range hostnames {
+		services = append(services, &v1alpha1.FederatedService{Hostname: hostname})
+	}
+	return services
+}
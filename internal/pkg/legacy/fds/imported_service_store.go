@@ -15,7 +15,9 @@
 package fds
 
 import (
+	"sort"
 	"sync"
+	"time"
 
 	"github.com/openshift-service-mesh/federation/internal/api/federation/v1alpha1"
 	"github.com/openshift-service-mesh/federation/internal/pkg/config"
@@ -25,14 +27,25 @@ import (
 type ImportedServiceStore struct {
 	mu               sync.RWMutex
 	importedServices map[string][]*v1alpha1.FederatedService
+	// syncTimes stores the time of the last update by source.
+	syncTimes map[string]time.Time
+	// restored contains sources restored from a checkpoint, which have not been updated since.
+	restored map[string]struct{}
+	// generation is incremented on every change, so that changes can be detected without comparing services.
+	generation uint64
+	// subscribers are notified after every change.
+	subscribers []chan struct{}
 }
 
 func NewImportedServiceStore() *ImportedServiceStore {
 	return &ImportedServiceStore{
 		importedServices: make(map[string][]*v1alpha1.FederatedService),
+		syncTimes:        make(map[string]time.Time),
+		restored:         make(map[string]struct{}),
 	}
 }
 
+// Update replaces services imported from given source and records the time of the update.
 func (s *ImportedServiceStore) Update(source string, importedServices []*v1alpha1.FederatedService) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -43,6 +56,84 @@ func (s *ImportedServiceStore) Update(source string, importedServices []*v1alpha
 	}
 
 	s.importedServices[source] = newImportedServices
+	s.syncTimes[source] = time.Now()
+	delete(s.restored, source)
+	s.generation++
+	s.notify()
+}
+
+// Restore sets services imported from given source before the restart, unless the source was already updated.
+func (s *ImportedServiceStore) Restore(source string, importedServices []*v1alpha1.FederatedService, syncTime time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, found := s.importedServices[source]; found {
+		return
+	}
+	s.importedServices[source] = importedServices
+	s.syncTimes[source] = syncTime
+	s.restored[source] = struct{}{}
+	s.generation++
+	s.notify()
+}
+
+// AwaitingSync returns sorted sources restored from a checkpoint, which have not been updated since.
+func (s *ImportedServiceStore) AwaitingSync() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	sources := make([]string, 0, len(s.restored))
+	for source := range s.restored {
+		sources = append(sources, source)
+	}
+	sort.Strings(sources)
+	return sources
+}
+
+// Sources returns sorted sources of imported services.
+func (s *ImportedServiceStore) Sources() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	sources := make([]string, 0, len(s.importedServices))
+	for source := range s.importedServices {
+		sources = append(sources, source)
+	}
+	sort.Strings(sources)
+	return sources
+}
+
+// Remove deletes all services imported from given source.
+func (s *ImportedServiceStore) Remove(source string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.importedServices, source)
+	delete(s.syncTimes, source)
+	delete(s.restored, source)
+	s.generation++
+	s.notify()
+}
+
+// SyncTime returns the time when services imported from given source were updated for the last time,
+// or zero time if there are no services from that source.
+func (s *ImportedServiceStore) SyncTime(source string) time.Time {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.syncTimes[source]
+}
+
+// Hostnames returns hostnames of services imported from given source.
+func (s *ImportedServiceStore) Hostnames(source string) []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	hostnames := make([]string, 0, len(s.importedServices[source]))
+	for _, svc := range s.importedServices[source] {
+		hostnames = append(hostnames, svc.Hostname)
+	}
+	return hostnames
 }
 
 // From returns copy of all services exported from given remote peer.
@@ -57,3 +148,47 @@ func (s *ImportedServiceStore) From(remote config.Remote) []*v1alpha1.FederatedS
 
 	return out
 }
+
+func (s *ImportedServiceStore) currentGeneration() uint64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.generation
+}
+
+// snapshot returns the generation of the store with services and sync times by source.
+// Services are not copied, because they are never modified after they are stored.
+func (s *ImportedServiceStore) snapshot() (uint64, map[string][]*v1alpha1.FederatedService, map[string]time.Time) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	services := make(map[string][]*v1alpha1.FederatedService, len(s.importedServices))
+	syncTimes := make(map[string]time.Time, len(s.syncTimes))
+	for source, sourceServices := range s.importedServices {
+		services[source] = sourceServices
+		syncTimes[source] = s.syncTimes[source]
+	}
+	return s.generation, services, syncTimes
+}
+
+// Subscribe returns a channel, which receives a notification after imported services have changed.
+// Notifications are coalesced, so a subscriber, which has not received the previous notification yet,
+// receives only one notification for all changes since then.
+func (s *ImportedServiceStore) Subscribe() <-chan struct{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ch := make(chan struct{}, 1)
+	s.subscribers = append(s.subscribers, ch)
+	return ch
+}
+
+// notify must be called with the lock held.
+func (s *ImportedServiceStore) notify() {
+	for _, ch := range s.subscribers {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
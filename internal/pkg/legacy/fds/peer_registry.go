@@ -0,0 +1,445 @@
+// Copyright Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the License);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an AS IS BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fds
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	istiolog "istio.io/istio/pkg/log"
+
+	"github.com/openshift-service-mesh/federation/internal/pkg/config"
+	"github.com/openshift-service-mesh/federation/internal/pkg/legacy/xds"
+	"github.com/openshift-service-mesh/federation/internal/pkg/legacy/xds/adsc"
+	"github.com/openshift-service-mesh/federation/internal/pkg/legacy/xds/adss"
+	"github.com/openshift-service-mesh/federation/internal/pkg/mtls"
+	"github.com/openshift-service-mesh/federation/internal/pkg/networking"
+)
+
+var (
+	log = istiolog.RegisterScope("fds", "Federation Discovery Service")
+
+	_ config.RemoteLister = (*PeerRegistry)(nil)
+)
+
+// PeerStatus describes the health of the FDS connection to a remote peer.
+type PeerStatus struct {
+	Name             string
+	State            adsc.ConnectionState
+	LastSyncTime     time.Time
+	ImportedServices int
+	LastError        error
+	// WithdrawalRejectedSince is the time since when updates of the peer have been rejected, because they withdrew
+	// too many services at once. It is zero if the last update was accepted.
+	WithdrawalRejectedSince time.Time
+	// Subscribers is the number of streams, over which the peer subscribes to services exported to it.
+	Subscribers int
+	// ExportError describes why the peer does not have the current exported services: it rejected the last response,
+	// or the services could not be generated and the peer is served the last known-good snapshot.
+	ExportError string
+}
+
+// ExportStatusSource reports the state of subscribers of exported services.
+type ExportStatusSource interface {
+	Subscribers() []adss.SubscriberStatus
+}
+
+// Connected returns true if services were received from the peer and no error occurred since then.
+func (s PeerStatus) Connected() bool {
+	return s.State == adsc.Synced
+}
+
+// ImportSafety limits withdrawing services imported from remote peers, which are temporarily unreachable
+// or send incomplete snapshots.
+type ImportSafety struct {
+	// GracePeriod is how long services imported from a disconnected peer are kept before they are withdrawn,
+	// and how long a peer must keep withdrawing more than MaxDropRatio of its services before the withdrawal is accepted.
+	// Zero keeps them until the peer is removed.
+	GracePeriod time.Duration
+	// MaxDropRatio is the maximum share of services imported from a peer, which can be withdrawn by a single update.
+	// Updates withdrawing more services are rejected. The value of 1 disables the check.
+	MaxDropRatio float64
+}
+
+// staleImportsCheckInterval is how often services imported from disconnected peers are checked for expiration.
+const staleImportsCheckInterval = 10 * time.Second
+
+type peer struct {
+	remote  config.Remote
+	client  *adsc.ADSC
+	handler *ImportedServiceHandler
+	cancel  context.CancelFunc
+}
+
+// PeerRegistry is a thread-safe registry of remote peers, which manages FDS clients connected to them.
+// Peers can be added, updated and removed at runtime.
+type PeerRegistry struct {
+	mu                     sync.RWMutex
+	peers                  map[string]*peer
+	localName              string
+	importedServiceStore   *ImportedServiceStore
+	importedServiceSet     config.ImportedServiceSet
+	meshConfigPushRequests chan<- xds.PushRequest
+	reconnectDelay         time.Duration
+	importSafety           ImportSafety
+	// credentials are nil if FDS connections are secured by the sidecar.
+	credentials *mtls.Credentials
+	// deletionsAllowed is set once all peers have synced after start, or the initial sync timed out.
+	deletionsAllowed atomic.Bool
+	// exports is nil until the FDS server is started.
+	exports ExportStatusSource
+}
+
+// NewPeerRegistry creates a registry of FDS clients, which identify themselves to remote peers with the local mesh name.
+// If credentials are not nil, FDS clients connect directly to ingress gateways of remote peers over mTLS.
+func NewPeerRegistry(localName string, importedServiceStore *ImportedServiceStore, importedServiceSet config.ImportedServiceSet,
+	meshConfigPushRequests chan<- xds.PushRequest, reconnectDelay time.Duration, importSafety ImportSafety, credentials *mtls.Credentials,
+) *PeerRegistry {
+	return &PeerRegistry{
+		peers:                  make(map[string]*peer),
+		localName:              localName,
+		importedServiceStore:   importedServiceStore,
+		importedServiceSet:     importedServiceSet,
+		meshConfigPushRequests: meshConfigPushRequests,
+		reconnectDelay:         reconnectDelay,
+		importSafety:           importSafety,
+		credentials:            credentials,
+	}
+}
+
+// Start connects FDS client to the given remote peer. If the peer is already registered with a different configuration,
+// the existing client is stopped and a new one is started. Registering the same configuration again is a no-op.
+func (r *PeerRegistry) Start(ctx context.Context, remote config.Remote) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if existing, found := r.peers[remote.Name]; found {
+		if reflect.DeepEqual(existing.remote, remote) {
+			return nil
+		}
+		log.Infof("configuration of peer %s has changed, restarting FDS client", remote.Name)
+		r.stop(existing)
+	}
+
+	var discoveryAddr string
+	var tlsConfig *tls.Config
+	switch {
+	case r.credentials != nil:
+		discoveryAddr = fmt.Sprintf("%s:%d", remote.Addresses[0], remote.GetPort())
+		tlsConfig = r.clientTLSConfig(remote)
+	case networking.IsIP(remote.Addresses[0]):
+		discoveryAddr = fmt.Sprintf("%s:%d", remote.ServiceFQDN(), remote.ServicePort())
+	default:
+		discoveryAddr = fmt.Sprintf("%s:%d", remote.Addresses[0], remote.ServicePort())
+	}
+
+	handler := NewImportedServiceHandler(r.importedServiceStore, r.importedServiceSet, r.importSafety, r.meshConfigPushRequests)
+	fdsClient, errClient := adsc.New(&adsc.ADSCConfig{
+		RemoteName:    remote.Name,
+		NodeID:        r.localName,
+		DiscoveryAddr: discoveryAddr,
+		Authority:     remote.ServiceFQDN(),
+		Handlers: map[string]adsc.ResponseHandler{
+			xds.ExportedServiceTypeUrl: handler,
+		},
+		ReconnectDelay: r.reconnectDelay,
+		TLSConfig:      tlsConfig,
+	})
+	if errClient != nil {
+		return fmt.Errorf("failed to create FDS client for peer %s: %w", remote.Name, errClient)
+	}
+
+	clientCtx, cancel := context.WithCancel(ctx)
+	r.peers[remote.Name] = &peer{
+		remote:  remote,
+		client:  fdsClient,
+		handler: handler,
+		cancel:  cancel,
+	}
+
+	go func() {
+		// Generate resources required to connect to the new peer, e.g. ServiceEntry for the remote discovery service.
+		r.pushMeshConfig(clientCtx)
+
+		if errRun := fdsClient.Run(clientCtx); errRun != nil {
+			log.Errorf("failed to run FDS client for peer %s: %v", remote.Name, errRun)
+		}
+	}()
+
+	return nil
+}
+
+// Stop disconnects FDS client from the given peer and withdraws services imported from it.
+func (r *PeerRegistry) Stop(ctx context.Context, name string) {
+	r.mu.Lock()
+	existing, found := r.peers[name]
+	if found {
+		r.stop(existing)
+		delete(r.peers, name)
+	}
+	r.mu.Unlock()
+
+	if found {
+		r.importedServiceStore.Remove(name)
+		r.pushMeshConfig(ctx)
+	}
+}
+
+// clientTLSConfig returns TLS configuration, which authorizes the discovery server of the remote peer
+// by the configured subject alternative names, or by its trust domain if no names are configured.
+func (r *PeerRegistry) clientTLSConfig(remote config.Remote) *tls.Config {
+	authorize := mtls.AuthorizeSubjectAltNames(remote.SubjectAltNames...)
+	if len(remote.SubjectAltNames) == 0 {
+		trustDomain := remote.GetTrustDomain(r.credentials.TrustDomain())
+		authorize = mtls.AuthorizeTrustDomains(func() []string {
+			return []string{trustDomain}
+		})
+	}
+	tlsConfig := r.credentials.ClientConfig(authorize)
+	tlsConfig.ServerName = remote.DiscoverySNI()
+	return tlsConfig
+}
+
+// TrustDomains returns the local trust domain and trust domains of all registered peers.
+// It returns nil if FDS connections are secured by the sidecar.
+func (r *PeerRegistry) TrustDomains() []string {
+	if r.credentials == nil {
+		return nil
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	trustDomains := []string{r.credentials.TrustDomain()}
+	for _, p := range r.peers {
+		trustDomains = append(trustDomains, p.remote.GetTrustDomain(r.credentials.TrustDomain()))
+	}
+	return trustDomains
+}
+
+func (r *PeerRegistry) stop(p *peer) {
+	p.cancel()
+	if err := p.client.Close(); err != nil {
+		log.Errorf("failed to close connection to peer %s: %v", p.remote.Name, err)
+	}
+}
+
+// DeletionsAllowed returns true if Istio configs, which are no longer generated for imported services, can be deleted.
+// Deletions are postponed after start, so that configs for services imported before a restart are not deleted
+// before the peers are connected again.
+func (r *PeerRegistry) DeletionsAllowed() bool {
+	return r.deletionsAllowed.Load()
+}
+
+// AwaitInitialSync allows deletions once all registered peers and peers restored from the checkpoint have synced,
+// or when the timeout has passed. Services restored for peers, which are not registered by then, are withdrawn,
+// and Istio configs are reconciled afterward to delete the stale ones.
+func (r *PeerRegistry) AwaitInitialSync(ctx context.Context, timeout time.Duration) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	deadline := time.After(timeout)
+
+loop:
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-deadline:
+			log.Warnf("not all peers have synced within %s, allowing deletion of stale configs", timeout)
+			break loop
+		case <-ticker.C:
+			if r.initiallySynced() {
+				log.Infof("all peers have synced, allowing deletion of stale configs")
+				break loop
+			}
+		}
+	}
+	r.withdrawUnknownImports()
+	r.deletionsAllowed.Store(true)
+	r.pushMeshConfig(ctx)
+}
+
+func (r *PeerRegistry) initiallySynced() bool {
+	if awaiting := r.importedServiceStore.AwaitingSync(); len(awaiting) > 0 {
+		log.Debugf("waiting for peers restored from the checkpoint to sync: %v", awaiting)
+		return false
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for name, p := range r.peers {
+		if p.client.Status().LastSyncTime.IsZero() {
+			log.Debugf("waiting for peer %s to sync", name)
+			return false
+		}
+	}
+	return true
+}
+
+// withdrawUnknownImports withdraws services imported from sources, which are not registered as peers,
+// e.g. services restored from the checkpoint for peers removed from the configuration during a restart.
+func (r *PeerRegistry) withdrawUnknownImports() {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, source := range r.importedServiceStore.Sources() {
+		if _, found := r.peers[source]; !found {
+			log.Infof("withdrawing services imported from %s, which is not a registered peer", source)
+			r.importedServiceStore.Remove(source)
+		}
+	}
+}
+
+// WithdrawStaleImports periodically withdraws services imported from peers, which have been disconnected
+// for longer than the grace period. It blocks until the context is done.
+func (r *PeerRegistry) WithdrawStaleImports(ctx context.Context) {
+	if r.importSafety.GracePeriod <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(staleImportsCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if r.withdrawStaleImports() {
+				r.pushMeshConfig(ctx)
+			}
+		}
+	}
+}
+
+// withdrawStaleImports returns true if services imported from any peer were withdrawn.
+func (r *PeerRegistry) withdrawStaleImports() bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	withdrawn := false
+	for name, p := range r.peers {
+		disconnectedSince := p.client.Status().DisconnectedSince
+		if disconnectedSince.IsZero() || time.Since(disconnectedSince) < r.importSafety.GracePeriod {
+			continue
+		}
+		if syncTime := r.importedServiceStore.SyncTime(name); !syncTime.IsZero() {
+			log.Warnf("withdrawing services imported from peer %s, which has been disconnected since %s (last synced at %s)",
+				name, disconnectedSince.Format(time.RFC3339), syncTime.Format(time.RFC3339))
+			r.importedServiceStore.Remove(name)
+			withdrawn = true
+		}
+	}
+	return withdrawn
+}
+
+func (r *PeerRegistry) pushMeshConfig(ctx context.Context) {
+	for _, typeUrl := range []string{xds.ServiceEntryTypeUrl, xds.WorkloadEntryTypeUrl, xds.DestinationRuleTypeUrl} {
+		select {
+		case r.meshConfigPushRequests <- xds.PushRequest{TypeUrl: typeUrl}:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Remote returns the configuration of the given peer.
+func (r *PeerRegistry) Remote(name string) (config.Remote, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if p, found := r.peers[name]; found {
+		return p.remote, true
+	}
+	return config.Remote{}, false
+}
+
+// Remotes returns configuration of all registered peers sorted by name.
+func (r *PeerRegistry) Remotes() []config.Remote {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	remotes := make([]config.Remote, 0, len(r.peers))
+	for _, p := range r.peers {
+		remotes = append(remotes, p.remote)
+	}
+	sort.Slice(remotes, func(i, j int) bool {
+		return remotes[i].Name < remotes[j].Name
+	})
+
+	return remotes
+}
+
+// exportError returns the reason why the subscriber does not have the current exported services, if any.
+func exportError(status adss.TypeStatus) string {
+	if status.GenerationError != nil {
+		return fmt.Sprintf("serving the last known-good snapshot of exported services: %v", status.GenerationError)
+	}
+	if status.Rejected() {
+		return fmt.Sprintf("version %s of exported services rejected: %s", status.NackedVersion, status.ErrorDetail)
+	}
+	return ""
+}
+
+// ReportExports sets the source of the state of peers subscribed to exported services, which is included in peer statuses.
+func (r *PeerRegistry) ReportExports(exports ExportStatusSource) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.exports = exports
+}
+
+// Statuses returns the status of connections to all registered peers sorted by peer name.
+func (r *PeerRegistry) Statuses() []PeerStatus {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var subscribers []adss.SubscriberStatus
+	if r.exports != nil {
+		subscribers = r.exports.Subscribers()
+	}
+	statuses := make([]PeerStatus, 0, len(r.peers))
+	for name, p := range r.peers {
+		clientStatus := p.client.Status()
+		status := PeerStatus{
+			Name:                    name,
+			State:                   clientStatus.State,
+			LastSyncTime:            clientStatus.LastSyncTime,
+			ImportedServices:        len(r.importedServiceStore.From(p.remote)),
+			LastError:               clientStatus.LastError,
+			WithdrawalRejectedSince: p.handler.WithdrawalRejectedSince(),
+		}
+		for _, subscriber := range subscribers {
+			if subscriber.Identity.NodeID != name {
+				continue
+			}
+			status.Subscribers++
+			if exportError := exportError(subscriber.Types[xds.ExportedServiceTypeUrl]); exportError != "" {
+				status.ExportError = exportError
+			}
+		}
+		statuses = append(statuses, status)
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Name < statuses[j].Name
+	})
+
+	return statuses
+}
@@ -0,0 +1,125 @@
+// Copyright Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the License);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an AS IS BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fds
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/openshift-service-mesh/federation/internal/api/federation/v1alpha1"
+	"github.com/openshift-service-mesh/federation/internal/pkg/config"
+	"github.com/openshift-service-mesh/federation/internal/pkg/legacy/xds"
+	"github.com/openshift-service-mesh/federation/internal/pkg/legacy/xds/adss"
+)
+
+func TestAwaitInitialSyncWithdrawsServicesOfUnknownPeers(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	store := NewImportedServiceStore()
+	restored := []*v1alpha1.FederatedService{{Hostname: "ratings.bookinfo.svc.cluster.local"}}
+	store.Restore("east", restored, time.Now())
+	store.Restore("removed", restored, time.Now())
+
+	pushRequests := make(chan xds.PushRequest, 10)
+	registry := NewPeerRegistry("west", store, config.ImportedServiceSet{}, pushRequests, time.Second, ImportSafety{MaxDropRatio: 1}, nil)
+	// The peer is not reachable, so the initial sync times out.
+	if err := registry.Start(ctx, config.Remote{Name: "east", Addresses: []string{"192.0.2.1"}}); err != nil {
+		t.Fatalf("failed to start peer: %v", err)
+	}
+	defer registry.Stop(ctx, "east")
+
+	registry.AwaitInitialSync(ctx, 10*time.Millisecond)
+
+	if !registry.DeletionsAllowed() {
+		t.Error("expected deletions to be allowed after the initial sync timed out")
+	}
+	if sources := store.Sources(); len(sources) != 1 || sources[0] != "east" {
+		t.Errorf("expected services restored only for the registered peer, got %v", sources)
+	}
+}
+
+type fakeExportStatusSource []adss.SubscriberStatus
+
+func (f fakeExportStatusSource) Subscribers() []adss.SubscriberStatus {
+	return f
+}
+
+func TestStatusesReportExportsOfPeer(t *testing.T) {
+	exported := func(typeStatus adss.TypeStatus) map[string]adss.TypeStatus {
+		return map[string]adss.TypeStatus{xds.ExportedServiceTypeUrl: typeStatus}
+	}
+	testCases := []struct {
+		name                string
+		subscribers         []adss.SubscriberStatus
+		expectedSubscribers int
+		expectedExportError string
+	}{{
+		name: "accepted",
+		subscribers: []adss.SubscriberStatus{
+			{ID: 1, Identity: adss.Identity{NodeID: "east"}, Types: exported(adss.TypeStatus{SentVersion: "2", AckedVersion: "2"})},
+			{ID: 2, Identity: adss.Identity{NodeID: "other"}, Types: exported(adss.TypeStatus{SentVersion: "2", NackedVersion: "2"})},
+		},
+		expectedSubscribers: 1,
+	}, {
+		name: "previously rejected version was superseded",
+		subscribers: []adss.SubscriberStatus{
+			{ID: 1, Identity: adss.Identity{NodeID: "east"}, Types: exported(adss.TypeStatus{SentVersion: "3", AckedVersion: "3", NackedVersion: "2"})},
+		},
+		expectedSubscribers: 1,
+	}, {
+		name: "rejected",
+		subscribers: []adss.SubscriberStatus{
+			{ID: 1, Identity: adss.Identity{NodeID: "east"}, Types: exported(adss.TypeStatus{SentVersion: "2", AckedVersion: "2"})},
+			{ID: 2, Identity: adss.Identity{NodeID: "east"}, Types: exported(adss.TypeStatus{SentVersion: "2", NackedVersion: "2", ErrorDetail: "invalid"})},
+		},
+		expectedSubscribers: 2,
+		expectedExportError: "version 2 of exported services rejected: invalid",
+	}, {
+		name: "generation failed",
+		subscribers: []adss.SubscriberStatus{
+			{ID: 1, Identity: adss.Identity{NodeID: "east"}, Types: exported(adss.TypeStatus{SentVersion: "2", GenerationError: errors.New("lister not synced")})},
+		},
+		expectedSubscribers: 1,
+		expectedExportError: "serving the last known-good snapshot of exported services: lister not synced",
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			registry := NewPeerRegistry("west", NewImportedServiceStore(), config.ImportedServiceSet{}, make(chan xds.PushRequest, 10), time.Second, ImportSafety{MaxDropRatio: 1}, nil)
+			if err := registry.Start(ctx, config.Remote{Name: "east", Addresses: []string{"192.0.2.1"}}); err != nil {
+				t.Fatalf("failed to start peer: %v", err)
+			}
+			defer registry.Stop(ctx, "east")
+			registry.ReportExports(fakeExportStatusSource(tc.subscribers))
+
+			statuses := registry.Statuses()
+			if len(statuses) != 1 {
+				t.Fatalf("expected status of 1 peer, got %d", len(statuses))
+			}
+			if statuses[0].Subscribers != tc.expectedSubscribers {
+				t.Errorf("expected %d subscribers, got %d", tc.expectedSubscribers, statuses[0].Subscribers)
+			}
+			if statuses[0].ExportError != tc.expectedExportError {
+				t.Errorf("expected export error %q, got %q", tc.expectedExportError, statuses[0].ExportError)
+			}
+		})
+	}
+}
@@ -0,0 +1,44 @@
+// Copyright Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the License);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an AS IS BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fds
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/openshift-service-mesh/federation/internal/pkg/config"
+	"github.com/openshift-service-mesh/federation/internal/pkg/legacy/xds/adss"
+)
+
+// NewSubscriberAuthorizer returns an authorizer, which allows a subscriber to receive exported services only if
+// it identifies itself with the name of a configured remote peer, and its SPIFFE ID is allowed for that peer.
+// This way, export rules restricted to a peer cannot be bypassed by sending the name of another peer.
+func NewSubscriberAuthorizer(remotes config.RemoteLister, localTrustDomain string) adss.Authorizer {
+	return func(identity adss.Identity) error {
+		if identity.SpiffeID == "" {
+			return errors.New("subscriber has no SPIFFE ID")
+		}
+		for _, remote := range remotes.Remotes() {
+			if remote.Name != identity.NodeID {
+				continue
+			}
+			if !remote.AllowsIdentity(identity.SpiffeID, localTrustDomain) {
+				return fmt.Errorf("SPIFFE ID %s is not allowed for remote peer %s", identity.SpiffeID, remote.Name)
+			}
+			return nil
+		}
+		return fmt.Errorf("remote peer %q is not configured", identity.NodeID)
+	}
+}
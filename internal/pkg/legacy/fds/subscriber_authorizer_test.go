@@ -0,0 +1,92 @@
+// Copyright Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the License);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an AS IS BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fds
+
+import (
+	"testing"
+
+	"github.com/openshift-service-mesh/federation/internal/pkg/config"
+	"github.com/openshift-service-mesh/federation/internal/pkg/legacy/xds/adss"
+)
+
+func TestSubscriberAuthorizer(t *testing.T) {
+	authorize := NewSubscriberAuthorizer(config.StaticRemotes{{
+		Name: "west",
+	}, {
+		Name:              "central",
+		AllowedIdentities: []string{"spiffe://central.local/ns/istio-system/sa/federation-controller"},
+	}, {
+		Name:        "south",
+		TrustDomain: "south.local",
+	}, {
+		Name:                "north",
+		ControllerNamespace: "federation-system",
+	}}, "cluster.local")
+
+	testCases := []struct {
+		name       string
+		identity   adss.Identity
+		authorized bool
+	}{{
+		name:       "federation controller in the local trust domain",
+		identity:   adss.Identity{NodeID: "west", SpiffeID: "spiffe://cluster.local/ns/istio-system/sa/federation-controller"},
+		authorized: true,
+	}, {
+		name:     "other identity in the local trust domain",
+		identity: adss.Identity{NodeID: "west", SpiffeID: "spiffe://cluster.local/ns/default/sa/default"},
+	}, {
+		name:       "federation controller in the trust domain of the remote",
+		identity:   adss.Identity{NodeID: "south", SpiffeID: "spiffe://south.local/ns/istio-system/sa/federation-controller"},
+		authorized: true,
+	}, {
+		name:     "other identity in the trust domain of the remote",
+		identity: adss.Identity{NodeID: "south", SpiffeID: "spiffe://south.local/ns/istio-system/sa/istio-ingressgateway"},
+	}, {
+		name:     "identity in a trust domain of another remote",
+		identity: adss.Identity{NodeID: "south", SpiffeID: "spiffe://cluster.local/ns/istio-system/sa/federation-controller"},
+	}, {
+		name:       "allowed identity",
+		identity:   adss.Identity{NodeID: "central", SpiffeID: "spiffe://central.local/ns/istio-system/sa/federation-controller"},
+		authorized: true,
+	}, {
+		name:     "identity not on the allow-list",
+		identity: adss.Identity{NodeID: "central", SpiffeID: "spiffe://central.local/ns/default/sa/default"},
+	}, {
+		name:       "federation controller in the namespace configured for the remote",
+		identity:   adss.Identity{NodeID: "north", SpiffeID: "spiffe://cluster.local/ns/federation-system/sa/federation-controller"},
+		authorized: true,
+	}, {
+		name:     "federation controller in the default namespace when another is configured",
+		identity: adss.Identity{NodeID: "north", SpiffeID: "spiffe://cluster.local/ns/istio-system/sa/federation-controller"},
+	}, {
+		name:     "unknown remote",
+		identity: adss.Identity{NodeID: "east", SpiffeID: "spiffe://cluster.local/ns/istio-system/sa/federation-controller"},
+	}, {
+		name:     "no SPIFFE ID",
+		identity: adss.Identity{NodeID: "west"},
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := authorize(tc.identity)
+			if tc.authorized && err != nil {
+				t.Errorf("expected subscriber to be authorized, but got: %v", err)
+			}
+			if !tc.authorized && err == nil {
+				t.Errorf("expected subscriber to be rejected")
+			}
+		})
+	}
+}
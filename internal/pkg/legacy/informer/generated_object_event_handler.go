@@ -0,0 +1,68 @@
+// Copyright Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the License);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an AS IS BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package informer
+
+import (
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"github.com/openshift-service-mesh/federation/internal/pkg/legacy/xds"
+)
+
+var _ Handler = (*GeneratedObjectEventHandler)(nil)
+
+// GeneratedObjectEventHandler triggers reconciliation of generated objects of the given type when they are modified
+// or deleted by someone else, so that manual changes are reverted immediately.
+// Created objects are ignored, because they are almost always created by the controller itself.
+type GeneratedObjectEventHandler struct {
+	typeUrl      string
+	pushRequests chan<- xds.PushRequest
+}
+
+func NewGeneratedObjectEventHandler(typeUrl string, pushRequests chan<- xds.PushRequest) *GeneratedObjectEventHandler {
+	return &GeneratedObjectEventHandler{
+		typeUrl:      typeUrl,
+		pushRequests: pushRequests,
+	}
+}
+
+func (h *GeneratedObjectEventHandler) Init() error {
+	return nil
+}
+
+func (h *GeneratedObjectEventHandler) ObjectCreated(_ runtime.Object) {
+}
+
+func (h *GeneratedObjectEventHandler) ObjectDeleted(obj runtime.Object) {
+	if objMeta, err := meta.Accessor(obj); err == nil {
+		log.Infof("Generated object %s/%s of type %s was deleted", objMeta.GetNamespace(), objMeta.GetName(), h.typeUrl)
+	}
+	h.pushRequests <- xds.PushRequest{TypeUrl: h.typeUrl}
+}
+
+func (h *GeneratedObjectEventHandler) ObjectUpdated(oldObj, newObj runtime.Object) {
+	oldMeta, errOld := meta.Accessor(oldObj)
+	newMeta, errNew := meta.Accessor(newObj)
+	if errOld != nil || errNew != nil {
+		return
+	}
+	// Changes of status or annotations do not require reconciliation.
+	if oldMeta.GetGeneration() == newMeta.GetGeneration() && labels.Equals(oldMeta.GetLabels(), newMeta.GetLabels()) {
+		return
+	}
+	log.Debugf("Generated object %s/%s of type %s was updated", newMeta.GetNamespace(), newMeta.GetName(), h.typeUrl)
+	h.pushRequests <- xds.PushRequest{TypeUrl: h.typeUrl}
+}
@@ -0,0 +1,157 @@
+// Copyright Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the License);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an AS IS BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package informer
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	networkingv1alpha3 "istio.io/client-go/pkg/apis/networking/v1alpha3"
+	istiofake "istio.io/client-go/pkg/clientset/versioned/fake"
+	istioinformers "istio.io/client-go/pkg/informers/externalversions"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/openshift-service-mesh/federation/internal/pkg/common"
+	"github.com/openshift-service-mesh/federation/internal/pkg/legacy/xds"
+)
+
+var testOwner = common.Owner{Mode: common.LegacyMode, Instance: "test"}
+
+func TestGeneratedObjectEventHandler(t *testing.T) {
+	generated := func(generation int64, labels map[string]string) *networkingv1alpha3.ServiceEntry {
+		return &networkingv1alpha3.ServiceEntry{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:       "import-a",
+				Namespace:  "istio-system",
+				Generation: generation,
+				Labels:     labels,
+			},
+		}
+	}
+	ownerLabels := common.OwnerLabels(testOwner)
+
+	testCases := []struct {
+		name              string
+		handlerFunc       func(handler Handler)
+		isTimeoutExpected bool
+	}{{
+		name: "object created - no push expected",
+		handlerFunc: func(handler Handler) {
+			handler.ObjectCreated(generated(1, ownerLabels))
+		},
+		isTimeoutExpected: true,
+	}, {
+		name: "object deleted - push expected",
+		handlerFunc: func(handler Handler) {
+			handler.ObjectDeleted(generated(1, ownerLabels))
+		},
+		isTimeoutExpected: false,
+	}, {
+		name: "object spec modified - push expected",
+		handlerFunc: func(handler Handler) {
+			handler.ObjectUpdated(generated(1, ownerLabels), generated(2, ownerLabels))
+		},
+		isTimeoutExpected: false,
+	}, {
+		name: "object labels modified - push expected",
+		handlerFunc: func(handler Handler) {
+			handler.ObjectUpdated(generated(1, ownerLabels), generated(1, map[string]string{"app": "test"}))
+		},
+		isTimeoutExpected: false,
+	}, {
+		name: "object status or annotations modified - no push expected",
+		handlerFunc: func(handler Handler) {
+			handler.ObjectUpdated(generated(1, ownerLabels), generated(1, ownerLabels))
+		},
+		isTimeoutExpected: true,
+	}}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			pushRequests := make(chan xds.PushRequest)
+			handler := NewGeneratedObjectEventHandler(xds.ServiceEntryTypeUrl, pushRequests)
+
+			go func() {
+				tc.handlerFunc(handler)
+			}()
+
+			checkChannel(t, pushRequests, xds.ServiceEntryTypeUrl, tc.isTimeoutExpected)
+		})
+	}
+}
+
+func TestGeneratedObjectChangesTriggerPush(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	client := istiofake.NewSimpleClientset(&networkingv1alpha3.ServiceEntry{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:       "import-a",
+			Namespace:  "istio-system",
+			Generation: 1,
+			Labels:     common.OwnerLabels(testOwner),
+		},
+	})
+	informerFactory := istioinformers.NewSharedInformerFactoryWithOptions(client, 0,
+		istioinformers.WithTweakListOptions(func(opts *metav1.ListOptions) {
+			opts.LabelSelector = common.OwnerSelector(testOwner)
+		}))
+	serviceEntryInformer := informerFactory.Networking().V1alpha3().ServiceEntries().Informer()
+
+	pushRequests := make(chan xds.PushRequest)
+	controller, err := NewResourceController(serviceEntryInformer, networkingv1alpha3.ServiceEntry{},
+		NewGeneratedObjectEventHandler(xds.ServiceEntryTypeUrl, pushRequests))
+	if err != nil {
+		t.Fatalf("failed to create controller: %v", err)
+	}
+	informerFactory.Start(ctx.Done())
+	go controller.Run(ctx.Done())
+	if !cache.WaitForCacheSync(ctx.Done(), controller.HasSynced) {
+		t.Fatal("failed to sync informer")
+	}
+
+	// The initial list of generated objects must not trigger reconciliation.
+	checkChannel(t, pushRequests, xds.ServiceEntryTypeUrl, true)
+
+	serviceEntries := client.NetworkingV1alpha3().ServiceEntries("istio-system")
+	modified, err := serviceEntries.Get(ctx, "import-a", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("failed to get service entry: %v", err)
+	}
+	modified.Spec.Hosts = []string{"modified.local"}
+	modified.Generation++
+	if _, err := serviceEntries.Update(ctx, modified, metav1.UpdateOptions{}); err != nil {
+		t.Fatalf("failed to update service entry: %v", err)
+	}
+	waitForPush(t, pushRequests, xds.ServiceEntryTypeUrl)
+
+	if err := serviceEntries.Delete(ctx, "import-a", metav1.DeleteOptions{}); err != nil {
+		t.Fatalf("failed to delete service entry: %v", err)
+	}
+	waitForPush(t, pushRequests, xds.ServiceEntryTypeUrl)
+}
+
+func waitForPush(t *testing.T, requests <-chan xds.PushRequest, expectedType string) {
+	t.Helper()
+	select {
+	case req := <-requests:
+		if req.TypeUrl != expectedType {
+			t.Errorf("expected push request for %s, got %s", expectedType, req.TypeUrl)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for push request")
+	}
+}
@@ -0,0 +1,69 @@
+// Copyright Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the License);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an AS IS BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package informer
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"github.com/openshift-service-mesh/federation/internal/pkg/config"
+	"github.com/openshift-service-mesh/federation/internal/pkg/legacy/xds"
+)
+
+var _ Handler = (*NamespaceExportEventHandler)(nil)
+
+// NamespaceExportEventHandler triggers proper FDS/MCP pushes when namespace labels change
+// and export rules select services by namespace labels.
+// Creating or deleting a namespace is handled by ServiceExportEventHandler, because it affects only services in that namespace.
+type NamespaceExportEventHandler struct {
+	cfg             config.Federation
+	fdsPushRequests chan<- xds.PushRequest
+	mcpPushRequests chan<- xds.PushRequest
+}
+
+func NewNamespaceExportEventHandler(
+	cfg config.Federation,
+	fdsPushRequests,
+	mcpPushRequests chan<- xds.PushRequest,
+) *NamespaceExportEventHandler {
+	return &NamespaceExportEventHandler{
+		cfg:             cfg,
+		fdsPushRequests: fdsPushRequests,
+		mcpPushRequests: mcpPushRequests,
+	}
+}
+
+func (w *NamespaceExportEventHandler) Init() error {
+	return nil
+}
+
+func (w *NamespaceExportEventHandler) ObjectCreated(_ runtime.Object) {
+}
+
+func (w *NamespaceExportEventHandler) ObjectDeleted(_ runtime.Object) {
+}
+
+func (w *NamespaceExportEventHandler) ObjectUpdated(oldObj, newObj runtime.Object) {
+	oldNamespace := oldObj.(*corev1.Namespace)
+	newNamespace := newObj.(*corev1.Namespace)
+	if labels.Equals(oldNamespace.Labels, newNamespace.Labels) {
+		return
+	}
+	if w.cfg.ExportedServiceSet.SelectsNamespaceLabels() {
+		log.Debugf("Updated labels of namespace %s", newNamespace.Name)
+		pushExportedServices(w.fdsPushRequests, w.mcpPushRequests)
+	}
+}
@@ -17,6 +17,7 @@ package informer
 import (
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	v1 "k8s.io/client-go/listers/core/v1"
 
 	"github.com/openshift-service-mesh/federation/internal/pkg/common"
 	"github.com/openshift-service-mesh/federation/internal/pkg/config"
@@ -28,17 +29,20 @@ var _ Handler = (*ServiceExportEventHandler)(nil)
 // ServiceExportEventHandler processes Service events and triggers proper FDS/MCP pushes if an event matches export rules.
 type ServiceExportEventHandler struct {
 	cfg             config.Federation
+	namespaceLister v1.NamespaceLister
 	fdsPushRequests chan<- xds.PushRequest
 	mcpPushRequests chan<- xds.PushRequest
 }
 
 func NewServiceExportEventHandler(
 	cfg config.Federation,
+	namespaceLister v1.NamespaceLister,
 	fdsPushRequests,
 	mcpPushRequests chan<- xds.PushRequest,
 ) *ServiceExportEventHandler {
 	return &ServiceExportEventHandler{
 		cfg:             cfg,
+		namespaceLister: namespaceLister,
 		fdsPushRequests: fdsPushRequests,
 		mcpPushRequests: mcpPushRequests,
 	}
@@ -68,21 +72,34 @@ func (w *ServiceExportEventHandler) ObjectUpdated(oldObj, newObj runtime.Object)
 }
 
 func (w *ServiceExportEventHandler) triggerXDSPushIfMatchRules(services ...*corev1.Service) {
-	exportLabels := w.cfg.ExportedServiceSet.GetLabelSelectors()
-	if len(services) == 2 {
-		if common.MatchExportRules(services[0], exportLabels) != common.MatchExportRules(services[1], exportLabels) {
+	matches := make([]bool, 0, len(services))
+	for _, svc := range services {
+		matched, err := common.MatchExportRules(svc, w.namespaceLister, w.cfg.ExportedServiceSet.Rules)
+		if err != nil {
+			log.Errorf("failed to match service %s/%s against export rules: %v", svc.Namespace, svc.Name, err)
+			return
+		}
+		matches = append(matches, matched)
+	}
+	if len(matches) == 2 {
+		if matches[0] != matches[1] {
 			w.triggerXDSPush()
 		}
 	} else {
-		if common.MatchExportRules(services[0], exportLabels) {
+		if matches[0] {
 			w.triggerXDSPush()
 		}
 	}
 }
 
 func (w *ServiceExportEventHandler) triggerXDSPush() {
-	w.mcpPushRequests <- xds.PushRequest{TypeUrl: xds.GatewayTypeUrl}
-	w.mcpPushRequests <- xds.PushRequest{TypeUrl: xds.EnvoyFilterTypeUrl}
-	w.mcpPushRequests <- xds.PushRequest{TypeUrl: xds.RouteTypeUrl}
-	w.fdsPushRequests <- xds.PushRequest{TypeUrl: xds.ExportedServiceTypeUrl}
+	pushExportedServices(w.fdsPushRequests, w.mcpPushRequests)
+}
+
+// pushExportedServices triggers regeneration of all resources depending on the set of exported services.
+func pushExportedServices(fdsPushRequests, mcpPushRequests chan<- xds.PushRequest) {
+	mcpPushRequests <- xds.PushRequest{TypeUrl: xds.GatewayTypeUrl}
+	mcpPushRequests <- xds.PushRequest{TypeUrl: xds.EnvoyFilterTypeUrl}
+	mcpPushRequests <- xds.PushRequest{TypeUrl: xds.RouteTypeUrl}
+	fdsPushRequests <- xds.PushRequest{TypeUrl: xds.ExportedServiceTypeUrl}
 }
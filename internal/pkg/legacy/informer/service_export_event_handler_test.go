@@ -153,7 +153,7 @@ func TestXDSTriggers(t *testing.T) {
 		t.Run(tc.name, func(t *testing.T) {
 			fdsPushRequests := make(chan xds.PushRequest)
 			mcpPushRequests := make(chan xds.PushRequest)
-			handler := NewServiceExportEventHandler(defaultConfig, fdsPushRequests, mcpPushRequests)
+			handler := NewServiceExportEventHandler(defaultConfig, nil, fdsPushRequests, mcpPushRequests)
 
 			// ObjectCreated must be called in a goroutine, because mcpPushRequests and fdsPushRequests are unbuffered channels,
 			// so they are blocked until another goroutine reads from the channels.
@@ -36,12 +36,14 @@ var _ Reconciler = (*DestinationRuleReconciler)(nil)
 type DestinationRuleReconciler struct {
 	client kube.Client
 	cf     *istio.ConfigFactory
+	gate   DeletionGate
 }
 
-func NewDestinationRuleReconciler(client kube.Client, cf *istio.ConfigFactory) *DestinationRuleReconciler {
+func NewDestinationRuleReconciler(client kube.Client, cf *istio.ConfigFactory, gate DeletionGate) *DestinationRuleReconciler {
 	return &DestinationRuleReconciler{
 		client: client,
 		cf:     cf,
+		gate:   gate,
 	}
 }
 
@@ -60,24 +62,22 @@ func (r *DestinationRuleReconciler) Reconcile(ctx context.Context) error {
 		destinationRulesMap[types.NamespacedName{Namespace: dr.Namespace, Name: dr.Name}] = dr
 	}
 
-	oldDestinationRules, err := r.client.Istio().NetworkingV1alpha3().DestinationRules(metav1.NamespaceAll).List(ctx, metav1.ListOptions{
-		LabelSelector: metav1.FormatLabelSelector(&metav1.LabelSelector{
-			MatchLabels: map[string]string{"federation.openshift-service-mesh.io/peer": "todo"},
-		}),
-	})
-	if err != nil {
-		return fmt.Errorf("failed to list destination rules: %w", err)
-	}
-	oldDestinationRulesMap := make(map[types.NamespacedName]*v1alpha3.DestinationRule, len(oldDestinationRules.Items))
-	for _, dr := range oldDestinationRules.Items {
-		oldDestinationRulesMap[types.NamespacedName{Namespace: dr.Namespace, Name: dr.Name}] = dr
+	oldDestinationRulesMap := make(map[types.NamespacedName]*v1alpha3.DestinationRule)
+	for _, selector := range ownerSelectors(r.cf.Owner()) {
+		oldDestinationRules, err := r.client.Istio().NetworkingV1alpha3().DestinationRules(metav1.NamespaceAll).List(ctx, metav1.ListOptions{LabelSelector: selector})
+		if err != nil {
+			return fmt.Errorf("failed to list destination rules: %w", err)
+		}
+		for _, dr := range oldDestinationRules.Items {
+			oldDestinationRulesMap[types.NamespacedName{Namespace: dr.Namespace, Name: dr.Name}] = dr
+		}
 	}
 
 	kind := "DestinationRule"
 	apiVersion := "networking.istio.io/v1alpha3"
 	for k, dr := range destinationRulesMap {
 		oldDR, ok := oldDestinationRulesMap[k]
-		if !ok || !reflect.DeepEqual(&oldDR.Spec, &dr.Spec) {
+		if !ok || !reflect.DeepEqual(&oldDR.Spec, &dr.Spec) || metadataChanged(oldDR, dr) {
 			// Destination rule does not currently exist or requires update
 			newDR, err := r.client.Istio().NetworkingV1alpha3().DestinationRules(dr.GetNamespace()).Apply(ctx,
 				&applyv1alpha3.DestinationRuleApplyConfiguration{
@@ -86,9 +86,10 @@ func (r *DestinationRuleReconciler) Reconcile(ctx context.Context) error {
 						APIVersion: &apiVersion,
 					},
 					ObjectMetaApplyConfiguration: &applyconfigurationv1.ObjectMetaApplyConfiguration{
-						Name:      &dr.Name,
-						Namespace: &dr.Namespace,
-						Labels:    dr.Labels,
+						Name:        &dr.Name,
+						Namespace:   &dr.Namespace,
+						Labels:      dr.Labels,
+						Annotations: dr.Annotations,
 					},
 					Spec: &dr.Spec,
 				},
@@ -108,6 +109,11 @@ func (r *DestinationRuleReconciler) Reconcile(ctx context.Context) error {
 		}
 	}
 
+	if !r.gate.DeletionsAllowed() {
+		log.Debugf("Postponing deletion of stale destination rules until remote peers are synced")
+		return nil
+	}
+
 	for k, oldDR := range oldDestinationRulesMap {
 		if _, ok := destinationRulesMap[k]; !ok {
 			err := r.client.Istio().NetworkingV1alpha3().DestinationRules(oldDR.GetNamespace()).Delete(ctx, oldDR.GetName(), metav1.DeleteOptions{})
@@ -60,24 +60,22 @@ func (r *EnvoyFilterReconciler) Reconcile(ctx context.Context) error {
 		envoyFiltersMap[types.NamespacedName{Namespace: ef.Namespace, Name: ef.Name}] = ef
 	}
 
-	oldEnvoyFilters, err := r.client.Istio().NetworkingV1alpha3().EnvoyFilters(metav1.NamespaceAll).List(ctx, metav1.ListOptions{
-		LabelSelector: metav1.FormatLabelSelector(&metav1.LabelSelector{
-			MatchLabels: map[string]string{"federation.openshift-service-mesh.io/peer": "todo"},
-		}),
-	})
-	if err != nil {
-		return fmt.Errorf("failed to list envoy filters: %w", err)
-	}
-	oldEnvoyFiltersMap := make(map[types.NamespacedName]*v1alpha3.EnvoyFilter, len(oldEnvoyFilters.Items))
-	for _, ef := range oldEnvoyFilters.Items {
-		oldEnvoyFiltersMap[types.NamespacedName{Namespace: ef.Namespace, Name: ef.Name}] = ef
+	oldEnvoyFiltersMap := make(map[types.NamespacedName]*v1alpha3.EnvoyFilter)
+	for _, selector := range ownerSelectors(r.cf.Owner()) {
+		oldEnvoyFilters, err := r.client.Istio().NetworkingV1alpha3().EnvoyFilters(metav1.NamespaceAll).List(ctx, metav1.ListOptions{LabelSelector: selector})
+		if err != nil {
+			return fmt.Errorf("failed to list envoy filters: %w", err)
+		}
+		for _, ef := range oldEnvoyFilters.Items {
+			oldEnvoyFiltersMap[types.NamespacedName{Namespace: ef.Namespace, Name: ef.Name}] = ef
+		}
 	}
 
 	kind := "EnvoyFilter"
 	apiVersion := "networking.istio.io/v1alpha3"
 	for k, ef := range envoyFiltersMap {
 		oldEF, ok := oldEnvoyFiltersMap[k]
-		if !ok || !reflect.DeepEqual(&oldEF.Spec, &ef.Spec) {
+		if !ok || !reflect.DeepEqual(&oldEF.Spec, &ef.Spec) || metadataChanged(oldEF, ef) {
 			// Envoy filter does not currently exist or requires update
 			newEF, err := r.client.Istio().NetworkingV1alpha3().EnvoyFilters(ef.GetNamespace()).Apply(ctx,
 				&applyv1alpha3.EnvoyFilterApplyConfiguration{
@@ -18,28 +18,26 @@ import (
 	"context"
 	"fmt"
 
-	securityv1beta1 "istio.io/api/security/v1beta1"
-	typev1beta1 "istio.io/api/type/v1beta1"
-	"istio.io/client-go/pkg/apis/security/v1beta1"
 	applyconfigurationv1 "istio.io/client-go/pkg/applyconfiguration/meta/v1"
 	applyv1beta "istio.io/client-go/pkg/applyconfiguration/security/v1beta1"
 	"istio.io/istio/pkg/kube"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
+	"github.com/openshift-service-mesh/federation/internal/pkg/istio"
 	"github.com/openshift-service-mesh/federation/internal/pkg/legacy/xds"
 )
 
 var _ Reconciler = (*PeerAuthResourceReconciler)(nil)
 
 type PeerAuthResourceReconciler struct {
-	client    kube.Client
-	namespace string
+	client kube.Client
+	cf     *istio.ConfigFactory
 }
 
-func NewPeerAuthResourceReconciler(client kube.Client, namespace string) *PeerAuthResourceReconciler {
+func NewPeerAuthResourceReconciler(client kube.Client, cf *istio.ConfigFactory) *PeerAuthResourceReconciler {
 	return &PeerAuthResourceReconciler{
-		client:    client,
-		namespace: namespace,
+		client: client,
+		cf:     cf,
 	}
 }
 
@@ -48,23 +46,7 @@ func (r *PeerAuthResourceReconciler) GetTypeUrl() string {
 }
 
 func (r *PeerAuthResourceReconciler) Reconcile(ctx context.Context) error {
-	pa := &v1beta1.PeerAuthentication{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      "fds-strict-mtls",
-			Namespace: r.namespace,
-			Labels:    map[string]string{"federation.openshift-service-mesh.io/peer": "todo"},
-		},
-		Spec: securityv1beta1.PeerAuthentication{
-			Selector: &typev1beta1.WorkloadSelector{
-				MatchLabels: map[string]string{
-					"app.kubernetes.io/name": "federation-controller",
-				},
-			},
-			Mtls: &securityv1beta1.PeerAuthentication_MutualTLS{
-				Mode: securityv1beta1.PeerAuthentication_MutualTLS_STRICT,
-			},
-		},
-	}
+	pa := r.cf.PeerAuthentication()
 
 	kind := "PeerAuthentication"
 	apiVersion := "security.istio.io/v1beta1"
@@ -14,7 +14,13 @@
 
 package kube
 
-import "context"
+import (
+	"context"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/openshift-service-mesh/federation/internal/pkg/common"
+)
 
 // Reconciler handles reconciliation of all resources of a K8s kind.
 type Reconciler interface {
@@ -25,3 +31,29 @@ type Reconciler interface {
 	// Reconcile all resources of the K8s resource type.
 	Reconcile(ctx context.Context) error
 }
+
+// DeletionGate decides whether reconcilers can delete objects, which are no longer generated.
+type DeletionGate interface {
+	DeletionsAllowed() bool
+}
+
+// ownerSelectors returns label selectors matching objects owned by the given federation instance, including objects
+// generated before they were labeled with their owner, so that these are relabeled or pruned after an upgrade.
+// Objects generated in other modes for an instance of the same name are not matched.
+func ownerSelectors(owner common.Owner) []string {
+	return []string{common.OwnerSelector(owner), common.LegacyOwnerSelector()}
+}
+
+// metadataChanged returns true if the existing object lacks any of the labels or annotations of the desired object.
+func metadataChanged(existing, desired metav1.Object) bool {
+	return !containsAll(existing.GetLabels(), desired.GetLabels()) || !containsAll(existing.GetAnnotations(), desired.GetAnnotations())
+}
+
+func containsAll(actual, expected map[string]string) bool {
+	for k, v := range expected {
+		if value, found := actual[k]; !found || value != v {
+			return false
+		}
+	}
+	return true
+}
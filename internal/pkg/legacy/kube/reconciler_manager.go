@@ -17,6 +17,7 @@ package kube
 import (
 	"context"
 	"errors"
+	"time"
 
 	istiolog "istio.io/istio/pkg/log"
 
@@ -28,9 +29,13 @@ var log = istiolog.RegisterScope("kube", "Kubernetes reconciler")
 type ReconcilerManager struct {
 	pushRequests <-chan xds.PushRequest
 	reconcilers  map[string]Reconciler
+	resyncPeriod time.Duration
 }
 
-func NewReconcilerManager(pushRequests <-chan xds.PushRequest, reconcilers ...Reconciler) *ReconcilerManager {
+// NewReconcilerManager creates a manager, which reconciles resources of the type requested by push requests.
+// If resyncPeriod is greater than zero, all resources are also reconciled periodically to revert changes
+// that were not observed by watches.
+func NewReconcilerManager(pushRequests <-chan xds.PushRequest, resyncPeriod time.Duration, reconcilers ...Reconciler) *ReconcilerManager {
 	reconcilerMap := make(map[string]Reconciler, len(reconcilers))
 	for _, r := range reconcilers {
 		reconcilerMap[r.GetTypeUrl()] = r
@@ -39,6 +44,7 @@ func NewReconcilerManager(pushRequests <-chan xds.PushRequest, reconcilers ...Re
 	return &ReconcilerManager{
 		pushRequests: pushRequests,
 		reconcilers:  reconcilerMap,
+		resyncPeriod: resyncPeriod,
 	}
 }
 
@@ -53,6 +59,12 @@ func (rm *ReconcilerManager) ReconcileAll(ctx context.Context) error {
 }
 
 func (rm *ReconcilerManager) Start(ctx context.Context) {
+	var resync <-chan time.Time
+	if rm.resyncPeriod > 0 {
+		ticker := time.NewTicker(rm.resyncPeriod)
+		defer ticker.Stop()
+		resync = ticker.C
+	}
 
 loop:
 	for {
@@ -60,6 +72,12 @@ loop:
 		case <-ctx.Done():
 			break loop
 
+		case <-resync:
+			log.Debug("Periodic resync of all resources")
+			if err := rm.ReconcileAll(ctx); err != nil {
+				log.Errorf("Periodic resync failed: %v", err)
+			}
+
 		case pushRequest := <-rm.pushRequests:
 			log.Infof("Received push request: %v", pushRequest)
 
@@ -0,0 +1,121 @@
+// Copyright Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the License);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an AS IS BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kube
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/openshift-service-mesh/federation/internal/pkg/legacy/xds"
+)
+
+type countingReconciler struct {
+	typeUrl    string
+	reconciles chan struct{}
+	count      atomic.Int32
+}
+
+func newCountingReconciler(typeUrl string) *countingReconciler {
+	return &countingReconciler{
+		typeUrl:    typeUrl,
+		reconciles: make(chan struct{}, 10),
+	}
+}
+
+func (r *countingReconciler) GetTypeUrl() string {
+	return r.typeUrl
+}
+
+func (r *countingReconciler) Reconcile(_ context.Context) error {
+	r.count.Add(1)
+	select {
+	case r.reconciles <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+func (r *countingReconciler) waitForReconcile(t *testing.T) {
+	t.Helper()
+	select {
+	case <-r.reconciles:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timed out waiting for reconciliation of %s", r.typeUrl)
+	}
+}
+
+func TestReconcilerManagerReconcilesRequestedType(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	serviceEntries := newCountingReconciler(xds.ServiceEntryTypeUrl)
+	workloadEntries := newCountingReconciler(xds.WorkloadEntryTypeUrl)
+	pushRequests := make(chan xds.PushRequest)
+	go NewReconcilerManager(pushRequests, 0, serviceEntries, workloadEntries).Start(ctx)
+
+	pushRequests <- xds.PushRequest{TypeUrl: xds.ServiceEntryTypeUrl}
+	serviceEntries.waitForReconcile(t)
+
+	// Unknown types are ignored and must not block following push requests.
+	pushRequests <- xds.PushRequest{TypeUrl: xds.GatewayTypeUrl}
+	pushRequests <- xds.PushRequest{TypeUrl: xds.ServiceEntryTypeUrl}
+	serviceEntries.waitForReconcile(t)
+
+	if count := workloadEntries.count.Load(); count != 0 {
+		t.Errorf("expected no reconciliation of workload entries, got %d", count)
+	}
+}
+
+func TestReconcilerManagerResync(t *testing.T) {
+	testCases := []struct {
+		name            string
+		resyncPeriod    time.Duration
+		expectReconcile bool
+	}{{
+		name:            "resync enabled - all types are reconciled periodically",
+		resyncPeriod:    10 * time.Millisecond,
+		expectReconcile: true,
+	}, {
+		name:            "resync disabled - no reconciliation without push requests",
+		resyncPeriod:    0,
+		expectReconcile: false,
+	}}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			serviceEntries := newCountingReconciler(xds.ServiceEntryTypeUrl)
+			workloadEntries := newCountingReconciler(xds.WorkloadEntryTypeUrl)
+			go NewReconcilerManager(make(chan xds.PushRequest), tc.resyncPeriod, serviceEntries, workloadEntries).Start(ctx)
+
+			if tc.expectReconcile {
+				// Two rounds make sure the ticker keeps firing after the first resync.
+				for range 2 {
+					serviceEntries.waitForReconcile(t)
+					workloadEntries.waitForReconcile(t)
+				}
+				return
+			}
+
+			time.Sleep(50 * time.Millisecond)
+			if count := serviceEntries.count.Load() + workloadEntries.count.Load(); count != 0 {
+				t.Errorf("expected no reconciliation, got %d", count)
+			}
+		})
+	}
+}
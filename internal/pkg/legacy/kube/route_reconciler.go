@@ -60,25 +60,22 @@ func (r *RouteReconciler) Reconcile(ctx context.Context) error {
 		routesMap[types.NamespacedName{Namespace: route.Namespace, Name: route.Name}] = route
 	}
 
-	oldRoutes, err := r.client.RouteV1().Routes(metav1.NamespaceAll).List(ctx, metav1.ListOptions{
-		LabelSelector: metav1.FormatLabelSelector(&metav1.LabelSelector{
-			MatchLabels: map[string]string{"federation.openshift.io/peer": "todo"},
-		}),
-	})
-	if err != nil {
-		return fmt.Errorf("failed to list routes: %w", err)
-	}
-
-	oldRoutesMap := make(map[types.NamespacedName]*routev1.Route, len(oldRoutes.Items))
-	for _, route := range oldRoutes.Items {
-		oldRoutesMap[types.NamespacedName{Namespace: route.Namespace, Name: route.Name}] = &route
+	oldRoutesMap := make(map[types.NamespacedName]*routev1.Route)
+	for _, selector := range ownerSelectors(r.cf.Owner()) {
+		oldRoutes, err := r.client.RouteV1().Routes(metav1.NamespaceAll).List(ctx, metav1.ListOptions{LabelSelector: selector})
+		if err != nil {
+			return fmt.Errorf("failed to list routes: %w", err)
+		}
+		for _, route := range oldRoutes.Items {
+			oldRoutesMap[types.NamespacedName{Namespace: route.Namespace, Name: route.Name}] = &route
+		}
 	}
 
 	kind := "Route"
 	apiVersion := "route.openshift.io/v1"
 	for k, route := range routesMap {
 		oldRoute, ok := oldRoutesMap[k]
-		if !ok || !reflect.DeepEqual(&oldRoute.Spec, &route.Spec) {
+		if !ok || !reflect.DeepEqual(&oldRoute.Spec, &route.Spec) || metadataChanged(oldRoute, route) {
 			// Route does not currently exist or requires an update
 			newRoute, err := r.client.RouteV1().Routes(route.Namespace).Apply(ctx,
 				&routev1apply.RouteApplyConfiguration{
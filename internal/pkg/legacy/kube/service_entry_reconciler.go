@@ -36,12 +36,14 @@ var _ Reconciler = (*ServiceEntryReconciler)(nil)
 type ServiceEntryReconciler struct {
 	client kube.Client
 	cf     *istio.ConfigFactory
+	gate   DeletionGate
 }
 
-func NewServiceEntryReconciler(client kube.Client, cf *istio.ConfigFactory) *ServiceEntryReconciler {
+func NewServiceEntryReconciler(client kube.Client, cf *istio.ConfigFactory, gate DeletionGate) *ServiceEntryReconciler {
 	return &ServiceEntryReconciler{
 		client: client,
 		cf:     cf,
+		gate:   gate,
 	}
 }
 
@@ -59,24 +61,22 @@ func (r *ServiceEntryReconciler) Reconcile(ctx context.Context) error {
 		serviceEntriesMap[types.NamespacedName{Namespace: se.Namespace, Name: se.Name}] = se
 	}
 
-	oldServiceEntries, err := r.client.Istio().NetworkingV1alpha3().ServiceEntries(metav1.NamespaceAll).List(ctx, metav1.ListOptions{
-		LabelSelector: metav1.FormatLabelSelector(&metav1.LabelSelector{
-			MatchLabels: map[string]string{"federation.openshift-service-mesh.io/peer": "todo"},
-		}),
-	})
-	if err != nil {
-		return fmt.Errorf("failed to list service entries: %w", err)
-	}
-	oldServiceEntriesMap := make(map[types.NamespacedName]*v1alpha3.ServiceEntry, len(oldServiceEntries.Items))
-	for _, se := range oldServiceEntries.Items {
-		oldServiceEntriesMap[types.NamespacedName{Namespace: se.Namespace, Name: se.Name}] = se
+	oldServiceEntriesMap := make(map[types.NamespacedName]*v1alpha3.ServiceEntry)
+	for _, selector := range ownerSelectors(r.cf.Owner()) {
+		oldServiceEntries, err := r.client.Istio().NetworkingV1alpha3().ServiceEntries(metav1.NamespaceAll).List(ctx, metav1.ListOptions{LabelSelector: selector})
+		if err != nil {
+			return fmt.Errorf("failed to list service entries: %w", err)
+		}
+		for _, se := range oldServiceEntries.Items {
+			oldServiceEntriesMap[types.NamespacedName{Namespace: se.Namespace, Name: se.Name}] = se
+		}
 	}
 
 	kind := "ServiceEntry"
 	apiVersion := "networking.istio.io/v1alpha3"
 	for k, se := range serviceEntriesMap {
 		oldSE, ok := oldServiceEntriesMap[k]
-		if !ok || !reflect.DeepEqual(&oldSE.Spec, &se.Spec) {
+		if !ok || !reflect.DeepEqual(&oldSE.Spec, &se.Spec) || metadataChanged(oldSE, se) {
 			// Service entry does not currently exist or requires update
 			newSE, err := r.client.Istio().NetworkingV1alpha3().ServiceEntries(se.GetNamespace()).Apply(ctx,
 				&applyv1alpha3.ServiceEntryApplyConfiguration{
@@ -85,9 +85,10 @@ func (r *ServiceEntryReconciler) Reconcile(ctx context.Context) error {
 						APIVersion: &apiVersion,
 					},
 					ObjectMetaApplyConfiguration: &applymetav1.ObjectMetaApplyConfiguration{
-						Name:      &se.Name,
-						Namespace: &se.Namespace,
-						Labels:    se.Labels,
+						Name:        &se.Name,
+						Namespace:   &se.Namespace,
+						Labels:      se.Labels,
+						Annotations: se.Annotations,
 					},
 					Spec: &se.Spec,
 				},
@@ -107,6 +108,11 @@ func (r *ServiceEntryReconciler) Reconcile(ctx context.Context) error {
 		}
 	}
 
+	if !r.gate.DeletionsAllowed() {
+		log.Debugf("Postponing deletion of stale service entries until remote peers are synced")
+		return nil
+	}
+
 	for k, oldSE := range oldServiceEntriesMap {
 		if _, ok := serviceEntriesMap[k]; !ok {
 			err := r.client.Istio().NetworkingV1alpha3().ServiceEntries(oldSE.GetNamespace()).Delete(ctx, oldSE.GetName(), metav1.DeleteOptions{})
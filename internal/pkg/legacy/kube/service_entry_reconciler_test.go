@@ -0,0 +1,94 @@
+// Copyright Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the License);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an AS IS BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kube
+
+import (
+	"context"
+	"testing"
+
+	"istio.io/client-go/pkg/apis/networking/v1alpha3"
+	"istio.io/istio/pkg/kube"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/openshift-service-mesh/federation/internal/pkg/common"
+	"github.com/openshift-service-mesh/federation/internal/pkg/config"
+	"github.com/openshift-service-mesh/federation/internal/pkg/istio"
+	"github.com/openshift-service-mesh/federation/internal/pkg/legacy/fds"
+)
+
+type allowDeletions struct{}
+
+func (allowDeletions) DeletionsAllowed() bool {
+	return true
+}
+
+func TestServiceEntryReconcilerPrunesOnlyLegacyObjects(t *testing.T) {
+	serviceEntry := func(name string, labels map[string]string) *v1alpha3.ServiceEntry {
+		return &v1alpha3.ServiceEntry{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "istio-system",
+				Labels:    labels,
+			},
+		}
+	}
+
+	testCases := []struct {
+		name    string
+		labels  map[string]string
+		deleted bool
+	}{{
+		name:    "stale object generated in the legacy mode",
+		labels:  common.ImportLabels(common.Owner{Mode: common.LegacyMode, Instance: "east"}, "west"),
+		deleted: true,
+	}, {
+		name:    "object generated before labeling the owner",
+		labels:  map[string]string{common.PeerLabel: "todo"},
+		deleted: true,
+	}, {
+		name:   "object generated in the legacy mode of another instance",
+		labels: common.ImportLabels(common.Owner{Mode: common.LegacyMode, Instance: "north"}, "west"),
+	}, {
+		name:   "object generated for a FederatedService",
+		labels: common.ImportLabels(common.Owner{Mode: common.FederatedServiceMode, Instance: "east"}, "west"),
+	}, {
+		name:   "object generated for a MeshFederation of the same name",
+		labels: common.ImportLabels(common.Owner{Mode: common.MeshFederationMode, Instance: "east"}, "west"),
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			client := kube.NewFakeClient()
+			if _, err := client.Istio().NetworkingV1alpha3().ServiceEntries("istio-system").Create(ctx, serviceEntry("import-ratings", tc.labels), metav1.CreateOptions{}); err != nil {
+				t.Fatalf("failed to create service entry: %v", err)
+			}
+			cfg := config.Federation{MeshPeers: config.MeshPeers{Local: config.Local{Name: "east"}}}
+			cf := istio.NewConfigFactory(cfg, common.LegacyMode, config.StaticRemotes{}, nil, nil, fds.NewImportedServiceStore(), "istio-system")
+
+			if err := NewServiceEntryReconciler(client, cf, allowDeletions{}).Reconcile(ctx); err != nil {
+				t.Fatalf("reconcile failed: %v", err)
+			}
+
+			list, err := client.Istio().NetworkingV1alpha3().ServiceEntries("istio-system").List(ctx, metav1.ListOptions{})
+			if err != nil {
+				t.Fatalf("failed to list service entries: %v", err)
+			}
+			if deleted := len(list.Items) == 0; deleted != tc.deleted {
+				t.Errorf("expected deleted to be %t, got %t", tc.deleted, deleted)
+			}
+		})
+	}
+}
@@ -36,12 +36,14 @@ var _ Reconciler = (*WorkloadEntryReconciler)(nil)
 type WorkloadEntryReconciler struct {
 	client kube.Client
 	cf     *istio.ConfigFactory
+	gate   DeletionGate
 }
 
-func NewWorkloadEntryReconciler(client kube.Client, cf *istio.ConfigFactory) *WorkloadEntryReconciler {
+func NewWorkloadEntryReconciler(client kube.Client, cf *istio.ConfigFactory, gate DeletionGate) *WorkloadEntryReconciler {
 	return &WorkloadEntryReconciler{
 		client: client,
 		cf:     cf,
+		gate:   gate,
 	}
 }
 
@@ -59,24 +61,22 @@ func (r *WorkloadEntryReconciler) Reconcile(ctx context.Context) error {
 		workloadEntriesMap[types.NamespacedName{Namespace: we.Namespace, Name: we.Name}] = we
 	}
 
-	oldWorkloadEntries, err := r.client.Istio().NetworkingV1alpha3().WorkloadEntries(metav1.NamespaceAll).List(ctx, metav1.ListOptions{
-		LabelSelector: metav1.FormatLabelSelector(&metav1.LabelSelector{
-			MatchLabels: map[string]string{"federation.openshift-service-mesh.io/peer": "todo"},
-		}),
-	})
-	if err != nil {
-		return fmt.Errorf("failed to list workload entries: %w", err)
-	}
-	oldWorkloadEntriesMap := make(map[types.NamespacedName]*v1alpha3.WorkloadEntry, len(oldWorkloadEntries.Items))
-	for _, we := range oldWorkloadEntries.Items {
-		oldWorkloadEntriesMap[types.NamespacedName{Namespace: we.Namespace, Name: we.Name}] = we
+	oldWorkloadEntriesMap := make(map[types.NamespacedName]*v1alpha3.WorkloadEntry)
+	for _, selector := range ownerSelectors(r.cf.Owner()) {
+		oldWorkloadEntries, err := r.client.Istio().NetworkingV1alpha3().WorkloadEntries(metav1.NamespaceAll).List(ctx, metav1.ListOptions{LabelSelector: selector})
+		if err != nil {
+			return fmt.Errorf("failed to list workload entries: %w", err)
+		}
+		for _, we := range oldWorkloadEntries.Items {
+			oldWorkloadEntriesMap[types.NamespacedName{Namespace: we.Namespace, Name: we.Name}] = we
+		}
 	}
 
 	kind := "WorkloadEntry"
 	apiVersion := "networking.istio.io/v1alpha3"
 	for k, we := range workloadEntriesMap {
 		oldWE, ok := oldWorkloadEntriesMap[k]
-		if !ok || !reflect.DeepEqual(&oldWE.Spec, &we.Spec) {
+		if !ok || !reflect.DeepEqual(&oldWE.Spec, &we.Spec) || metadataChanged(oldWE, we) {
 			// Workload entry does not currently exist or requires update
 			newWE, err := r.client.Istio().NetworkingV1alpha3().WorkloadEntries(we.GetNamespace()).Apply(ctx,
 				&applyv1alpha3.WorkloadEntryApplyConfiguration{
@@ -85,9 +85,10 @@ func (r *WorkloadEntryReconciler) Reconcile(ctx context.Context) error {
 						APIVersion: &apiVersion,
 					},
 					ObjectMetaApplyConfiguration: &applymetav1.ObjectMetaApplyConfiguration{
-						Name:      &we.Name,
-						Namespace: &we.Namespace,
-						Labels:    we.Labels,
+						Name:        &we.Name,
+						Namespace:   &we.Namespace,
+						Labels:      we.Labels,
+						Annotations: we.Annotations,
 					},
 					Spec:   &we.Spec,
 					Status: nil,
@@ -108,6 +109,11 @@ func (r *WorkloadEntryReconciler) Reconcile(ctx context.Context) error {
 		}
 	}
 
+	if !r.gate.DeletionsAllowed() {
+		log.Debugf("Postponing deletion of stale workload entries until remote peers are synced")
+		return nil
+	}
+
 	for k, oldWE := range oldWorkloadEntriesMap {
 		if _, ok := workloadEntriesMap[k]; !ok {
 			err := r.client.Istio().NetworkingV1alpha3().WorkloadEntries(oldWE.GetNamespace()).Delete(ctx, oldWE.GetName(), metav1.DeleteOptions{})
@@ -16,46 +16,124 @@ package adsc
 
 import (
 	"context"
+	"crypto/tls"
 	"errors"
 	"fmt"
 	"math"
+	"net"
+	"sort"
+	"sync"
 	"time"
 
+	envoycfgcorev3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
 	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	rpcstatus "google.golang.org/genproto/googleapis/rpc/status"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/backoff"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/anypb"
 	istiolog "istio.io/istio/pkg/log"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/utils/clock"
 )
 
 const (
 	defaultClientMaxReceiveMessageSize = math.MaxInt32
 	defaultInitialConnWindowSize       = 1024 * 1024 // default gRPC InitialWindowSize
 	defaultInitialWindowSize           = 1024 * 1024 // default gRPC ConnWindowSize
+
+	defaultMaxReconnectDelay = 2 * time.Minute
 )
 
 type ADSCConfig struct {
-	RemoteName     string
-	DiscoveryAddr  string
-	Authority      string
-	Handlers       map[string]ResponseHandler
-	ReconnectDelay time.Duration
+	RemoteName    string
+	DiscoveryAddr string
+	Authority     string
+	Handlers      map[string]ResponseHandler
+	// ReconnectDelay is the initial delay of reconnecting to the server. The delay grows exponentially with jitter
+	// after each failed attempt up to MaxReconnectDelay, and is reset once the client syncs again.
+	ReconnectDelay    time.Duration
+	MaxReconnectDelay time.Duration
+	// NodeID identifies this client in discovery requests, so that the server can generate resources specific to the client.
+	NodeID string
+	// TLSConfig enables mTLS of the connection to the server. If not set, the connection is expected to be secured by the sidecar.
+	TLSConfig *tls.Config
+
+	// dialer replaces the default dialer of the connection, so that tests can connect to in-memory servers.
+	dialer func(ctx context.Context, addr string) (net.Conn, error)
+}
+
+// ConnectionState describes the state of the connection to the ADS server.
+type ConnectionState string
+
+const (
+	// Connecting means that the client is establishing the connection and has not received resources yet.
+	Connecting ConnectionState = "Connecting"
+	// Synced means that all responses received since connecting were handled successfully.
+	Synced ConnectionState = "Synced"
+	// Degraded means that the connection was lost after the client had synced, or that the last response
+	// could not be handled, so the resources handled before may be stale.
+	Degraded ConnectionState = "Degraded"
+)
+
+// Status describes the health of the connection to the ADS server.
+type Status struct {
+	State ConnectionState
+	// LastSyncTime is the time when the last response from the ADS server was handled successfully.
+	LastSyncTime time.Time
+	// LastError is the last error that occurred while connecting to the server or handling its responses.
+	// It is reset when a response is handled successfully.
+	LastError error
+	// DisconnectedSince is the time when the connection to the server was lost. It is zero while the client
+	// receives responses, and it is not updated by failed attempts to reconnect.
+	DisconnectedSince time.Time
 }
 
+// errFallback is returned by a session, which found that the server does not support incremental XDS.
+var errFallback = errors.New("server does not support incremental XDS")
+
 type ADSC struct {
-	stream discovery.AggregatedDiscoveryService_StreamAggregatedResourcesClient
-	conn   *grpc.ClientConn
-	cfg    *ADSCConfig
-	log    *istiolog.Scope
+	conn  *grpc.ClientConn
+	cfg   *ADSCConfig
+	log   *istiolog.Scope
+	clock clock.Clock
+
+	// The following fields are accessed only by the goroutine executing Run, so they are not guarded by the mutex.
+	// stateOfTheWorld is set when the server does not support incremental XDS. It is reset when the state-of-the-world
+	// stream breaks, so that incremental XDS is used again once the server is upgraded.
+	stateOfTheWorld bool
+	// resources accepted by handlers over delta stream by type URL and resource name. They are kept across reconnects,
+	// so that the server sends only differences.
+	resources map[string]map[string]*discovery.Resource
+	// acceptedVersions stores the last version accepted over state-of-the-world stream by type URL.
+	acceptedVersions map[string]string
+
+	mu     sync.RWMutex
+	status Status
+	// cancel stops the running client and done is closed when Run returns.
+	cancel context.CancelFunc
+	done   chan struct{}
+	closed bool
 }
 
 func New(opts *ADSCConfig) (*ADSC, error) {
 	if opts == nil {
 		return nil, errors.New("adsc: opts is nil")
 	}
+	if opts.MaxReconnectDelay == 0 {
+		opts.MaxReconnectDelay = defaultMaxReconnectDelay
+	}
+	opts.MaxReconnectDelay = max(opts.MaxReconnectDelay, opts.ReconnectDelay)
 	adsc := &ADSC{
-		cfg: opts,
-		log: istiolog.RegisterScope("adsc", "Aggregated Discovery Service Client").WithLabels("peer", opts.RemoteName),
+		cfg:              opts,
+		resources:        make(map[string]map[string]*discovery.Resource),
+		acceptedVersions: make(map[string]string),
+		log:              istiolog.RegisterScope("adsc", "Aggregated Discovery Service Client").WithLabels("peer", opts.RemoteName),
+		clock:            clock.RealClock{},
+		status:           Status{State: Connecting},
 	}
 	if err := adsc.dial(); err != nil {
 		return nil, err
@@ -64,96 +142,335 @@ func New(opts *ADSCConfig) (*ADSC, error) {
 	return adsc, nil
 }
 
+// Run subscribes to resources of all types supported by the handlers and blocks until the context is done
+// or the client is closed. If the client was closed before, Run returns immediately. Whenever the stream breaks, the client reconnects with exponential backoff.
+// The client uses incremental XDS and falls back to state-of-the-world XDS if the server does not support it.
+// Incremental XDS is tried again after every reconnect, as the server may have been upgraded in the meantime.
 func (a *ADSC) Run(ctx context.Context) error {
+	a.mu.Lock()
+	if a.closed {
+		a.mu.Unlock()
+		return nil
+	}
+	if a.done != nil {
+		a.mu.Unlock()
+		return errors.New("adsc: client is already running")
+	}
+	ctx, a.cancel = context.WithCancel(ctx)
+	a.done = make(chan struct{})
+	a.mu.Unlock()
+	defer close(a.done)
+
 	client := discovery.NewAggregatedDiscoveryServiceClient(a.conn)
+	reconnectBackoff := a.newBackoff()
+	for {
+		var synced bool
+		var err error
+		if a.stateOfTheWorld {
+			synced, err = a.runStateOfTheWorld(ctx, client)
+			a.stateOfTheWorld = false
+		} else {
+			synced, err = a.runDelta(ctx, client)
+		}
+		if ctx.Err() != nil {
+			a.log.Infof("stopped FDS client: %v", ctx.Err())
+			return nil
+		}
+		if errors.Is(err, errFallback) {
+			a.log.Infof("ADS server does not support incremental XDS, falling back to state-of-the-world XDS")
+			a.stateOfTheWorld = true
+			continue
+		}
+		if synced {
+			reconnectBackoff = a.newBackoff()
+		}
+		a.recordDisconnect(err)
 
-	var err error
-	if a.stream, err = client.StreamAggregatedResources(ctx); err != nil {
-		return fmt.Errorf("failed setting resource stream: %w", err)
+		delay := reconnectBackoff.Step()
+		a.log.Errorf("connection to ADS server %s failed, will reconnect in %s: %v", a.cfg.DiscoveryAddr, delay, err)
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-a.clock.After(delay):
+		}
 	}
+}
+
+func (a *ADSC) newBackoff() *wait.Backoff {
+	return &wait.Backoff{
+		Duration: a.cfg.ReconnectDelay,
+		Factor:   2,
+		Jitter:   0.5,
+		Steps:    math.MaxInt32,
+		Cap:      a.cfg.MaxReconnectDelay,
+	}
+}
+
+// runStateOfTheWorld subscribes to resources over state-of-the-world stream and handles responses until the stream breaks.
+// It returns true if any response was handled successfully.
+func (a *ADSC) runStateOfTheWorld(ctx context.Context, client discovery.AggregatedDiscoveryServiceClient) (bool, error) {
+	// The stream is canceled on return, so that it does not leak when the session ends due to a failed send.
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
 
-	for k, _ := range a.cfg.Handlers {
-		discoveryRequest := &discovery.DiscoveryRequest{TypeUrl: k}
-		if errSend := a.Send(discoveryRequest); errSend != nil {
-			a.log.Errorf("[%s] failed requesting initial discovery sync: %+v", k, errSend)
+	stream, err := client.StreamAggregatedResources(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed setting resource stream: %w", err)
+	}
+	for typeUrl := range a.cfg.Handlers {
+		discoveryRequest := &discovery.DiscoveryRequest{
+			TypeUrl: typeUrl,
+			Node:    &envoycfgcorev3.Node{Id: a.cfg.NodeID},
+		}
+		a.log.Infof("Sending Discovery Request to ADS server: %s", discoveryRequest.String())
+		if err := stream.Send(discoveryRequest); err != nil {
+			return false, fmt.Errorf("failed requesting initial discovery sync of %s: %w", typeUrl, err)
 		}
 	}
 
-	go a.handleRecv(ctx)
+	synced := false
+	for {
+		msg, err := stream.Recv()
+		if err != nil {
+			return synced, fmt.Errorf("connection closed: %w", err)
+		}
+		a.recordConnected()
+		a.log.Infof("received response for %s: %v", msg.TypeUrl, msg.Resources)
+		handler, found := a.cfg.Handlers[msg.TypeUrl]
+		if !found {
+			a.log.Infof("no handler found for type: %s", msg.TypeUrl)
+			continue
+		}
 
-	return nil
+		// ACK carries the version of the handled response, while NACK carries the last accepted version.
+		ack := &discovery.DiscoveryRequest{
+			TypeUrl:       msg.TypeUrl,
+			ResponseNonce: msg.Nonce,
+		}
+		errHandle := handler.Handle(a.cfg.RemoteName, msg.Resources)
+		if errHandle != nil {
+			a.log.Infof("error handling resource %s: %v", msg.TypeUrl, errHandle)
+			a.recordError(fmt.Errorf("failed handling %s: %w", msg.TypeUrl, errHandle))
+			ack.ErrorDetail = &rpcstatus.Status{
+				Code:    int32(codes.InvalidArgument),
+				Message: errHandle.Error(),
+			}
+		} else {
+			a.acceptedVersions[msg.TypeUrl] = msg.VersionInfo
+			a.recordSync()
+			synced = true
+		}
+		ack.VersionInfo = a.acceptedVersions[msg.TypeUrl]
+		if err := stream.Send(ack); err != nil {
+			return synced, fmt.Errorf("failed to acknowledge response for %s: %w", msg.TypeUrl, err)
+		}
+		if errHandle != nil {
+			return synced, rejected(stream, msg.TypeUrl, errHandle)
+		}
+	}
 }
 
-func (a *ADSC) Restart(ctx context.Context) {
-	a.log.Infof("reconnecting to ADS server %s", a.cfg.DiscoveryAddr)
-	if err := a.Run(ctx); err != nil {
-		a.log.Errorf("failed to connect to ADS server %s, will reconnect in %s: %v", a.cfg.DiscoveryAddr, a.cfg.ReconnectDelay, err)
-		time.AfterFunc(a.cfg.ReconnectDelay, func() {
-			if errCtx := ctx.Err(); errCtx != nil {
-				a.log.Infof("Parent ctx is done: %v", errCtx)
-				return
+// runDelta subscribes to resources over delta stream and handles responses until the stream breaks.
+// It returns true if any response was handled successfully.
+func (a *ADSC) runDelta(ctx context.Context, client discovery.AggregatedDiscoveryServiceClient) (bool, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	stream, err := client.DeltaAggregatedResources(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed setting delta resource stream: %w", err)
+	}
+	for typeUrl := range a.cfg.Handlers {
+		// Resources received before reconnecting are reported to the server, so that it sends only differences.
+		initialVersions := make(map[string]string, len(a.resources[typeUrl]))
+		for name, res := range a.resources[typeUrl] {
+			initialVersions[name] = res.GetVersion()
+		}
+		discoveryRequest := &discovery.DeltaDiscoveryRequest{
+			TypeUrl:                 typeUrl,
+			Node:                    &envoycfgcorev3.Node{Id: a.cfg.NodeID},
+			InitialResourceVersions: initialVersions,
+		}
+		a.log.Infof("Sending Delta Discovery Request to ADS server: %s", discoveryRequest.String())
+		if err := stream.Send(discoveryRequest); err != nil {
+			return false, fmt.Errorf("failed requesting initial delta discovery sync of %s: %w", typeUrl, err)
+		}
+	}
+
+	synced := false
+	for {
+		msg, err := stream.Recv()
+		if err != nil {
+			if status.Code(err) == codes.Unimplemented {
+				return synced, errFallback
 			}
+			return synced, fmt.Errorf("connection closed: %w", err)
+		}
+		a.recordConnected()
+		a.log.Infof("received delta response for %s: %d updated, %d removed", msg.TypeUrl, len(msg.Resources), len(msg.RemovedResources))
+
+		handler, found := a.cfg.Handlers[msg.TypeUrl]
+		if !found {
+			a.log.Infof("no handler found for type: %s", msg.TypeUrl)
+			continue
+		}
 
-			a.Restart(ctx)
-		})
+		ack := &discovery.DeltaDiscoveryRequest{
+			TypeUrl:       msg.TypeUrl,
+			ResponseNonce: msg.Nonce,
+		}
+		// Resources are committed only when the handler accepts them, so that rejected changes are not reported
+		// to the server as received when reconnecting.
+		resources, updated := a.applyDelta(msg)
+		errHandle := handler.Handle(a.cfg.RemoteName, resources)
+		if errHandle != nil {
+			a.log.Infof("error handling resource %s: %v", msg.TypeUrl, errHandle)
+			a.recordError(fmt.Errorf("failed handling %s: %w", msg.TypeUrl, errHandle))
+			ack.ErrorDetail = &rpcstatus.Status{
+				Code:    int32(codes.InvalidArgument),
+				Message: errHandle.Error(),
+			}
+		} else {
+			a.resources[msg.TypeUrl] = updated
+			a.recordSync()
+			synced = true
+		}
+		if err := stream.Send(ack); err != nil {
+			return synced, fmt.Errorf("failed to acknowledge delta response for %s: %w", msg.TypeUrl, err)
+		}
+		if errHandle != nil {
+			return synced, rejected(stream, msg.TypeUrl, errHandle)
+		}
 	}
 }
 
-func (a *ADSC) Send(req *discovery.DiscoveryRequest) error {
-	req.ResponseNonce = time.Now().String()
-	a.log.Infof("Sending Discovery Request to ADS server: %s", req.String())
-	return a.stream.Send(req)
+// rejected ends the session after the response of the given type was rejected. The server does not send rejected
+// resources again on the same stream, so the client reconnects to receive them again, and the handler can accept them
+// once the reason of the rejection is gone.
+func rejected(stream grpc.ClientStream, typeUrl string, err error) error {
+	// Closing the stream for sending lets the server receive the NACK before the stream is canceled.
+	_ = stream.CloseSend()
+	return fmt.Errorf("rejected response for %s: %w", typeUrl, err)
+}
+
+// Status returns the current health of the connection to the ADS server.
+func (a *ADSC) Status() Status {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.status
+}
+
+// recordError records failure to handle a response. The connection is still established.
+func (a *ADSC) recordError(err error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.status.LastError = err
+	a.status.State = Degraded
+}
+
+// recordDisconnect records a broken connection. Resources received before remain in use, so the client is degraded
+// if it had synced before, and otherwise it is still connecting.
+func (a *ADSC) recordDisconnect(err error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.status.LastError = err
+	if a.status.DisconnectedSince.IsZero() {
+		a.status.DisconnectedSince = time.Now()
+	}
+	if a.status.LastSyncTime.IsZero() {
+		a.status.State = Connecting
+	} else {
+		a.status.State = Degraded
+	}
+}
+
+func (a *ADSC) recordConnected() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.status.DisconnectedSince = time.Time{}
+}
+
+func (a *ADSC) recordSync() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.status.LastSyncTime = time.Now()
+	a.status.LastError = nil
+	a.status.State = Synced
+}
+
+// Close stops the client, waits until Run returns, and closes the connection to the ADS server.
+// Run must not be called afterward. Closing the client more than once is a no-op.
+func (a *ADSC) Close() error {
+	a.mu.Lock()
+	if a.closed {
+		a.mu.Unlock()
+		return nil
+	}
+	a.closed = true
+	cancel, done := a.cancel, a.done
+	a.mu.Unlock()
+
+	if cancel != nil {
+		cancel()
+		<-done
+	}
+	return a.conn.Close()
 }
 
 func (a *ADSC) dial() error {
 	backoffConfig := backoff.DefaultConfig
-	backoffConfig.MaxDelay = a.cfg.ReconnectDelay
+	backoffConfig.MaxDelay = a.cfg.MaxReconnectDelay
 
-	var err error
-	a.conn, err = grpc.NewClient(
-		a.cfg.DiscoveryAddr,
+	transportCredentials := insecure.NewCredentials()
+	if a.cfg.TLSConfig != nil {
+		transportCredentials = credentials.NewTLS(a.cfg.TLSConfig)
+	}
+
+	opts := []grpc.DialOption{
 		grpc.WithAuthority(a.cfg.Authority),
-		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithTransportCredentials(transportCredentials),
 		grpc.WithInitialWindowSize(int32(defaultInitialWindowSize)),
 		grpc.WithInitialConnWindowSize(int32(defaultInitialConnWindowSize)),
 		grpc.WithDefaultCallOptions(grpc.MaxCallRecvMsgSize(defaultClientMaxReceiveMessageSize)),
 		grpc.WithConnectParams(grpc.ConnectParams{
-			Backoff:           backoff.DefaultConfig,
+			Backoff:           backoffConfig,
 			MinConnectTimeout: a.cfg.ReconnectDelay,
 		}),
-	)
+	}
+	if a.cfg.dialer != nil {
+		opts = append(opts, grpc.WithContextDialer(a.cfg.dialer))
+	}
+
+	var err error
+	a.conn, err = grpc.NewClient(a.cfg.DiscoveryAddr, opts...)
 	if err != nil {
 		return fmt.Errorf("failed to establish connection to the ADS server %s: %w", a.cfg.DiscoveryAddr, err)
 	}
 	return nil
 }
 
-func (a *ADSC) handleRecv(ctx context.Context) {
+// applyDelta returns the complete set of resources of the given type sorted by name, and the accepted resources
+// updated by the response. The accepted resources are not modified.
+func (a *ADSC) applyDelta(msg *discovery.DeltaDiscoveryResponse) ([]*anypb.Any, map[string]*discovery.Resource) {
+	resources := make(map[string]*discovery.Resource, len(a.resources[msg.TypeUrl])+len(msg.Resources))
+	for name, res := range a.resources[msg.TypeUrl] {
+		resources[name] = res
+	}
+	for _, res := range msg.Resources {
+		resources[res.GetName()] = res
+	}
+	for _, name := range msg.RemovedResources {
+		delete(resources, name)
+	}
 
-loop:
-	for {
-		select {
-		case <-ctx.Done():
-			break loop
-		default:
-
-			var err error
-			msg, err := a.stream.Recv()
-			if err != nil {
-				a.log.Errorf("connection closed with err: %v", err)
-				time.AfterFunc(a.cfg.ReconnectDelay, func() {
-					a.Restart(ctx)
-				})
-				return
-			}
-			a.log.Infof("received response for %s: %v", msg.TypeUrl, msg.Resources)
-			if handler, found := a.cfg.Handlers[msg.TypeUrl]; found {
-				if err := handler.Handle(a.cfg.RemoteName, msg.Resources); err != nil {
-					a.log.Infof("error handling resource %s: %v", msg.TypeUrl, err)
-				}
-			} else {
-				a.log.Infof("no handler found for type: %s", msg.TypeUrl)
-			}
-		}
+	names := make([]string, 0, len(resources))
+	for name := range resources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	out := make([]*anypb.Any, 0, len(names))
+	for _, name := range names {
+		out = append(out, resources[name].GetResource())
 	}
+	return out, resources
 }
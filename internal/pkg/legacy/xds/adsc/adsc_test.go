@@ -0,0 +1,491 @@
+// Copyright Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the License);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an AS IS BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package adsc
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"net"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	"go.uber.org/goleak"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+	testingclock "k8s.io/utils/clock/testing"
+)
+
+const (
+	testTypeUrl = "federation.openshift-service-mesh.io/v1alpha1/Test"
+	waitTimeout = 5 * time.Second
+)
+
+// fakeServer hands every stream opened by the client to the test, which then drives the stream
+// until it ends the stream by calling end.
+type fakeServer struct {
+	deltaUnsupported atomic.Bool
+	deltaStreams     chan *fakeStream[*discovery.DeltaDiscoveryRequest, *discovery.DeltaDiscoveryResponse]
+	sotwStreams      chan *fakeStream[*discovery.DiscoveryRequest, *discovery.DiscoveryResponse]
+}
+
+type fakeStream[Req any, Resp any] struct {
+	stream interface {
+		Send(Resp) error
+		Recv() (Req, error)
+	}
+	ended chan error
+}
+
+var _ discovery.AggregatedDiscoveryServiceServer = (*fakeServer)(nil)
+
+func (s *fakeServer) StreamAggregatedResources(stream discovery.AggregatedDiscoveryService_StreamAggregatedResourcesServer) error {
+	fake := &fakeStream[*discovery.DiscoveryRequest, *discovery.DiscoveryResponse]{stream: stream, ended: make(chan error, 1)}
+	s.sotwStreams <- fake
+	return fake.wait(stream.Context())
+}
+
+func (s *fakeServer) DeltaAggregatedResources(stream discovery.AggregatedDiscoveryService_DeltaAggregatedResourcesServer) error {
+	if s.deltaUnsupported.Load() {
+		return status.Error(codes.Unimplemented, "incremental XDS is not supported")
+	}
+	fake := &fakeStream[*discovery.DeltaDiscoveryRequest, *discovery.DeltaDiscoveryResponse]{stream: stream, ended: make(chan error, 1)}
+	s.deltaStreams <- fake
+	return fake.wait(stream.Context())
+}
+
+func (f *fakeStream[Req, Resp]) wait(ctx context.Context) error {
+	select {
+	case err := <-f.ended:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// end closes the stream with the given error, as if the server dropped the connection.
+func (f *fakeStream[Req, Resp]) end(err error) {
+	f.ended <- err
+}
+
+func (f *fakeStream[Req, Resp]) recv(t *testing.T) Req {
+	t.Helper()
+	type result struct {
+		req Req
+		err error
+	}
+	received := make(chan result, 1)
+	go func() {
+		req, err := f.stream.Recv()
+		received <- result{req, err}
+	}()
+	select {
+	case r := <-received:
+		if r.err != nil {
+			t.Fatalf("failed to receive request: %v", r.err)
+		}
+		return r.req
+	case <-time.After(waitTimeout):
+		t.Fatal("timed out waiting for request")
+	}
+	panic("unreachable")
+}
+
+func (f *fakeStream[Req, Resp]) send(t *testing.T, resp Resp) {
+	t.Helper()
+	if err := f.stream.Send(resp); err != nil {
+		t.Fatalf("failed to send response: %v", err)
+	}
+}
+
+func accept[T any](t *testing.T, streams <-chan T) T {
+	t.Helper()
+	select {
+	case stream := <-streams:
+		return stream
+	case <-time.After(waitTimeout):
+		t.Fatal("timed out waiting for the client to open a stream")
+	}
+	panic("unreachable")
+}
+
+func startFakeServer(t *testing.T) (*fakeServer, *bufconn.Listener) {
+	t.Helper()
+	srv := &fakeServer{
+		deltaStreams: make(chan *fakeStream[*discovery.DeltaDiscoveryRequest, *discovery.DeltaDiscoveryResponse], 10),
+		sotwStreams:  make(chan *fakeStream[*discovery.DiscoveryRequest, *discovery.DiscoveryResponse], 10),
+	}
+	listener := bufconn.Listen(1024 * 1024)
+	grpcServer := grpc.NewServer()
+	discovery.RegisterAggregatedDiscoveryServiceServer(grpcServer, srv)
+	go func() {
+		_ = grpcServer.Serve(listener)
+	}()
+	t.Cleanup(grpcServer.Stop)
+	return srv, listener
+}
+
+// recordingHandler records values of handled resources, and rejects resources while err is set.
+type recordingHandler struct {
+	mu      sync.Mutex
+	err     error
+	handled chan []string
+}
+
+func newRecordingHandler() *recordingHandler {
+	return &recordingHandler{handled: make(chan []string, 10)}
+}
+
+func (h *recordingHandler) Handle(_ string, resources []*anypb.Any) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.err != nil {
+		return h.err
+	}
+	values := make([]string, 0, len(resources))
+	for _, res := range resources {
+		value := &wrapperspb.StringValue{}
+		if err := res.UnmarshalTo(value); err != nil {
+			return err
+		}
+		values = append(values, value.GetValue())
+	}
+	h.handled <- values
+	return nil
+}
+
+func (h *recordingHandler) expectHandled(t *testing.T, expected ...string) {
+	t.Helper()
+	select {
+	case values := <-h.handled:
+		if !slices.Equal(values, expected) {
+			t.Errorf("expected handled resources %v, got %v", expected, values)
+		}
+	case <-time.After(waitTimeout):
+		t.Fatalf("timed out waiting for resources %v", expected)
+	}
+}
+
+func newTestClient(t *testing.T, listener *bufconn.Listener, handler ResponseHandler) *ADSC {
+	t.Helper()
+	client, err := New(&ADSCConfig{
+		RemoteName:     "east",
+		DiscoveryAddr:  "passthrough:///fds",
+		NodeID:         "west",
+		Handlers:       map[string]ResponseHandler{testTypeUrl: handler},
+		ReconnectDelay: 10 * time.Millisecond,
+		dialer: func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		},
+	})
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+	return client
+}
+
+func runClient(t *testing.T, client *ADSC) {
+	t.Helper()
+	go func() {
+		_ = client.Run(context.Background())
+	}()
+	t.Cleanup(func() {
+		_ = client.Close()
+	})
+}
+
+func resource(t *testing.T, name, version, value string) *discovery.Resource {
+	t.Helper()
+	res, err := anypb.New(wrapperspb.String(value))
+	if err != nil {
+		t.Fatalf("failed to marshal resource: %v", err)
+	}
+	return &discovery.Resource{Name: name, Version: version, Resource: res}
+}
+
+func TestDeltaSubscription(t *testing.T) {
+	srv, listener := startFakeServer(t)
+	handler := newRecordingHandler()
+	runClient(t, newTestClient(t, listener, handler))
+
+	stream := accept(t, srv.deltaStreams)
+	subscription := stream.recv(t)
+	if subscription.GetTypeUrl() != testTypeUrl || subscription.GetNode().GetId() != "west" {
+		t.Errorf("unexpected subscription: %v", subscription)
+	}
+	if len(subscription.GetInitialResourceVersions()) != 0 {
+		t.Errorf("expected no initial resource versions, got %v", subscription.GetInitialResourceVersions())
+	}
+
+	// Initial sync delivers the complete set of resources.
+	stream.send(t, &discovery.DeltaDiscoveryResponse{
+		TypeUrl:   testTypeUrl,
+		Nonce:     "1",
+		Resources: []*discovery.Resource{resource(t, "b", "1", "b-1"), resource(t, "a", "1", "a-1")},
+	})
+	handler.expectHandled(t, "a-1", "b-1")
+	if ack := stream.recv(t); ack.GetResponseNonce() != "1" || ack.GetErrorDetail() != nil {
+		t.Errorf("expected ACK of nonce 1, got %v", ack)
+	}
+
+	// Updates and removals are applied to resources received before.
+	stream.send(t, &discovery.DeltaDiscoveryResponse{
+		TypeUrl:          testTypeUrl,
+		Nonce:            "2",
+		Resources:        []*discovery.Resource{resource(t, "c", "1", "c-1"), resource(t, "a", "2", "a-2")},
+		RemovedResources: []string{"b"},
+	})
+	handler.expectHandled(t, "a-2", "c-1")
+	if ack := stream.recv(t); ack.GetResponseNonce() != "2" || ack.GetErrorDetail() != nil {
+		t.Errorf("expected ACK of nonce 2, got %v", ack)
+	}
+
+	// After reconnecting, the client reports versions of received resources, so that the server sends only differences.
+	stream.end(status.Error(codes.Unavailable, "connection dropped"))
+	stream = accept(t, srv.deltaStreams)
+	subscription = stream.recv(t)
+	expectedVersions := map[string]string{"a": "2", "c": "1"}
+	if !maps.Equal(subscription.GetInitialResourceVersions(), expectedVersions) {
+		t.Errorf("expected initial resource versions %v, got %v", expectedVersions, subscription.GetInitialResourceVersions())
+	}
+	stream.send(t, &discovery.DeltaDiscoveryResponse{
+		TypeUrl:          testTypeUrl,
+		Nonce:            "3",
+		RemovedResources: []string{"c"},
+	})
+	handler.expectHandled(t, "a-2")
+}
+
+func TestRejectedDeltaIsNotCommitted(t *testing.T) {
+	srv, listener := startFakeServer(t)
+	handler := newRecordingHandler()
+	runClient(t, newTestClient(t, listener, handler))
+
+	stream := accept(t, srv.deltaStreams)
+	stream.recv(t)
+	stream.send(t, &discovery.DeltaDiscoveryResponse{
+		TypeUrl:   testTypeUrl,
+		Nonce:     "1",
+		Resources: []*discovery.Resource{resource(t, "a", "1", "a-1"), resource(t, "b", "1", "b-1")},
+	})
+	handler.expectHandled(t, "a-1", "b-1")
+	stream.recv(t)
+
+	handler.mu.Lock()
+	handler.err = errors.New("too many services withdrawn")
+	handler.mu.Unlock()
+	stream.send(t, &discovery.DeltaDiscoveryResponse{
+		TypeUrl:          testTypeUrl,
+		Nonce:            "2",
+		RemovedResources: []string{"a", "b"},
+	})
+	if nack := stream.recv(t); nack.GetResponseNonce() != "2" || nack.GetErrorDetail() == nil {
+		t.Errorf("expected NACK of nonce 2, got %v", nack)
+	}
+
+	// The client reconnects and reports only accepted resources, so that the server sends the rejected removal again.
+	stream = accept(t, srv.deltaStreams)
+	subscription := stream.recv(t)
+	expectedVersions := map[string]string{"a": "1", "b": "1"}
+	if !maps.Equal(subscription.GetInitialResourceVersions(), expectedVersions) {
+		t.Errorf("expected initial resource versions %v, got %v", expectedVersions, subscription.GetInitialResourceVersions())
+	}
+
+	handler.mu.Lock()
+	handler.err = nil
+	handler.mu.Unlock()
+	stream.send(t, &discovery.DeltaDiscoveryResponse{
+		TypeUrl:          testTypeUrl,
+		Nonce:            "3",
+		RemovedResources: []string{"a", "b"},
+	})
+	handler.expectHandled(t)
+}
+
+func TestFallbackToStateOfTheWorld(t *testing.T) {
+	srv, listener := startFakeServer(t)
+	srv.deltaUnsupported.Store(true)
+	handler := newRecordingHandler()
+	runClient(t, newTestClient(t, listener, handler))
+
+	stream := accept(t, srv.sotwStreams)
+	if subscription := stream.recv(t); subscription.GetTypeUrl() != testTypeUrl || subscription.GetNode().GetId() != "west" {
+		t.Errorf("unexpected subscription: %v", subscription)
+	}
+	stream.send(t, &discovery.DiscoveryResponse{
+		TypeUrl:     testTypeUrl,
+		VersionInfo: "v1",
+		Nonce:       "1",
+		Resources:   []*anypb.Any{resource(t, "a", "", "a-1").GetResource()},
+	})
+	handler.expectHandled(t, "a-1")
+	if ack := stream.recv(t); ack.GetResponseNonce() != "1" || ack.GetVersionInfo() != "v1" {
+		t.Errorf("expected ACK of version v1, got %v", ack)
+	}
+
+	// Once the server supports incremental XDS, the client uses it after reconnecting.
+	srv.deltaUnsupported.Store(false)
+	stream.end(status.Error(codes.Unavailable, "server upgraded"))
+	deltaStream := accept(t, srv.deltaStreams)
+	if subscription := deltaStream.recv(t); subscription.GetTypeUrl() != testTypeUrl {
+		t.Errorf("unexpected delta subscription: %v", subscription)
+	}
+}
+
+// backoffClock reports reconnect delays, so that tests advance the fake time only once the client waits to reconnect.
+type backoffClock struct {
+	*testingclock.FakeClock
+	delays chan time.Duration
+}
+
+func (c *backoffClock) After(d time.Duration) <-chan time.Time {
+	ch := c.FakeClock.After(d)
+	c.delays <- d
+	return ch
+}
+
+func (c *backoffClock) expectDelay(t *testing.T, minDelay time.Duration) time.Duration {
+	t.Helper()
+	select {
+	case delay := <-c.delays:
+		// The delay grows by jitter of up to a half of the expected delay.
+		if delay < minDelay || delay > minDelay*3/2 {
+			t.Errorf("expected reconnect delay between %s and %s, got %s", minDelay, minDelay*3/2, delay)
+		}
+		return delay
+	case <-time.After(waitTimeout):
+		t.Fatal("timed out waiting for the client to reconnect")
+	}
+	return 0
+}
+
+func expectState(t *testing.T, client *ADSC, expected ConnectionState) {
+	t.Helper()
+	if state := client.Status().State; state != expected {
+		t.Errorf("expected state %s, got %s", expected, state)
+	}
+}
+
+func TestReconnectWithBackoff(t *testing.T) {
+	srv, listener := startFakeServer(t)
+	handler := newRecordingHandler()
+	client := newTestClient(t, listener, handler)
+	fakeClock := &backoffClock{FakeClock: testingclock.NewFakeClock(time.Now()), delays: make(chan time.Duration, 10)}
+	client.clock = fakeClock
+	reconnectDelay := client.cfg.ReconnectDelay
+	runClient(t, client)
+
+	// The client keeps connecting with growing delays until it receives resources.
+	stream := accept(t, srv.deltaStreams)
+	stream.recv(t)
+	expectState(t, client, Connecting)
+	stream.end(status.Error(codes.Unavailable, "connection dropped"))
+	fakeClock.Step(fakeClock.expectDelay(t, reconnectDelay))
+	expectState(t, client, Connecting)
+
+	stream = accept(t, srv.deltaStreams)
+	stream.recv(t)
+	stream.end(status.Error(codes.Unavailable, "connection dropped"))
+	fakeClock.Step(fakeClock.expectDelay(t, 2*reconnectDelay))
+
+	stream = accept(t, srv.deltaStreams)
+	stream.recv(t)
+	stream.send(t, &discovery.DeltaDiscoveryResponse{
+		TypeUrl:   testTypeUrl,
+		Nonce:     "1",
+		Resources: []*discovery.Resource{resource(t, "a", "1", "a-1")},
+	})
+	handler.expectHandled(t, "a-1")
+	stream.recv(t)
+	expectState(t, client, Synced)
+
+	// Once the client synced, the connection is degraded after it breaks, and the backoff starts over.
+	stream.end(status.Error(codes.Unavailable, "connection dropped"))
+	fakeClock.Step(fakeClock.expectDelay(t, reconnectDelay))
+	expectState(t, client, Degraded)
+	if client.Status().DisconnectedSince.IsZero() {
+		t.Error("expected the time of disconnection to be recorded")
+	}
+
+	stream = accept(t, srv.deltaStreams)
+	if subscription := stream.recv(t); subscription.GetInitialResourceVersions()["a"] != "1" {
+		t.Errorf("expected initial version of a to be reported after reconnecting, got %v", subscription.GetInitialResourceVersions())
+	}
+}
+
+func TestCloseDoesNotLeakGoroutines(t *testing.T) {
+	testCases := []struct {
+		name string
+		// await brings the client to the state in which it is closed.
+		await func(t *testing.T, srv *fakeServer, fakeClock *backoffClock, reconnectDelay time.Duration)
+	}{{
+		name: "close during backoff",
+		await: func(t *testing.T, srv *fakeServer, fakeClock *backoffClock, reconnectDelay time.Duration) {
+			stream := accept(t, srv.deltaStreams)
+			stream.recv(t)
+			stream.end(status.Error(codes.Unavailable, "connection dropped"))
+			fakeClock.expectDelay(t, reconnectDelay)
+		},
+	}, {
+		name: "close while receiving",
+		await: func(t *testing.T, srv *fakeServer, _ *backoffClock, _ time.Duration) {
+			stream := accept(t, srv.deltaStreams)
+			stream.recv(t)
+			stream.send(t, &discovery.DeltaDiscoveryResponse{TypeUrl: testTypeUrl, Nonce: "1"})
+			stream.recv(t)
+		},
+	}}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ignoreRunning := goleak.IgnoreCurrent()
+			srv, listener := startFakeServer(t)
+			client := newTestClient(t, listener, newRecordingHandler())
+			fakeClock := &backoffClock{FakeClock: testingclock.NewFakeClock(time.Now()), delays: make(chan time.Duration, 10)}
+			client.clock = fakeClock
+
+			stopped := make(chan error, 1)
+			go func() {
+				stopped <- client.Run(context.Background())
+			}()
+			tc.await(t, srv, fakeClock, client.cfg.ReconnectDelay)
+
+			if err := client.Close(); err != nil {
+				t.Errorf("failed to close client: %v", err)
+			}
+			select {
+			case err := <-stopped:
+				if err != nil {
+					t.Errorf("expected Run to return without error, got %v", err)
+				}
+			case <-time.After(waitTimeout):
+				t.Fatal("Run did not return after closing the client")
+			}
+			if err := client.Run(context.Background()); err != nil {
+				t.Errorf("expected Run of a closed client to return immediately, got %v", err)
+			}
+
+			listener.Close()
+			goleak.VerifyNone(t, ignoreRunning, goleak.IgnoreTopFunction("google.golang.org/grpc.(*Server).Serve"))
+		})
+	}
+}
@@ -17,6 +17,8 @@ package adsc
 import "google.golang.org/protobuf/types/known/anypb"
 
 // ResponseHandler handles response received from an XDS server.
+// If Handle returns an error, the response is rejected and the client reconnects, so that the server sends
+// the rejected resources again and the handler can reevaluate them.
 type ResponseHandler interface {
 	Handle(source string, resources []*anypb.Any) error
 }
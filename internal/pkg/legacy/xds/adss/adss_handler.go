@@ -16,6 +16,7 @@ package adss
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"math"
 	"os"
@@ -36,10 +37,10 @@ import (
 
 var log = istiolog.RegisterScope("adss", "Aggregated Discovery Service Server")
 
-// DeltaDiscoveryStream is a server interface for XDS.
-// DeltaDiscoveryStream is a server interface for Delta XDS.
 type (
-	DiscoveryStream      = discovery.AggregatedDiscoveryService_StreamAggregatedResourcesServer
+	// DiscoveryStream is a server interface for state-of-the-world XDS.
+	DiscoveryStream = discovery.AggregatedDiscoveryService_StreamAggregatedResourcesServer
+	// DeltaDiscoveryStream is a server interface for Delta XDS.
 	DeltaDiscoveryStream = discovery.AggregatedDiscoveryService_DeltaAggregatedResourcesServer
 )
 
@@ -48,13 +49,13 @@ type adsServer struct {
 	handlers         map[string]RequestHandler
 	subscribers      sync.Map
 	nextSubscriberID atomic.Uint64
-}
-
-// subscriber represents a client that is subscribed to XDS resources.
-type subscriber struct {
-	id          uint64
-	stream      DiscoveryStream
-	closeStream func()
+	nextNonce        atomic.Uint64
+	snapshots        *snapshotCache
+	sendTimeout      time.Duration
+	// authorize is nil if all subscribers are allowed.
+	authorize Authorizer
+	// trustXFCC enables identifying subscribers connected over plaintext by the XFCC header.
+	trustXFCC bool
 }
 
 var _ discovery.AggregatedDiscoveryServiceServer = (*adsServer)(nil)
@@ -63,23 +64,31 @@ func (adss *adsServer) StreamAggregatedResources(downstream DiscoveryStream) err
 	log.Info("New subscriber connected")
 	ctx, closeStream := context.WithCancel(downstream.Context())
 
-	sub := &subscriber{
-		id:          adss.nextSubscriberID.Add(1),
-		stream:      downstream,
-		closeStream: closeStream,
-	}
-
-	adss.subscribers.Store(sub.id, sub)
+	sub := newSubscriber(adss.nextSubscriberID.Add(1), closeStream)
+	sub.stream = downstream
 
-	go adss.recvFromStream(int64(sub.id), downstream)
+	go adss.recvFromStream(sub)
+	go adss.sendLoop(ctx, sub)
 
 	<-ctx.Done()
-	return nil
+	adss.unregister(sub)
+	return sub.closeError()
 }
 
-// DeltaAggregatedResources is not implemented.
+// DeltaAggregatedResources sends only added, modified and removed resources to the subscriber.
 func (adss *adsServer) DeltaAggregatedResources(downstream DeltaDiscoveryStream) error {
-	return status.Errorf(codes.Unimplemented, "Not Implemented")
+	log.Info("New delta subscriber connected")
+	ctx, closeStream := context.WithCancel(downstream.Context())
+
+	sub := newSubscriber(adss.nextSubscriberID.Add(1), closeStream)
+	sub.deltaStream = downstream
+
+	go adss.recvFromDeltaStream(sub)
+	go adss.sendLoop(ctx, sub)
+
+	<-ctx.Done()
+	adss.unregister(sub)
+	return sub.closeError()
 }
 
 var (
@@ -87,8 +96,39 @@ var (
 	subIDFmtStr   = `%0` + strconv.Itoa(maxUintDigits) + `d`
 )
 
-// recvFromStream receives discovery requests from the subscriber.
-func (adss *adsServer) recvFromStream(id int64, downstream DiscoveryStream) {
+// register identifies the subscriber and registers it for pushes. Subscribers, which are not authorized,
+// are rejected with PermissionDenied.
+func (adss *adsServer) register(ctx context.Context, sub *subscriber, node *envoycfgcorev3.Node) error {
+	sub.identity = identify(ctx, node, adss.trustXFCC)
+	if adss.authorize != nil {
+		if err := adss.authorize(sub.identity); err != nil {
+			return status.Errorf(codes.PermissionDenied, "subscriber %v is not authorized: %v", sub.identity.Names(), err)
+		}
+	}
+	log.Infof("Subscriber %s identified as %v", fmt.Sprintf(subIDFmtStr, sub.id), sub.identity.Names())
+	adss.subscribers.Store(sub.id, sub)
+	return nil
+}
+
+// unregister stops pushes to the subscriber. Snapshots and metrics of its identity are deleted, unless another subscriber
+// with the same identity is still connected.
+func (adss *adsServer) unregister(sub *subscriber) {
+	adss.subscribers.Delete(sub.id)
+	shared := false
+	adss.subscribers.Range(func(_, value any) bool {
+		shared = value.(*subscriber).identity == sub.identity
+		return !shared
+	})
+	if !shared {
+		adss.snapshots.delete(sub.identity)
+		deleteSubscriberMetrics(sub.identity)
+	}
+}
+
+// recvFromStream receives discovery requests from the subscriber. The subscriber is registered for pushes
+// after the first request is received, because its identity is determined from that request.
+func (adss *adsServer) recvFromStream(sub *subscriber) {
+	id, downstream := sub.id, sub.stream
 	log.Infof("Received from stream %d", id)
 	for {
 		discoveryRequest, err := downstream.Recv()
@@ -97,49 +137,85 @@ func (adss *adsServer) recvFromStream(id int64, downstream DiscoveryStream) {
 			break
 		}
 		log.Infof("Got discovery request from subscriber %s: %v", fmt.Sprintf(subIDFmtStr, id), discoveryRequest)
-		if discoveryRequest.GetVersionInfo() == "" {
-			resources, err := adss.generateResources(discoveryRequest.GetTypeUrl())
-			if err != nil {
-				// TODO: Do not push empty resources if there was an error during resource generation,
-				// because that may cause unintentional removal of the subscribed resources.
-				log.Errorf("failed to generate resources of type %s: %v", discoveryRequest.GetTypeUrl(), err)
-			}
-			log.Infof("Sending initial config snapshot for type %s: %s", discoveryRequest.GetTypeUrl(), resources)
-			if err := sendToStream(downstream, discoveryRequest.GetTypeUrl(), resources, strconv.FormatInt(time.Now().Unix(), 10)); err != nil {
-				log.Errorf("failed to send initial config snapshot for type %s: %v", discoveryRequest.GetTypeUrl(), err)
+		if _, registered := adss.subscribers.Load(id); !registered {
+			if err := adss.register(downstream.Context(), sub, discoveryRequest.GetNode()); err != nil {
+				log.Warnf("rejecting subscriber %s: %v", fmt.Sprintf(subIDFmtStr, id), err)
+				sub.closeWithError(err)
+				return
 			}
 		}
+		// Requests with a nonce acknowledge or reject previous responses, and requests without a nonce subscribe to the type.
+		if discoveryRequest.GetResponseNonce() != "" {
+			sub.recordResponse(discoveryRequest.GetTypeUrl(), discoveryRequest.GetResponseNonce(), discoveryRequest.GetVersionInfo(), discoveryRequest.GetErrorDetail())
+			continue
+		}
+		resources, err := adss.generateResources(discoveryRequest.GetTypeUrl(), sub.identity)
+		if err != nil {
+			// Sending an empty snapshot would remove all resources from the subscriber, so the subscriber
+			// receives resources with the next successful push.
+			log.Errorf("failed to generate resources of type %s: %v", discoveryRequest.GetTypeUrl(), err)
+			continue
+		}
+		log.Infof("Sending initial config snapshot for type %s: %s", discoveryRequest.GetTypeUrl(), resources)
+		sub.enqueue(discoveryRequest.GetTypeUrl(), resources, true)
 	}
+	sub.closeStream()
 }
 
-func (adss *adsServer) generateResources(typeUrl string) ([]*anypb.Any, error) {
+// generateResources returns resources of the given type for the subscriber. If generating resources fails,
+// the last known-good snapshot is returned instead, and an error is returned only if there is no such snapshot.
+func (adss *adsServer) generateResources(typeUrl string, identity Identity) ([]*discovery.Resource, error) {
 	handler, found := adss.handlers[typeUrl]
 	if !found {
-		return []*anypb.Any{}, nil
+		return []*discovery.Resource{}, nil
 	}
 
 	log.Infof("Generating config snapshot for type %s", typeUrl)
-	resources, err := handler.GenerateResponse()
+	resources, err := handler.GenerateResponse(identity)
 	if err != nil {
-		log.Errorf("failed generating resources for type %s: %v", typeUrl, err)
-		return []*anypb.Any{}, fmt.Errorf("failed generating resources for type %s: %w", typeUrl, err)
+		err = fmt.Errorf("failed generating resources for type %s: %w", typeUrl, err)
+		lastGood, found := adss.snapshots.recordFailure(typeUrl, identity, err)
+		if !found {
+			return nil, err
+		}
+		log.Errorf("serving the last known-good snapshot of type %s to %v: %v", typeUrl, identity.Names(), err)
+		return lastGood, nil
 	}
+	adss.snapshots.store(typeUrl, identity, resources)
 	return resources, nil
 }
 
-// sendToStream sends XDS resources to the subscriber.
-func sendToStream(downstream DiscoveryStream, typeUrl string, xdsResources []*anypb.Any, version string) error {
-	if err := downstream.Send(&discovery.DiscoveryResponse{
+// send sends the complete set of XDS resources to the state-of-the-world subscriber.
+// Nothing is sent if the version of resources has not changed since the last response, unless the response is forced.
+func (adss *adsServer) send(sub *subscriber, typeUrl string, xdsResources []*discovery.Resource, force bool) error {
+	sub.mu.Lock()
+	defer sub.mu.Unlock()
+
+	version := snapshotVersion(xdsResources)
+	state := sub.state(typeUrl)
+	if version == state.version && !force {
+		log.Debugf("Skip pushing %s to subscriber %s, because resources did not change", typeUrl, fmt.Sprintf(subIDFmtStr, sub.id))
+		return nil
+	}
+
+	resources := make([]*anypb.Any, 0, len(xdsResources))
+	for _, res := range xdsResources {
+		resources = append(resources, res.GetResource())
+	}
+	nonce := strconv.FormatUint(adss.nextNonce.Add(1), 10)
+	if err := sub.stream.Send(&discovery.DiscoveryResponse{
 		TypeUrl:     typeUrl,
 		VersionInfo: version,
-		Resources:   xdsResources,
+		Resources:   resources,
 		ControlPlane: &envoycfgcorev3.ControlPlane{
 			Identifier: os.Getenv("POD_NAME"),
 		},
-		Nonce: version,
+		Nonce: nonce,
 	}); err != nil {
 		return err
 	}
+	state.version = version
+	state.nonce = nonce
 	return nil
 }
 
@@ -158,33 +234,38 @@ func (adss *adsServer) push(pushRequest xds.PushRequest) error {
 		return nil
 	}
 
-	resources := pushRequest.Resources
-	if resources == nil {
-		var err error
-		resources, err = adss.generateResources(pushRequest.TypeUrl)
-		if err != nil {
-			return err
-		}
-	}
-
-	log.Infof("Pushing discovery response to subscribers: [type=%s,resources=%v]", pushRequest.TypeUrl, resources)
+	// Resources are generated once per distinct identity, as subscribers with the same identity receive the same resources.
+	// Subscribers whose resources could not be generated are skipped, so that they keep the resources they already have.
+	generated := make(map[Identity][]*discovery.Resource)
+	failed := make(map[Identity]error)
+	log.Infof("Pushing discovery response to subscribers: [type=%s]", pushRequest.TypeUrl)
 	adss.subscribers.Range(func(key, value any) bool {
-		log.Infof("Sending to subscriber %s", fmt.Sprintf(subIDFmtStr, key.(uint64)))
-		if err := value.(*subscriber).stream.Send(&discovery.DiscoveryResponse{
-			TypeUrl:     pushRequest.TypeUrl,
-			VersionInfo: strconv.FormatInt(time.Now().Unix(), 10), // TODO improve version computation
-			Resources:   resources,
-			ControlPlane: &envoycfgcorev3.ControlPlane{
-				Identifier: os.Getenv("POD_NAME"),
-			},
-		}); err != nil {
-			log.Errorf("error sending XDS resources: %v", err)
-			value.(*subscriber).closeStream()
-			adss.subscribers.Delete(key)
+		resources := pushRequest.Resources
+		if resources == nil {
+			identity := value.(*subscriber).identity
+			if _, skip := failed[identity]; skip {
+				return true
+			}
+			var found bool
+			if resources, found = generated[identity]; !found {
+				var err error
+				if resources, err = adss.generateResources(pushRequest.TypeUrl, identity); err != nil {
+					failed[identity] = err
+					return true
+				}
+				generated[identity] = resources
+			}
 		}
+		log.Infof("Queueing push to subscriber %s: %v", fmt.Sprintf(subIDFmtStr, key.(uint64)), resources)
+		value.(*subscriber).enqueue(pushRequest.TypeUrl, resources, false)
 		return true
 	})
-	return nil
+
+	var errs []error
+	for _, err := range failed {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
 }
 
 // closeSubscribers closes all active subscriber streams.
@@ -0,0 +1,153 @@
+// Copyright Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the License);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an AS IS BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package adss
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+
+	envoycfgcorev3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+// recvFromDeltaStream receives delta discovery requests from the subscriber. A request without a nonce subscribes
+// to the given type, and the subscriber receives resources which differ from its initial resource versions.
+// Other requests acknowledge received responses and do not trigger any response.
+func (adss *adsServer) recvFromDeltaStream(sub *subscriber) {
+	id, downstream := sub.id, sub.deltaStream
+	for {
+		req, err := downstream.Recv()
+		if err != nil {
+			log.Errorf("error while recv delta discovery request from subscriber %s: %v", fmt.Sprintf(subIDFmtStr, id), err)
+			break
+		}
+		log.Debugf("Got delta discovery request from subscriber %s: %v", fmt.Sprintf(subIDFmtStr, id), req)
+		if _, registered := adss.subscribers.Load(id); !registered {
+			if err := adss.register(downstream.Context(), sub, req.GetNode()); err != nil {
+				log.Warnf("rejecting delta subscriber %s: %v", fmt.Sprintf(subIDFmtStr, id), err)
+				sub.closeWithError(err)
+				return
+			}
+		}
+
+		if req.GetResponseNonce() != "" {
+			sub.recordResponse(req.GetTypeUrl(), req.GetResponseNonce(), "", req.GetErrorDetail())
+			continue
+		}
+
+		sub.mu.Lock()
+		sub.resourceVersions[req.GetTypeUrl()] = req.GetInitialResourceVersions()
+		sub.mu.Unlock()
+
+		resources, err := adss.generateResources(req.GetTypeUrl(), sub.identity)
+		if err != nil {
+			// Do not send anything, so that the subscriber keeps resources it received before reconnecting.
+			log.Errorf("failed to generate resources of type %s: %v", req.GetTypeUrl(), err)
+			continue
+		}
+		sub.enqueue(req.GetTypeUrl(), resources, true)
+	}
+	sub.closeStream()
+}
+
+// sendDelta sends resources that were added or modified since the last response, and names of removed resources.
+// Nothing is sent if resources did not change, unless the response is forced to complete the subscription.
+func (adss *adsServer) sendDelta(sub *subscriber, typeUrl string, resources []*discovery.Resource, force bool) error {
+	sub.mu.Lock()
+	defer sub.mu.Unlock()
+
+	sent := sub.resourceVersions[typeUrl]
+	current := make(map[string]string, len(resources))
+	var updated []*discovery.Resource
+	for _, res := range resources {
+		version := res.GetVersion()
+		if version == "" {
+			version = resourceVersion(res.GetResource())
+		}
+		current[res.GetName()] = version
+		if sent[res.GetName()] != version {
+			updated = append(updated, &discovery.Resource{
+				Name:     res.GetName(),
+				Version:  version,
+				Resource: res.GetResource(),
+			})
+		}
+	}
+	var removed []string
+	for name := range sent {
+		if _, found := current[name]; !found {
+			removed = append(removed, name)
+		}
+	}
+	sort.Strings(removed)
+
+	if len(updated) == 0 && len(removed) == 0 && !force {
+		log.Debugf("Skip pushing %s to subscriber %s, because resources did not change", typeUrl, fmt.Sprintf(subIDFmtStr, sub.id))
+		return nil
+	}
+
+	log.Infof("Sending %d updated and %d removed resources of type %s to subscriber %s",
+		len(updated), len(removed), typeUrl, fmt.Sprintf(subIDFmtStr, sub.id))
+	version := snapshotVersion(resources)
+	nonce := strconv.FormatUint(adss.nextNonce.Add(1), 10)
+	if err := sub.deltaStream.Send(&discovery.DeltaDiscoveryResponse{
+		TypeUrl:           typeUrl,
+		SystemVersionInfo: version,
+		Resources:         updated,
+		RemovedResources:  removed,
+		Nonce:             nonce,
+		ControlPlane: &envoycfgcorev3.ControlPlane{
+			Identifier: os.Getenv("POD_NAME"),
+		},
+	}); err != nil {
+		return err
+	}
+	sub.resourceVersions[typeUrl] = current
+	state := sub.state(typeUrl)
+	state.version = version
+	state.nonce = nonce
+	return nil
+}
+
+// resourceVersion returns a version derived from the content of the resource.
+func resourceVersion(res *anypb.Any) string {
+	hash := sha256.Sum256(res.GetValue())
+	return hex.EncodeToString(hash[:8])
+}
+
+// snapshotVersion returns a version derived from names and content of all resources, regardless of their order.
+func snapshotVersion(resources []*discovery.Resource) string {
+	entries := make([]string, 0, len(resources))
+	for _, res := range resources {
+		version := res.GetVersion()
+		if version == "" {
+			version = resourceVersion(res.GetResource())
+		}
+		entries = append(entries, res.GetName()+"/"+version)
+	}
+	sort.Strings(entries)
+
+	hash := sha256.New()
+	for _, entry := range entries {
+		hash.Write([]byte(entry))
+		hash.Write([]byte{0})
+	}
+	return hex.EncodeToString(hash.Sum(nil)[:8])
+}
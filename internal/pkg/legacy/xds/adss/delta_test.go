@@ -0,0 +1,245 @@
+// Copyright Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the License);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an AS IS BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package adss
+
+import (
+	"context"
+	"net"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	envoycfgcorev3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	"github.com/openshift-service-mesh/federation/internal/pkg/legacy/xds"
+)
+
+const testTypeUrl = "federation.openshift-service-mesh.io/v1alpha1/Test"
+
+// fakeRequestHandler generates resources with the given values, each named after its key.
+type fakeRequestHandler struct {
+	mu     sync.Mutex
+	values map[string]string
+}
+
+func (h *fakeRequestHandler) GetTypeUrl() string {
+	return testTypeUrl
+}
+
+func (h *fakeRequestHandler) GenerateResponse(_ Identity) ([]*discovery.Resource, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	resources := make([]*discovery.Resource, 0, len(h.values))
+	for name, value := range h.values {
+		res, err := anypb.New(wrapperspb.String(value))
+		if err != nil {
+			return nil, err
+		}
+		resources = append(resources, &discovery.Resource{Name: name, Resource: res})
+	}
+	return resources, nil
+}
+
+func (h *fakeRequestHandler) set(values map[string]string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.values = values
+}
+
+// startTestServer serves the given handlers over an in-memory listener and returns a client connected to it.
+func startTestServer(t *testing.T, sendTimeout time.Duration, handlers ...RequestHandler) (*adsServer, discovery.AggregatedDiscoveryServiceClient) {
+	t.Helper()
+	handlerMap := make(map[string]RequestHandler, len(handlers))
+	for _, h := range handlers {
+		handlerMap[h.GetTypeUrl()] = h
+	}
+	ads := &adsServer{
+		handlers:    handlerMap,
+		snapshots:   newSnapshotCache(),
+		sendTimeout: sendTimeout,
+	}
+	listener := bufconn.Listen(1024 * 1024)
+	grpcServer := grpc.NewServer()
+	discovery.RegisterAggregatedDiscoveryServiceServer(grpcServer, ads)
+	go func() {
+		_ = grpcServer.Serve(listener)
+	}()
+	t.Cleanup(grpcServer.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///fds",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("failed to connect to the server: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = conn.Close()
+	})
+	return ads, discovery.NewAggregatedDiscoveryServiceClient(conn)
+}
+
+// recvWithTimeout receives the next message from the stream, or fails the test if none arrives in time.
+func recvWithTimeout[T any](t *testing.T, recv func() (T, error)) T {
+	t.Helper()
+	type result struct {
+		msg T
+		err error
+	}
+	received := make(chan result, 1)
+	go func() {
+		msg, err := recv()
+		received <- result{msg, err}
+	}()
+	select {
+	case r := <-received:
+		if r.err != nil {
+			t.Fatalf("failed to receive response: %v", r.err)
+		}
+		return r.msg
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for response")
+	}
+	panic("unreachable")
+}
+
+// waitForSubscribers waits until the given number of subscribers is registered for pushes.
+func waitForSubscribers(t *testing.T, ads *adsServer, expected int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for ads.subscribersLen() != expected {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %d subscribers, got %d", expected, ads.subscribersLen())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func names(resources []*discovery.Resource) []string {
+	out := make([]string, 0, len(resources))
+	for _, res := range resources {
+		out = append(out, res.GetName())
+	}
+	slices.Sort(out)
+	return out
+}
+
+func versions(resources []*discovery.Resource) map[string]string {
+	out := make(map[string]string, len(resources))
+	for _, res := range resources {
+		out[res.GetName()] = res.GetVersion()
+	}
+	return out
+}
+
+func TestDeltaAggregatedResources(t *testing.T) {
+	handler := &fakeRequestHandler{values: map[string]string{"a": "a-1", "b": "b-1"}}
+	ads, client := startTestServer(t, defaultSendTimeout, handler)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	stream, err := client.DeltaAggregatedResources(ctx)
+	if err != nil {
+		t.Fatalf("failed to open delta stream: %v", err)
+	}
+	if err := stream.Send(&discovery.DeltaDiscoveryRequest{TypeUrl: testTypeUrl, Node: &envoycfgcorev3.Node{Id: "west"}}); err != nil {
+		t.Fatalf("failed to subscribe: %v", err)
+	}
+
+	// Initial sync sends all resources.
+	resp := recvWithTimeout(t, stream.Recv)
+	if got := names(resp.GetResources()); !slices.Equal(got, []string{"a", "b"}) {
+		t.Errorf("expected resources [a b] in initial sync, got %v", got)
+	}
+	if len(resp.GetRemovedResources()) != 0 {
+		t.Errorf("expected no removed resources in initial sync, got %v", resp.GetRemovedResources())
+	}
+	initialVersions := versions(resp.GetResources())
+	if err := stream.Send(&discovery.DeltaDiscoveryRequest{TypeUrl: testTypeUrl, ResponseNonce: resp.GetNonce()}); err != nil {
+		t.Fatalf("failed to acknowledge response: %v", err)
+	}
+
+	// Pushes send only modified and added resources, and names of removed resources.
+	waitForSubscribers(t, ads, 1)
+	handler.set(map[string]string{"a": "a-2", "c": "c-1"})
+	if err := ads.push(xds.PushRequest{TypeUrl: testTypeUrl}); err != nil {
+		t.Fatalf("failed to push: %v", err)
+	}
+	resp = recvWithTimeout(t, stream.Recv)
+	if got := names(resp.GetResources()); !slices.Equal(got, []string{"a", "c"}) {
+		t.Errorf("expected updated resources [a c], got %v", got)
+	}
+	if !slices.Equal(resp.GetRemovedResources(), []string{"b"}) {
+		t.Errorf("expected removed resources [b], got %v", resp.GetRemovedResources())
+	}
+	cancel()
+
+	// After reconnecting with versions received before the update, the subscriber receives only differences.
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	stream, err = client.DeltaAggregatedResources(ctx)
+	if err != nil {
+		t.Fatalf("failed to reopen delta stream: %v", err)
+	}
+	if err := stream.Send(&discovery.DeltaDiscoveryRequest{
+		TypeUrl:                 testTypeUrl,
+		Node:                    &envoycfgcorev3.Node{Id: "west"},
+		InitialResourceVersions: initialVersions,
+	}); err != nil {
+		t.Fatalf("failed to resubscribe: %v", err)
+	}
+	resp = recvWithTimeout(t, stream.Recv)
+	if got := names(resp.GetResources()); !slices.Equal(got, []string{"a", "c"}) {
+		t.Errorf("expected resources [a c] after reconnecting, got %v", got)
+	}
+	if !slices.Equal(resp.GetRemovedResources(), []string{"b"}) {
+		t.Errorf("expected removed resources [b] after reconnecting, got %v", resp.GetRemovedResources())
+	}
+}
+
+func TestDeltaAggregatedResourcesReconnectUpToDate(t *testing.T) {
+	handler := &fakeRequestHandler{values: map[string]string{"a": "a-1"}}
+	_, client := startTestServer(t, defaultSendTimeout, handler)
+	resources, err := handler.GenerateResponse(Identity{})
+	if err != nil {
+		t.Fatalf("failed to generate resources: %v", err)
+	}
+
+	stream, err := client.DeltaAggregatedResources(context.Background())
+	if err != nil {
+		t.Fatalf("failed to open delta stream: %v", err)
+	}
+	if err := stream.Send(&discovery.DeltaDiscoveryRequest{
+		TypeUrl:                 testTypeUrl,
+		Node:                    &envoycfgcorev3.Node{Id: "west"},
+		InitialResourceVersions: map[string]string{"a": resourceVersion(resources[0].GetResource())},
+	}); err != nil {
+		t.Fatalf("failed to subscribe: %v", err)
+	}
+
+	// The subscription is completed by an empty response, because the subscriber already has all resources.
+	resp := recvWithTimeout(t, stream.Recv)
+	if len(resp.GetResources()) != 0 || len(resp.GetRemovedResources()) != 0 {
+		t.Errorf("expected empty response, got %d updated and %d removed resources", len(resp.GetResources()), len(resp.GetRemovedResources()))
+	}
+}
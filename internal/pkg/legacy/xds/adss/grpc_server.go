@@ -16,11 +16,14 @@ package adss
 
 import (
 	"context"
+	"crypto/tls"
 	"fmt"
 	"net"
+	"sort"
 
 	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 
 	"github.com/openshift-service-mesh/federation/internal/pkg/legacy/xds"
 )
@@ -31,14 +34,27 @@ type Server struct {
 	pushRequests <-chan xds.PushRequest
 }
 
-func NewServer(pushRequests <-chan xds.PushRequest, handlers ...RequestHandler) *Server {
-	grpcServer := grpc.NewServer()
+// NewServer creates the discovery server. If tlsConfig is nil, the server accepts plaintext connections,
+// which are expected to be secured by the sidecar. Subscribers are rejected unless authorized by the given authorizer.
+// If trustXFCC is true, subscribers connected over plaintext are identified by the XFCC header, which must be guaranteed
+// to be set by the sidecar, i.e. the sidecar terminates mTLS for all inbound connections (STRICT PeerAuthentication)
+// and appends the client identity to the header.
+func NewServer(pushRequests <-chan xds.PushRequest, tlsConfig *tls.Config, trustXFCC bool, authorize Authorizer, handlers ...RequestHandler) *Server {
+	var opts []grpc.ServerOption
+	if tlsConfig != nil {
+		opts = append(opts, grpc.Creds(credentials.NewTLS(tlsConfig)))
+	}
+	grpcServer := grpc.NewServer(opts...)
 	handlerMap := make(map[string]RequestHandler)
 	for _, g := range handlers {
 		handlerMap[g.GetTypeUrl()] = g
 	}
 	ads := &adsServer{
-		handlers: handlerMap,
+		handlers:    handlerMap,
+		snapshots:   newSnapshotCache(),
+		sendTimeout: defaultSendTimeout,
+		authorize:   authorize,
+		trustXFCC:   trustXFCC,
 	}
 
 	discovery.RegisterAggregatedDiscoveryServiceServer(grpcServer, ads)
@@ -85,3 +101,21 @@ loop:
 
 	return nil
 }
+
+// Subscribers returns the status of all connected subscribers sorted by ID.
+func (s *Server) Subscribers() []SubscriberStatus {
+	var statuses []SubscriberStatus
+	s.ads.subscribers.Range(func(_, value any) bool {
+		statuses = append(statuses, value.(*subscriber).status(s.ads.snapshots))
+		return true
+	})
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].ID < statuses[j].ID
+	})
+	return statuses
+}
+
+// GenerationStatus returns the result of the last generation of resources of the given type.
+func (s *Server) GenerationStatus(typeUrl string) GenerationStatus {
+	return s.ads.snapshots.status(typeUrl)
+}
@@ -0,0 +1,129 @@
+// Copyright Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the License);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an AS IS BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package adss
+
+import (
+	"context"
+	"strings"
+
+	envoycfgcorev3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+// xfccHeader is set by the sidecar on requests received over Istio mTLS.
+const xfccHeader = "x-forwarded-client-cert"
+
+// Identity identifies a subscriber of the discovery service.
+type Identity struct {
+	// NodeID is the node identifier sent by the subscriber in discovery requests. Federation controllers
+	// send the name of their local mesh, so it matches the name under which the subscriber is configured as a remote peer.
+	NodeID string
+	// SpiffeID is the SPIFFE identity from the client certificate, if the subscriber connected over mTLS,
+	// or from the XFCC header set by the sidecar, if the connection was terminated by the trusted sidecar.
+	SpiffeID string
+}
+
+// Names returns all non-empty names identifying the subscriber.
+func (i Identity) Names() []string {
+	var names []string
+	if i.NodeID != "" {
+		names = append(names, i.NodeID)
+	}
+	if i.SpiffeID != "" {
+		names = append(names, i.SpiffeID)
+	}
+	return names
+}
+
+// Authorizer returns an error if the subscriber with the given identity is not allowed to subscribe.
+type Authorizer func(identity Identity) error
+
+// identify returns the identity of the subscriber based on the node metadata from the first discovery request
+// and the client certificate or, if trustXFCC is true, the XFCC header of the stream.
+func identify(ctx context.Context, node *envoycfgcorev3.Node, trustXFCC bool) Identity {
+	return Identity{
+		NodeID:   node.GetId(),
+		SpiffeID: spiffeID(ctx, trustXFCC),
+	}
+}
+
+func spiffeID(ctx context.Context, trustXFCC bool) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return ""
+	}
+	tlsInfo, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok {
+		// Plaintext connections may be terminated by the sidecar, which forwards the client identity in the XFCC header.
+		// Otherwise, the header could be set by any client, so it is only trusted if the deployment guarantees
+		// the former. The header is never trusted on mTLS connections, because it is set by the client then.
+		if !trustXFCC {
+			return ""
+		}
+		return spiffeIDFromXFCC(ctx)
+	}
+	if len(tlsInfo.State.PeerCertificates) == 0 {
+		return ""
+	}
+	for _, uri := range tlsInfo.State.PeerCertificates[0].URIs {
+		if uri.Scheme == "spiffe" {
+			return uri.String()
+		}
+	}
+	return ""
+}
+
+// spiffeIDFromXFCC returns the URI from the last element of the XFCC header, which is appended by the local sidecar.
+// Elements appended by other proxies are ignored, as they could be forged by the client.
+func spiffeIDFromXFCC(ctx context.Context) string {
+	values := metadata.ValueFromIncomingContext(ctx, xfccHeader)
+	if len(values) == 0 {
+		return ""
+	}
+	elements := splitQuoted(values[len(values)-1], ',')
+	for _, pair := range splitQuoted(elements[len(elements)-1], ';') {
+		key, value, found := strings.Cut(pair, "=")
+		if !found || !strings.EqualFold(strings.TrimSpace(key), "URI") {
+			continue
+		}
+		if uri := strings.Trim(strings.TrimSpace(value), `"`); strings.HasPrefix(uri, "spiffe://") {
+			return uri
+		}
+	}
+	return ""
+}
+
+// splitQuoted splits s by the separator, except for separators within double quotes, e.g. in the Subject of XFCC.
+func splitQuoted(s string, sep byte) []string {
+	var parts []string
+	quoted, escaped := false, false
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch {
+		case escaped:
+			escaped = false
+		case s[i] == '\\':
+			escaped = true
+		case s[i] == '"':
+			quoted = !quoted
+		case s[i] == sep && !quoted:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
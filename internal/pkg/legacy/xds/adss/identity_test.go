@@ -0,0 +1,86 @@
+// Copyright Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the License);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an AS IS BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package adss
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+func TestSpiffeIDFromXFCC(t *testing.T) {
+	testCases := []struct {
+		name     string
+		xfcc     []string
+		expected string
+	}{{
+		name:     "no header",
+		expected: "",
+	}, {
+		name:     "element set by the sidecar",
+		xfcc:     []string{`By=spiffe://east.local/ns/istio-system/sa/federation-controller;Hash=abc;Subject="";URI=spiffe://west.local/ns/istio-system/sa/federation-controller`},
+		expected: "spiffe://west.local/ns/istio-system/sa/federation-controller",
+	}, {
+		name: "element forwarded from the client is ignored",
+		xfcc: []string{`URI=spiffe://east.local/ns/istio-system/sa/admin,` +
+			`By=spiffe://east.local/ns/istio-system/sa/federation-controller;Subject="CN=west,O=mesh;x";URI=spiffe://west.local/ns/istio-system/sa/federation-controller`},
+		expected: "spiffe://west.local/ns/istio-system/sa/federation-controller",
+	}, {
+		name:     "last element without URI",
+		xfcc:     []string{`URI=spiffe://east.local/ns/istio-system/sa/admin,Hash=abc`},
+		expected: "",
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			for _, value := range tc.xfcc {
+				ctx = metadata.NewIncomingContext(ctx, metadata.Pairs(xfccHeader, value))
+			}
+			if actual := spiffeIDFromXFCC(ctx); actual != tc.expected {
+				t.Errorf("expected %q but got %q", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestSpiffeIDOfPlaintextConnection(t *testing.T) {
+	ctx := peer.NewContext(context.Background(), &peer.Peer{Addr: &net.TCPAddr{IP: net.IPv4(10, 0, 0, 1)}})
+	ctx = metadata.NewIncomingContext(ctx, metadata.Pairs(xfccHeader, "URI=spiffe://west.local/ns/istio-system/sa/federation-controller"))
+
+	testCases := []struct {
+		name      string
+		trustXFCC bool
+		expected  string
+	}{{
+		name:      "XFCC header is trusted",
+		trustXFCC: true,
+		expected:  "spiffe://west.local/ns/istio-system/sa/federation-controller",
+	}, {
+		name:     "XFCC header is ignored",
+		expected: "",
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if actual := spiffeID(ctx, tc.trustXFCC); actual != tc.expected {
+				t.Errorf("expected %q but got %q", tc.expected, actual)
+			}
+		})
+	}
+}
@@ -0,0 +1,61 @@
+// Copyright Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the License);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an AS IS BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package adss
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+// subscriberLabelNames identify the type and the subscriber of per-subscriber metrics. Subscribers with the same identity
+// receive the same resources, so they share the metrics.
+var subscriberLabelNames = []string{"type", "node", "spiffe_id"}
+
+var (
+	generationFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "federation_fds_generation_failures_total",
+		Help: "Number of failures to generate resources served to remote peers.",
+	}, []string{"type"})
+
+	staleSnapshot = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "federation_fds_stale_snapshot",
+		Help: "Set to 1 when the last generation of resources for the subscriber failed and it is served the last known-good snapshot.",
+	}, subscriberLabelNames)
+
+	rejectedSnapshot = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "federation_fds_rejected_snapshot",
+		Help: "Set to 1 when the subscriber rejected the last response of the type.",
+	}, subscriberLabelNames)
+
+	slowSubscribers = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "federation_fds_slow_subscribers_total",
+		Help: "Number of subscribers disconnected, because they did not receive resources within the send timeout.",
+	})
+)
+
+func init() {
+	metrics.Registry.MustRegister(generationFailures, staleSnapshot, rejectedSnapshot, slowSubscribers)
+}
+
+func subscriberLabels(typeUrl string, identity Identity) []string {
+	return []string{typeUrl, identity.NodeID, identity.SpiffeID}
+}
+
+// deleteSubscriberMetrics deletes metrics of all types for the given identity.
+func deleteSubscriberMetrics(identity Identity) {
+	labels := prometheus.Labels{"node": identity.NodeID, "spiffe_id": identity.SpiffeID}
+	staleSnapshot.DeletePartialMatch(labels)
+	rejectedSnapshot.DeletePartialMatch(labels)
+}
@@ -14,7 +14,7 @@
 
 package adss
 
-import "google.golang.org/protobuf/types/known/anypb"
+import discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
 
 // RequestHandler generates XDS response for requests from subscribers or push requests triggered by other events.
 type RequestHandler interface {
@@ -22,5 +22,7 @@ type RequestHandler interface {
 	// An implementation can support only one XDS type.
 	GetTypeUrl() string
 	// GenerateResponse returns generated resources for requested XDS type.
-	GenerateResponse() ([]*anypb.Any, error)
+	// Resources may differ between subscribers depending on their identity.
+	// Resources must have unique names, which identify them in incremental updates.
+	GenerateResponse(subscriber Identity) ([]*discovery.Resource, error)
 }
@@ -0,0 +1,105 @@
+// Copyright Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the License);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an AS IS BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package adss
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+)
+
+// defaultSendTimeout is the maximum time of sending a single response. Subscribers which do not receive responses
+// in time are considered stuck and are disconnected, so that they can reconnect and receive the current snapshot.
+const defaultSendTimeout = 30 * time.Second
+
+// pendingPush is the latest snapshot of a type waiting to be sent to the subscriber.
+type pendingPush struct {
+	resources []*discovery.Resource
+	// force is set when the snapshot must be sent even if it did not change, e.g. in response to a subscription.
+	force bool
+}
+
+// enqueue schedules sending resources to the subscriber without blocking the caller. Snapshots of the same type
+// which were not sent yet are replaced by the latest one, so a slow subscriber receives only the current state.
+func (sub *subscriber) enqueue(typeUrl string, resources []*discovery.Resource, force bool) {
+	sub.queueMu.Lock()
+	defer sub.queueMu.Unlock()
+
+	if prev, found := sub.pending[typeUrl]; found {
+		log.Debugf("Coalescing pending push of type %s to subscriber %s", typeUrl, fmt.Sprintf(subIDFmtStr, sub.id))
+		force = force || prev.force
+	}
+	sub.pending[typeUrl] = pendingPush{resources: resources, force: force}
+	select {
+	case sub.notify <- struct{}{}:
+	default:
+	}
+}
+
+// dequeue returns all pending snapshots sorted by type URL and clears the queue.
+func (sub *subscriber) dequeue() ([]string, map[string]pendingPush) {
+	sub.queueMu.Lock()
+	defer sub.queueMu.Unlock()
+
+	pending := sub.pending
+	sub.pending = make(map[string]pendingPush)
+	typeUrls := make([]string, 0, len(pending))
+	for typeUrl := range pending {
+		typeUrls = append(typeUrls, typeUrl)
+	}
+	sort.Strings(typeUrls)
+	return typeUrls, pending
+}
+
+// sendLoop sends queued snapshots to the subscriber until the stream is closed. Each subscriber has its own loop,
+// so a slow subscriber does not delay pushes to other subscribers.
+func (adss *adsServer) sendLoop(ctx context.Context, sub *subscriber) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-sub.notify:
+		}
+
+		typeUrls, pending := sub.dequeue()
+		for _, typeUrl := range typeUrls {
+			if err := adss.sendWithTimeout(sub, typeUrl, pending[typeUrl]); err != nil {
+				log.Errorf("error sending XDS resources of type %s to subscriber %s: %v", typeUrl, fmt.Sprintf(subIDFmtStr, sub.id), err)
+				sub.closeStream()
+				return
+			}
+		}
+	}
+}
+
+// sendWithTimeout sends the snapshot and closes the stream if sending does not complete within the send timeout.
+// Closing the stream unblocks the pending send, which then returns an error.
+func (adss *adsServer) sendWithTimeout(sub *subscriber, typeUrl string, push pendingPush) error {
+	timer := time.AfterFunc(adss.sendTimeout, func() {
+		log.Warnf("Subscriber %s did not receive resources of type %s within %s, closing the stream",
+			fmt.Sprintf(subIDFmtStr, sub.id), typeUrl, adss.sendTimeout)
+		slowSubscribers.Inc()
+		sub.closeStream()
+	})
+	defer timer.Stop()
+
+	if sub.deltaStream != nil {
+		return adss.sendDelta(sub, typeUrl, push.resources, push.force)
+	}
+	return adss.send(sub, typeUrl, push.resources, push.force)
+}
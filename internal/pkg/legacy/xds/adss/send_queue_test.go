@@ -0,0 +1,180 @@
+// Copyright Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the License);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an AS IS BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package adss
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	"github.com/openshift-service-mesh/federation/internal/pkg/legacy/xds"
+)
+
+// fakeDiscoveryStream records sent responses. If release is set, each send is signaled by sending
+// and then blocks until a value is received from release or the stream is closed.
+type fakeDiscoveryStream struct {
+	grpc.ServerStream
+	ctx     context.Context
+	sending chan struct{}
+	release chan struct{}
+	sent    chan *discovery.DiscoveryResponse
+}
+
+func (s *fakeDiscoveryStream) Send(resp *discovery.DiscoveryResponse) error {
+	if s.release != nil {
+		s.sending <- struct{}{}
+		select {
+		case <-s.release:
+		case <-s.ctx.Done():
+			return s.ctx.Err()
+		}
+	}
+	s.sent <- resp
+	return nil
+}
+
+func (s *fakeDiscoveryStream) Recv() (*discovery.DiscoveryRequest, error) {
+	<-s.ctx.Done()
+	return nil, s.ctx.Err()
+}
+
+func (s *fakeDiscoveryStream) Context() context.Context {
+	return s.ctx
+}
+
+// startSubscriber registers a state-of-the-world subscriber with the fake stream and starts its send loop.
+// The returned context is done when the server closes the stream.
+func startSubscriber(t *testing.T, ads *adsServer, nodeID string, blocked bool) (*fakeDiscoveryStream, context.Context) {
+	t.Helper()
+	ctx, closeStream := context.WithCancel(context.Background())
+	t.Cleanup(closeStream)
+
+	stream := &fakeDiscoveryStream{ctx: ctx, sent: make(chan *discovery.DiscoveryResponse, 10)}
+	if blocked {
+		stream.sending = make(chan struct{}, 10)
+		stream.release = make(chan struct{})
+	}
+	sub := newSubscriber(ads.nextSubscriberID.Add(1), closeStream)
+	sub.identity = Identity{NodeID: nodeID}
+	sub.stream = stream
+	ads.subscribers.Store(sub.id, sub)
+	go ads.sendLoop(ctx, sub)
+	return stream, ctx
+}
+
+func stringResources(t *testing.T, values ...string) []*discovery.Resource {
+	t.Helper()
+	resources := make([]*discovery.Resource, 0, len(values))
+	for _, value := range values {
+		res, err := anypb.New(wrapperspb.String(value))
+		if err != nil {
+			t.Fatalf("failed to marshal resource: %v", err)
+		}
+		resources = append(resources, &discovery.Resource{Name: value, Resource: res})
+	}
+	return resources
+}
+
+func expectSent(t *testing.T, stream *fakeDiscoveryStream, expected []*discovery.Resource) {
+	t.Helper()
+	select {
+	case resp := <-stream.sent:
+		if resp.GetVersionInfo() != snapshotVersion(expected) {
+			t.Errorf("expected version %s, got %s", snapshotVersion(expected), resp.GetVersionInfo())
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for response")
+	}
+}
+
+func TestSendQueueCoalescesPushesWhileStreamIsBlocked(t *testing.T) {
+	ads := &adsServer{snapshots: newSnapshotCache(), sendTimeout: time.Minute}
+	stream, _ := startSubscriber(t, ads, "east", true)
+
+	first := stringResources(t, "a")
+	if err := ads.push(xds.PushRequest{TypeUrl: testTypeUrl, Resources: first}); err != nil {
+		t.Fatalf("failed to push: %v", err)
+	}
+	<-stream.sending
+
+	// The send loop is blocked by the first push, so the following pushes wait in the queue,
+	// where they are replaced by the latest one.
+	for _, values := range [][]string{{"a", "b"}, {"a", "b", "c"}} {
+		if err := ads.push(xds.PushRequest{TypeUrl: testTypeUrl, Resources: stringResources(t, values...)}); err != nil {
+			t.Fatalf("failed to push: %v", err)
+		}
+	}
+	stream.release <- struct{}{}
+	expectSent(t, stream, first)
+	<-stream.sending
+	stream.release <- struct{}{}
+	expectSent(t, stream, stringResources(t, "a", "b", "c"))
+
+	select {
+	case <-stream.sending:
+		t.Error("expected coalesced pushes to be sent once")
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestSendTimeoutClosesStuckSubscriber(t *testing.T) {
+	ads := &adsServer{snapshots: newSnapshotCache(), sendTimeout: 50 * time.Millisecond}
+	_, streamCtx := startSubscriber(t, ads, "east", true)
+	slowBefore := testutil.ToFloat64(slowSubscribers)
+
+	if err := ads.push(xds.PushRequest{TypeUrl: testTypeUrl, Resources: stringResources(t, "a")}); err != nil {
+		t.Fatalf("failed to push: %v", err)
+	}
+	select {
+	case <-streamCtx.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the stream of the stuck subscriber to be closed")
+	}
+	if slow := testutil.ToFloat64(slowSubscribers) - slowBefore; slow != 1 {
+		t.Errorf("expected 1 slow subscriber, got %v", slow)
+	}
+}
+
+func TestHealthySubscriberReceivesPushesWhileAnotherIsStuck(t *testing.T) {
+	ads := &adsServer{snapshots: newSnapshotCache(), sendTimeout: time.Minute}
+	_, stuckCtx := startSubscriber(t, ads, "east", true)
+	healthy, _ := startSubscriber(t, ads, "west", false)
+
+	for _, values := range [][]string{{"a"}, {"a", "b"}, {"a", "b", "c"}} {
+		resources := stringResources(t, values...)
+		pushed := make(chan error, 1)
+		go func() {
+			pushed <- ads.push(xds.PushRequest{TypeUrl: testTypeUrl, Resources: resources})
+		}()
+		select {
+		case err := <-pushed:
+			if err != nil {
+				t.Fatalf("failed to push: %v", err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("push was blocked by the stuck subscriber")
+		}
+		expectSent(t, healthy, resources)
+	}
+	if stuckCtx.Err() != nil {
+		t.Error("expected the stuck subscriber to stay connected until the send timeout")
+	}
+}
@@ -0,0 +1,116 @@
+// Copyright Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the License);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an AS IS BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package adss
+
+import (
+	"sync"
+	"time"
+
+	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+)
+
+// GenerationStatus describes the result of generating resources of a type.
+type GenerationStatus struct {
+	// LastSuccessTime is the time when resources were generated successfully for the last time.
+	LastSuccessTime time.Time
+	// LastFailureTime is the time of the last failure, and LastError is its cause.
+	// LastError is reset when resources are generated successfully again.
+	LastFailureTime time.Time
+	LastError       error
+}
+
+type snapshotKey struct {
+	typeUrl  string
+	identity Identity
+}
+
+// snapshotCache stores the last known-good snapshot of resources generated for each type and subscriber identity,
+// so that subscribers do not receive empty snapshots when generating resources fails.
+// Snapshots are kept only while a subscriber with the identity is connected.
+type snapshotCache struct {
+	mu        sync.Mutex
+	snapshots map[snapshotKey][]*discovery.Resource
+	// stale contains errors of the last generation for identities served the last known-good snapshot.
+	stale    map[snapshotKey]error
+	statuses map[string]GenerationStatus
+}
+
+func newSnapshotCache() *snapshotCache {
+	return &snapshotCache{
+		snapshots: make(map[snapshotKey][]*discovery.Resource),
+		stale:     make(map[snapshotKey]error),
+		statuses:  make(map[string]GenerationStatus),
+	}
+}
+
+func (c *snapshotCache) store(typeUrl string, identity Identity, resources []*discovery.Resource) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := snapshotKey{typeUrl, identity}
+	c.snapshots[key] = resources
+	delete(c.stale, key)
+	status := c.statuses[typeUrl]
+	status.LastSuccessTime = time.Now()
+	status.LastError = nil
+	c.statuses[typeUrl] = status
+	staleSnapshot.WithLabelValues(subscriberLabels(typeUrl, identity)...).Set(0)
+}
+
+// recordFailure records the generation error and returns the last known-good snapshot for the given subscriber if any.
+func (c *snapshotCache) recordFailure(typeUrl string, identity Identity, err error) ([]*discovery.Resource, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.statuses[typeUrl] = GenerationStatus{
+		LastSuccessTime: c.statuses[typeUrl].LastSuccessTime,
+		LastFailureTime: time.Now(),
+		LastError:       err,
+	}
+	generationFailures.WithLabelValues(typeUrl).Inc()
+	key := snapshotKey{typeUrl, identity}
+	resources, found := c.snapshots[key]
+	if found {
+		c.stale[key] = err
+		staleSnapshot.WithLabelValues(subscriberLabels(typeUrl, identity)...).Set(1)
+	}
+	return resources, found
+}
+
+func (c *snapshotCache) status(typeUrl string) GenerationStatus {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.statuses[typeUrl]
+}
+
+// staleError returns the generation error, if the given subscriber is served the last known-good snapshot.
+func (c *snapshotCache) staleError(typeUrl string, identity Identity) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.stale[snapshotKey{typeUrl, identity}]
+}
+
+// delete removes snapshots of all types stored for the given subscriber identity.
+func (c *snapshotCache) delete(identity Identity) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for key := range c.snapshots {
+		if key.identity == identity {
+			delete(c.snapshots, key)
+			delete(c.stale, key)
+		}
+	}
+}
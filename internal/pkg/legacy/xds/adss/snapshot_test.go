@@ -0,0 +1,186 @@
+// Copyright Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the License);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an AS IS BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package adss
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// failingRequestHandler fails to generate resources for identities with the given node IDs.
+type failingRequestHandler struct {
+	mu     sync.Mutex
+	failed map[string]bool
+}
+
+func (h *failingRequestHandler) GetTypeUrl() string {
+	return testTypeUrl
+}
+
+func (h *failingRequestHandler) GenerateResponse(subscriber Identity) ([]*discovery.Resource, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.failed[subscriber.NodeID] {
+		return nil, errors.New("generation failed")
+	}
+	return []*discovery.Resource{{Name: subscriber.NodeID}}, nil
+}
+
+func (h *failingRequestHandler) fail(nodeID string, failed bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.failed[nodeID] = failed
+}
+
+func TestStaleSnapshotIsTrackedPerIdentity(t *testing.T) {
+	handler := &failingRequestHandler{failed: make(map[string]bool)}
+	ads := &adsServer{
+		handlers:  map[string]RequestHandler{testTypeUrl: handler},
+		snapshots: newSnapshotCache(),
+	}
+	east, west := Identity{NodeID: "east"}, Identity{NodeID: "west"}
+	stale := func(identity Identity) float64 {
+		return testutil.ToFloat64(staleSnapshot.WithLabelValues(subscriberLabels(testTypeUrl, identity)...))
+	}
+	t.Cleanup(func() {
+		deleteSubscriberMetrics(east)
+		deleteSubscriberMetrics(west)
+	})
+
+	for _, identity := range []Identity{east, west} {
+		if _, err := ads.generateResources(testTypeUrl, identity); err != nil {
+			t.Fatalf("failed to generate resources for %v: %v", identity.Names(), err)
+		}
+	}
+
+	// The last known-good snapshot is served to the subscriber whose resources could not be generated.
+	handler.fail("east", true)
+	resources, err := ads.generateResources(testTypeUrl, east)
+	if err != nil {
+		t.Fatalf("expected the last known-good snapshot, got error: %v", err)
+	}
+	if len(resources) != 1 || resources[0].GetName() != "east" {
+		t.Errorf("expected the last known-good snapshot of east, got %v", resources)
+	}
+	if stale(east) != 1 {
+		t.Errorf("expected stale snapshot of east")
+	}
+
+	// Successful generation for another identity does not hide the stale snapshot.
+	if _, err := ads.generateResources(testTypeUrl, west); err != nil {
+		t.Fatalf("failed to generate resources for west: %v", err)
+	}
+	if stale(east) != 1 || stale(west) != 0 {
+		t.Errorf("expected stale snapshot of east only, got east=%v west=%v", stale(east), stale(west))
+	}
+
+	handler.fail("east", false)
+	if _, err := ads.generateResources(testTypeUrl, east); err != nil {
+		t.Fatalf("failed to generate resources for east: %v", err)
+	}
+	if stale(east) != 0 {
+		t.Errorf("expected stale snapshot of east to be cleared")
+	}
+}
+
+func TestStaleSnapshotWithoutLastKnownGood(t *testing.T) {
+	handler := &failingRequestHandler{failed: map[string]bool{"east": true}}
+	ads := &adsServer{
+		handlers:  map[string]RequestHandler{testTypeUrl: handler},
+		snapshots: newSnapshotCache(),
+	}
+	if _, err := ads.generateResources(testTypeUrl, Identity{NodeID: "east"}); err == nil {
+		t.Error("expected error, because there is no snapshot to serve")
+	}
+}
+
+func TestSnapshotsAreDeletedWhenLastSubscriberOfIdentityUnregisters(t *testing.T) {
+	handler := &failingRequestHandler{failed: make(map[string]bool)}
+	ads := &adsServer{
+		handlers:  map[string]RequestHandler{testTypeUrl: handler},
+		snapshots: newSnapshotCache(),
+	}
+	east := Identity{NodeID: "east"}
+	t.Cleanup(func() {
+		deleteSubscriberMetrics(east)
+	})
+	first, second := &subscriber{id: 1, identity: east}, &subscriber{id: 2, identity: east}
+	ads.subscribers.Store(first.id, first)
+	ads.subscribers.Store(second.id, second)
+	if _, err := ads.generateResources(testTypeUrl, east); err != nil {
+		t.Fatalf("failed to generate resources: %v", err)
+	}
+	handler.fail("east", true)
+
+	ads.unregister(first)
+	if _, err := ads.generateResources(testTypeUrl, east); err != nil {
+		t.Errorf("expected the snapshot to be kept while another subscriber has the same identity, got: %v", err)
+	}
+
+	ads.unregister(second)
+	if len(ads.snapshots.snapshots) != 0 {
+		t.Errorf("expected snapshots to be deleted, got %d", len(ads.snapshots.snapshots))
+	}
+}
+
+func TestSubscriberStatusReportsGenerationError(t *testing.T) {
+	handler := &failingRequestHandler{failed: make(map[string]bool)}
+	server := &Server{ads: &adsServer{
+		handlers:  map[string]RequestHandler{testTypeUrl: handler},
+		snapshots: newSnapshotCache(),
+	}}
+	east := Identity{NodeID: "east"}
+	t.Cleanup(func() {
+		deleteSubscriberMetrics(east)
+	})
+	sub := newSubscriber(1, func() {})
+	sub.identity = east
+	sub.state(testTypeUrl).version = "1"
+	server.ads.subscribers.Store(sub.id, sub)
+
+	if _, err := server.ads.generateResources(testTypeUrl, east); err != nil {
+		t.Fatalf("failed to generate resources: %v", err)
+	}
+	if status := server.GenerationStatus(testTypeUrl); status.LastSuccessTime.IsZero() || status.LastError != nil {
+		t.Errorf("expected successful generation, got %+v", status)
+	}
+
+	handler.fail("east", true)
+	if _, err := server.ads.generateResources(testTypeUrl, east); err != nil {
+		t.Fatalf("expected the last known-good snapshot, got error: %v", err)
+	}
+	if status := server.GenerationStatus(testTypeUrl); status.LastError == nil || status.LastFailureTime.IsZero() {
+		t.Errorf("expected failed generation, got %+v", status)
+	}
+	subscribers := server.Subscribers()
+	if len(subscribers) != 1 {
+		t.Fatalf("expected 1 subscriber, got %d", len(subscribers))
+	}
+	if typeStatus := subscribers[0].Types[testTypeUrl]; typeStatus.SentVersion != "1" || typeStatus.GenerationError == nil {
+		t.Errorf("expected generation error reported for sent version 1, got %+v", typeStatus)
+	}
+
+	handler.fail("east", false)
+	if _, err := server.ads.generateResources(testTypeUrl, east); err != nil {
+		t.Fatalf("failed to generate resources: %v", err)
+	}
+	if typeStatus := server.Subscribers()[0].Types[testTypeUrl]; typeStatus.GenerationError != nil {
+		t.Errorf("expected generation error to be cleared, got %v", typeStatus.GenerationError)
+	}
+}
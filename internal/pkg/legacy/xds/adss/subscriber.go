@@ -0,0 +1,167 @@
+// Copyright Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the License);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an AS IS BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package adss
+
+import (
+	"fmt"
+	"sync"
+
+	rpcstatus "google.golang.org/genproto/googleapis/rpc/status"
+)
+
+// subscriber represents a client that is subscribed to XDS resources.
+// Exactly one of stream and deltaStream is set, depending on the protocol variant used by the subscriber.
+type subscriber struct {
+	id          uint64
+	identity    Identity
+	stream      DiscoveryStream
+	deltaStream DeltaDiscoveryStream
+	closeStream func()
+
+	// queueMu guards snapshots waiting to be sent, and notify signals the send loop that there are any.
+	queueMu sync.Mutex
+	pending map[string]pendingPush
+	notify  chan struct{}
+
+	// mu guards the state of sent responses, which is updated by the send loop and the receiving goroutine, and the close error.
+	mu sync.Mutex
+	// types stores the state of responses sent to the subscriber by type URL.
+	types map[string]*typeState
+	// resourceVersions stores versions of resources sent over delta stream by type URL and resource name.
+	resourceVersions map[string]map[string]string
+	// err is returned to the subscriber when the server closes the stream.
+	err error
+}
+
+// typeState tracks the last response of the given type sent to the subscriber and its acknowledgement.
+type typeState struct {
+	version       string
+	nonce         string
+	ackedVersion  string
+	nackedVersion string
+	errorDetail   string
+}
+
+// closeWithError closes the stream and returns the given error to the subscriber.
+func (sub *subscriber) closeWithError(err error) {
+	sub.mu.Lock()
+	sub.err = err
+	sub.mu.Unlock()
+	sub.closeStream()
+}
+
+func (sub *subscriber) closeError() error {
+	sub.mu.Lock()
+	defer sub.mu.Unlock()
+	return sub.err
+}
+
+func newSubscriber(id uint64, closeStream func()) *subscriber {
+	return &subscriber{
+		id:               id,
+		closeStream:      closeStream,
+		pending:          make(map[string]pendingPush),
+		notify:           make(chan struct{}, 1),
+		types:            make(map[string]*typeState),
+		resourceVersions: make(map[string]map[string]string),
+	}
+}
+
+// state returns the state of the given type. The caller must hold the mutex.
+func (sub *subscriber) state(typeUrl string) *typeState {
+	state, found := sub.types[typeUrl]
+	if !found {
+		state = &typeState{}
+		sub.types[typeUrl] = state
+	}
+	return state
+}
+
+// recordResponse records ACK or NACK of the response with the given nonce. Responses to stale nonces are ignored,
+// because a newer response was already sent and the subscriber will respond to it as well.
+// State-of-the-world subscribers send the last accepted version, while delta subscribers do not send versions at all,
+// so the version of the acknowledged response is taken from the state in that case.
+func (sub *subscriber) recordResponse(typeUrl, nonce, version string, errorDetail *rpcstatus.Status) {
+	sub.mu.Lock()
+	defer sub.mu.Unlock()
+
+	state := sub.state(typeUrl)
+	if nonce != state.nonce {
+		log.Debugf("Ignoring response of subscriber %s to stale nonce %s of type %s", fmt.Sprintf(subIDFmtStr, sub.id), nonce, typeUrl)
+		return
+	}
+	if errorDetail != nil {
+		log.Errorf("Subscriber %s rejected version %s of type %s: %s", fmt.Sprintf(subIDFmtStr, sub.id), state.version, typeUrl, errorDetail.GetMessage())
+		state.nackedVersion = state.version
+		state.errorDetail = errorDetail.GetMessage()
+		rejectedSnapshot.WithLabelValues(subscriberLabels(typeUrl, sub.identity)...).Set(1)
+		return
+	}
+	if version == "" {
+		version = state.version
+	}
+	log.Debugf("Subscriber %s acknowledged version %s of type %s", fmt.Sprintf(subIDFmtStr, sub.id), version, typeUrl)
+	state.ackedVersion = version
+	rejectedSnapshot.WithLabelValues(subscriberLabels(typeUrl, sub.identity)...).Set(0)
+}
+
+// SubscriberStatus describes responses sent to a subscriber and whether the subscriber accepted them.
+type SubscriberStatus struct {
+	ID       uint64
+	Identity Identity
+	Delta    bool
+	// Types contains the status of responses by type URL.
+	Types map[string]TypeStatus
+}
+
+// TypeStatus describes the last response of the given type sent to a subscriber.
+type TypeStatus struct {
+	// SentVersion is the version of the last response.
+	SentVersion string
+	// AckedVersion is the last version accepted by the subscriber.
+	AckedVersion string
+	// NackedVersion is the last version rejected by the subscriber and ErrorDetail is the reason of the rejection.
+	NackedVersion string
+	ErrorDetail   string
+	// GenerationError is set if the subscriber is served the last known-good snapshot, because generating resources failed.
+	GenerationError error
+}
+
+// Rejected returns true if the subscriber rejected the last response.
+func (s TypeStatus) Rejected() bool {
+	return s.NackedVersion != "" && s.NackedVersion == s.SentVersion
+}
+
+func (sub *subscriber) status(snapshots *snapshotCache) SubscriberStatus {
+	sub.mu.Lock()
+	defer sub.mu.Unlock()
+
+	types := make(map[string]TypeStatus, len(sub.types))
+	for typeUrl, state := range sub.types {
+		types[typeUrl] = TypeStatus{
+			SentVersion:     state.version,
+			AckedVersion:    state.ackedVersion,
+			NackedVersion:   state.nackedVersion,
+			ErrorDetail:     state.errorDetail,
+			GenerationError: snapshots.staleError(typeUrl, sub.identity),
+		}
+	}
+	return SubscriberStatus{
+		ID:       sub.id,
+		Identity: sub.identity,
+		Delta:    sub.deltaStream != nil,
+		Types:    types,
+	}
+}
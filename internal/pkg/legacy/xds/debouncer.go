@@ -0,0 +1,143 @@
+// Copyright Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the License);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an AS IS BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"context"
+	"time"
+
+	"k8s.io/utils/clock"
+)
+
+// debouncerQueueSize is the capacity of the input channel, so that producers are not blocked while pending requests
+// are delivered to the consumer.
+const debouncerQueueSize = 100
+
+// Debouncer merges push requests of the same type received in a short time, so that bursts of events,
+// e.g. during a rolling deployment of many Services, trigger a single push per type.
+// Pending requests are delivered once no request has been received for the quiet period,
+// but no later than maxDelay after the first pending request, so that a constant stream of events cannot delay pushes forever.
+type Debouncer struct {
+	in          chan PushRequest
+	out         chan PushRequest
+	quietPeriod time.Duration
+	maxDelay    time.Duration
+	clock       clock.Clock
+}
+
+// NewDebouncer creates a debouncer. Requests are passed through without delay if quietPeriod is not greater than zero.
+func NewDebouncer(quietPeriod, maxDelay time.Duration) *Debouncer {
+	if maxDelay < quietPeriod {
+		maxDelay = quietPeriod
+	}
+	return &Debouncer{
+		in:          make(chan PushRequest, debouncerQueueSize),
+		out:         make(chan PushRequest),
+		quietPeriod: quietPeriod,
+		maxDelay:    maxDelay,
+		clock:       clock.RealClock{},
+	}
+}
+
+// PushRequests returns the channel accepting push requests to debounce.
+func (d *Debouncer) PushRequests() chan<- PushRequest {
+	return d.in
+}
+
+// Debounced returns the channel delivering merged push requests.
+func (d *Debouncer) Debounced() <-chan PushRequest {
+	return d.out
+}
+
+// Run merges and delivers push requests until the context is done.
+// A request replaces a pending request of the same type, because both describe the complete state of that type.
+func (d *Debouncer) Run(ctx context.Context) {
+	var (
+		pending = make(map[string]PushRequest)
+		// order preserves the order in which types were requested for the first time in the current batch.
+		order []string
+		// ready is set when the pending requests are being delivered.
+		ready     bool
+		firstSeen time.Time
+		// timer is created by the first request, which is delayed by the quiet period.
+		timer  clock.Timer
+		timerC <-chan time.Time
+	)
+	defer func() {
+		if timer != nil {
+			timer.Stop()
+		}
+	}()
+
+	for {
+		var (
+			out  chan<- PushRequest
+			next PushRequest
+		)
+		if ready && len(order) > 0 {
+			out, next = d.out, pending[order[0]]
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+
+		case req := <-d.in:
+			if _, found := pending[req.TypeUrl]; !found {
+				order = append(order, req.TypeUrl)
+			}
+			pending[req.TypeUrl] = req
+			if ready {
+				// The request is delivered with the current batch.
+				continue
+			}
+			if d.quietPeriod <= 0 {
+				ready = true
+				continue
+			}
+			now := d.clock.Now()
+			if firstSeen.IsZero() {
+				firstSeen = now
+			}
+			delay := d.quietPeriod
+			if deadline := firstSeen.Add(d.maxDelay); now.Add(delay).After(deadline) {
+				delay = deadline.Sub(now)
+			}
+			if timer == nil {
+				timer = d.clock.NewTimer(delay)
+				timerC = timer.C()
+				continue
+			}
+			if !timer.Stop() {
+				select {
+				case <-timerC:
+				default:
+				}
+			}
+			timer.Reset(delay)
+
+		case <-timerC:
+			ready = true
+
+		case out <- next:
+			delete(pending, order[0])
+			order = order[1:]
+			if len(order) == 0 {
+				ready = false
+				firstSeen = time.Time{}
+			}
+		}
+	}
+}
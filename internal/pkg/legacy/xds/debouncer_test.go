@@ -0,0 +1,154 @@
+// Copyright Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the License);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an AS IS BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	"k8s.io/utils/clock"
+	testingclock "k8s.io/utils/clock/testing"
+)
+
+const testTimeout = 5 * time.Second
+
+// timerClock reports delays of timers set by the debouncer, so that tests advance the fake time
+// only after the debouncer handled the last request.
+type timerClock struct {
+	*testingclock.FakeClock
+	delays chan time.Duration
+}
+
+func (c *timerClock) NewTimer(d time.Duration) clock.Timer {
+	c.delays <- d
+	return &reportingTimer{Timer: c.FakeClock.NewTimer(d), delays: c.delays}
+}
+
+type reportingTimer struct {
+	clock.Timer
+	delays chan time.Duration
+}
+
+func (t *reportingTimer) Reset(d time.Duration) bool {
+	stopped := t.Timer.Reset(d)
+	t.delays <- d
+	return stopped
+}
+
+// startDebouncer runs the debouncer with the fake clock. The input channel is unbuffered, so that sending a request
+// returns only after the debouncer received it.
+func startDebouncer(t *testing.T, quietPeriod, maxDelay time.Duration) (*Debouncer, *timerClock) {
+	t.Helper()
+	fakeClock := &timerClock{
+		FakeClock: testingclock.NewFakeClock(time.Now()),
+		delays:    make(chan time.Duration, 10),
+	}
+	d := NewDebouncer(quietPeriod, maxDelay)
+	d.in = make(chan PushRequest)
+	d.clock = fakeClock
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go d.Run(ctx)
+	return d, fakeClock
+}
+
+// push sends the request and returns the delay of the timer set by the debouncer.
+func (c *timerClock) push(t *testing.T, d *Debouncer, req PushRequest) time.Duration {
+	t.Helper()
+	d.PushRequests() <- req
+	select {
+	case delay := <-c.delays:
+		return delay
+	case <-time.After(testTimeout):
+		t.Fatalf("timed out waiting for the debouncer to handle %s", req.TypeUrl)
+	}
+	return 0
+}
+
+func expectDebounced(t *testing.T, d *Debouncer, expected PushRequest) {
+	t.Helper()
+	select {
+	case req := <-d.Debounced():
+		if req.TypeUrl != expected.TypeUrl || len(req.Resources) != len(expected.Resources) {
+			t.Errorf("expected %s with %d resources, got %s with %d resources",
+				expected.TypeUrl, len(expected.Resources), req.TypeUrl, len(req.Resources))
+		}
+	case <-time.After(testTimeout):
+		t.Fatalf("timed out waiting for %s", expected.TypeUrl)
+	}
+}
+
+func TestDebouncerMergesRequestsOfTheSameType(t *testing.T) {
+	d, fakeClock := startDebouncer(t, time.Second, 10*time.Second)
+	services := PushRequest{TypeUrl: ExportedServiceTypeUrl}
+	gateways := PushRequest{TypeUrl: GatewayTypeUrl}
+	latestServices := PushRequest{TypeUrl: ExportedServiceTypeUrl, Resources: make([]*discovery.Resource, 2)}
+
+	fakeClock.push(t, d, services)
+	fakeClock.push(t, d, gateways)
+	fakeClock.push(t, d, latestServices)
+	fakeClock.Step(time.Second)
+
+	// Types are delivered in the order of their first request, and the latest request of the type replaces previous ones.
+	expectDebounced(t, d, latestServices)
+	expectDebounced(t, d, gateways)
+
+	// The next batch starts with a new request only, so nothing else was pending.
+	routes := PushRequest{TypeUrl: RouteTypeUrl}
+	fakeClock.push(t, d, routes)
+	fakeClock.Step(time.Second)
+	expectDebounced(t, d, routes)
+}
+
+func TestDebouncerDeliversWithinMaxDelay(t *testing.T) {
+	quietPeriod, maxDelay := 100*time.Millisecond, 250*time.Millisecond
+	d, fakeClock := startDebouncer(t, quietPeriod, maxDelay)
+	req := PushRequest{TypeUrl: ExportedServiceTypeUrl}
+
+	// Requests keep arriving before the quiet period elapses, so only the max delay bounds the batch.
+	expectedDelays := []time.Duration{quietPeriod, quietPeriod, 70 * time.Millisecond}
+	for i, expected := range expectedDelays {
+		if i > 0 {
+			fakeClock.Step(90 * time.Millisecond)
+		}
+		if delay := fakeClock.push(t, d, req); delay != expected {
+			t.Errorf("request %d: expected delay %s, got %s", i, expected, delay)
+		}
+	}
+	fakeClock.Step(70 * time.Millisecond)
+	expectDebounced(t, d, req)
+
+	// The max delay is measured from the first request of the next batch.
+	if delay := fakeClock.push(t, d, req); delay != quietPeriod {
+		t.Errorf("expected delay %s in the next batch, got %s", quietPeriod, delay)
+	}
+}
+
+func TestDebouncerPassesThroughWithoutQuietPeriod(t *testing.T) {
+	d, fakeClock := startDebouncer(t, 0, 0)
+
+	for _, req := range []PushRequest{{TypeUrl: ExportedServiceTypeUrl}, {TypeUrl: GatewayTypeUrl}, {TypeUrl: ExportedServiceTypeUrl}} {
+		d.PushRequests() <- req
+		expectDebounced(t, d, req)
+	}
+	select {
+	case delay := <-fakeClock.delays:
+		t.Errorf("expected requests to be passed through without timers, got delay %s", delay)
+	default:
+	}
+}
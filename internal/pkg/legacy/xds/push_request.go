@@ -14,7 +14,7 @@
 
 package xds
 
-import "google.golang.org/protobuf/types/known/anypb"
+import discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
 
 // PushRequest notifies ADS server that it should send DiscoveryResponse to subscribers.
 type PushRequest struct {
@@ -22,5 +22,5 @@ type PushRequest struct {
 	TypeUrl string
 	// Resources contains data to be sent to subscribers.
 	// If it is not set, ADS server will trigger proper request handler to generate resources of given type.
-	Resources []*anypb.Any
+	Resources []*discovery.Resource
 }
@@ -0,0 +1,219 @@
+// Copyright Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the License);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an AS IS BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mtls
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/spiffe/go-spiffe/v2/spiffeid"
+	"github.com/spiffe/go-spiffe/v2/svid/x509svid"
+)
+
+// Source determines where certificates used by FDS connections are loaded from.
+type Source string
+
+const (
+	// Files loads the certificate, the private key and CA certificates from PEM files.
+	Files Source = "files"
+	// SPIFFE fetches X.509 SVIDs and trust bundles from the SPIFFE Workload API.
+	SPIFFE Source = "spiffe"
+)
+
+// Config configures mutual TLS of FDS connections. Mutual TLS is disabled if the source is not set,
+// in which case FDS connections are expected to be secured by the sidecar.
+type Config struct {
+	Source Source
+	// CertFile, KeyFile and CAFile are paths to PEM files used by the Files source.
+	// The files are read on every handshake, so that rotated certificates are used without a restart.
+	CertFile string
+	KeyFile  string
+	CAFile   string
+	// WorkloadAPIAddr is the address of the SPIFFE Workload API used by the SPIFFE source,
+	// e.g. unix:///run/spire/sockets/agent.sock. Defaults to SPIFFE_ENDPOINT_SOCKET environment variable.
+	WorkloadAPIAddr string
+	// TrustDomain is the trust domain of the local mesh. The local certificate must have a SPIFFE ID in this trust domain.
+	TrustDomain string
+}
+
+func (c Config) Enabled() bool {
+	return c.Source != ""
+}
+
+func (c Config) Validate() error {
+	switch c.Source {
+	case "":
+		return nil
+	case Files:
+		if c.CertFile == "" || c.KeyFile == "" || c.CAFile == "" {
+			return errors.New("certificate, private key and CA files are required")
+		}
+	case SPIFFE:
+	default:
+		return fmt.Errorf("unknown certificate source %q, expected one of: %s, %s", c.Source, Files, SPIFFE)
+	}
+	if c.TrustDomain == "" {
+		return errors.New("trust domain is required")
+	}
+	if _, err := spiffeid.TrustDomainFromString(c.TrustDomain); err != nil {
+		return fmt.Errorf("invalid trust domain %q: %w", c.TrustDomain, err)
+	}
+	return nil
+}
+
+// Authorizer decides if the peer presenting the given certificate is allowed to connect.
+// It is called after the certificate chain has been verified.
+type Authorizer func(leaf *x509.Certificate) error
+
+// AuthorizeTrustDomains allows peers with a SPIFFE ID in any of the trust domains returned by the given function.
+// The function is called on every handshake, so the set of trust domains can change at runtime.
+func AuthorizeTrustDomains(trustDomains func() []string) Authorizer {
+	return func(leaf *x509.Certificate) error {
+		id, err := x509svid.IDFromCert(leaf)
+		if err != nil {
+			return fmt.Errorf("peer certificate has no SPIFFE ID: %w", err)
+		}
+		if !slices.Contains(trustDomains(), id.TrustDomain().Name()) {
+			return fmt.Errorf("trust domain of peer %s is not allowed", id)
+		}
+		return nil
+	}
+}
+
+// AuthorizeSubjectAltNames allows peers with a certificate containing any of the given URI or DNS subject alternative names.
+func AuthorizeSubjectAltNames(sans ...string) Authorizer {
+	return func(leaf *x509.Certificate) error {
+		for _, uri := range leaf.URIs {
+			if slices.Contains(sans, uri.String()) {
+				return nil
+			}
+		}
+		for _, dnsName := range leaf.DNSNames {
+			if slices.Contains(sans, dnsName) {
+				return nil
+			}
+		}
+		return fmt.Errorf("peer certificate does not match any of the allowed subject alternative names %v", sans)
+	}
+}
+
+// certSource provides the local certificate and verifies certificate chains presented by peers.
+type certSource interface {
+	certificate() (*tls.Certificate, error)
+	verify(rawCerts [][]byte) (*x509.Certificate, error)
+	close() error
+}
+
+// Credentials provide TLS configurations for FDS servers and clients.
+type Credentials struct {
+	trustDomain spiffeid.TrustDomain
+	source      certSource
+}
+
+// New loads the local certificate from the configured source and checks that it belongs to the local trust domain.
+// When the SPIFFE source is used, New blocks until the first SVID is received or the context is done.
+func New(ctx context.Context, cfg Config) (*Credentials, error) {
+	if !cfg.Enabled() {
+		return nil, errors.New("mTLS is disabled")
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	trustDomain, err := spiffeid.TrustDomainFromString(cfg.TrustDomain)
+	if err != nil {
+		return nil, err
+	}
+
+	var source certSource
+	switch cfg.Source {
+	case Files:
+		source = &fileSource{certFile: cfg.CertFile, keyFile: cfg.KeyFile, caFile: cfg.CAFile}
+	case SPIFFE:
+		if source, err = newSPIFFESource(ctx, cfg.WorkloadAPIAddr); err != nil {
+			return nil, err
+		}
+	}
+
+	c := &Credentials{trustDomain: trustDomain, source: source}
+	if _, err := c.certificate(); err != nil {
+		return nil, errors.Join(err, source.close())
+	}
+	return c, nil
+}
+
+// TrustDomain returns the trust domain of the local mesh.
+func (c *Credentials) TrustDomain() string {
+	return c.trustDomain.Name()
+}
+
+// ServerConfig returns TLS configuration for the FDS server, which requires client certificates
+// and authorizes clients with the given authorizer.
+func (c *Credentials) ServerConfig(authorize Authorizer) *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ClientAuth: tls.RequireAnyClientCert,
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			return c.certificate()
+		},
+		VerifyPeerCertificate: c.verifyPeer(authorize),
+	}
+}
+
+// ClientConfig returns TLS configuration for FDS clients, which authorizes the server with the given authorizer.
+func (c *Credentials) ClientConfig(authorize Authorizer) *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		// SPIFFE certificates are not issued for host names, so the default verification is replaced by VerifyPeerCertificate.
+		InsecureSkipVerify: true, //nolint:gosec
+		GetClientCertificate: func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			return c.certificate()
+		},
+		VerifyPeerCertificate: c.verifyPeer(authorize),
+	}
+}
+
+// Close stops watching the SPIFFE Workload API.
+func (c *Credentials) Close() error {
+	return c.source.close()
+}
+
+func (c *Credentials) certificate() (*tls.Certificate, error) {
+	cert, err := c.source.certificate()
+	if err != nil {
+		return nil, err
+	}
+	id, err := x509svid.IDFromCert(cert.Leaf)
+	if err != nil {
+		return nil, fmt.Errorf("local certificate has no SPIFFE ID: %w", err)
+	}
+	if !id.MemberOf(c.trustDomain) {
+		return nil, fmt.Errorf("local SPIFFE ID %s does not belong to trust domain %s", id, c.trustDomain)
+	}
+	return cert, nil
+}
+
+func (c *Credentials) verifyPeer(authorize Authorizer) func([][]byte, [][]*x509.Certificate) error {
+	return func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+		leaf, err := c.source.verify(rawCerts)
+		if err != nil {
+			return fmt.Errorf("failed verifying peer certificate: %w", err)
+		}
+		return authorize(leaf)
+	}
+}
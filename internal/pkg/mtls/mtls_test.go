@@ -0,0 +1,219 @@
+// Copyright Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the License);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an AS IS BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mtls
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+func newTestCA(t *testing.T) *testCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test-ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("failed to create CA certificate: %v", err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	return &testCA{cert: cert, key: key}
+}
+
+// issue writes a certificate with the given SPIFFE ID and its private key to the directory,
+// and returns paths to the files.
+func (ca *testCA) issue(t *testing.T, dir, spiffeID string) (string, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	uri, _ := url.Parse(spiffeID)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		URIs:         []*url.URL{uri},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatalf("failed to create certificate: %v", err)
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatalf("failed to marshal key: %v", err)
+	}
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	writePEM(t, certFile, "CERTIFICATE", der)
+	writePEM(t, keyFile, "PRIVATE KEY", keyDER)
+	return certFile, keyFile
+}
+
+func (ca *testCA) write(t *testing.T, dir string) string {
+	t.Helper()
+	caFile := filepath.Join(dir, "ca.crt")
+	writePEM(t, caFile, "CERTIFICATE", ca.cert.Raw)
+	return caFile
+}
+
+func writePEM(t *testing.T, path, blockType string, der []byte) {
+	t.Helper()
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0o600); err != nil {
+		t.Fatalf("failed to write %s: %v", path, err)
+	}
+}
+
+func newFileCredentials(t *testing.T, ca, trustedCA *testCA, spiffeID, trustDomain string) (*Credentials, error) {
+	t.Helper()
+	dir := t.TempDir()
+	certFile, keyFile := ca.issue(t, dir, spiffeID)
+	return New(context.Background(), Config{
+		Source:      Files,
+		CertFile:    certFile,
+		KeyFile:     keyFile,
+		CAFile:      trustedCA.write(t, dir),
+		TrustDomain: trustDomain,
+	})
+}
+
+func TestNewRejectsCertificateFromOtherTrustDomain(t *testing.T) {
+	ca := newTestCA(t)
+	if _, err := newFileCredentials(t, ca, ca, "spiffe://west.local/ns/istio-system/sa/federation-controller", "east.local"); err == nil {
+		t.Errorf("expected an error for a certificate outside of the local trust domain")
+	}
+}
+
+func TestHandshake(t *testing.T) {
+	ca := newTestCA(t)
+	otherCA := newTestCA(t)
+
+	server, err := newFileCredentials(t, ca, ca, "spiffe://east.local/ns/istio-system/sa/federation-controller", "east.local")
+	if err != nil {
+		t.Fatalf("failed to load server credentials: %v", err)
+	}
+	allowedTrustDomains := func() []string {
+		return []string{"east.local", "west.local"}
+	}
+
+	testCases := []struct {
+		name            string
+		clientCA        *testCA
+		clientID        string
+		clientTD        string
+		serverAuthorize Authorizer
+		expectErr       bool
+	}{{
+		name:            "client from an allowed trust domain",
+		clientCA:        ca,
+		clientID:        "spiffe://west.local/ns/istio-system/sa/federation-controller",
+		clientTD:        "west.local",
+		serverAuthorize: AuthorizeTrustDomains(func() []string { return []string{"east.local"} }),
+	}, {
+		name:            "server authorized by subject alternative name",
+		clientCA:        ca,
+		clientID:        "spiffe://west.local/ns/istio-system/sa/federation-controller",
+		clientTD:        "west.local",
+		serverAuthorize: AuthorizeSubjectAltNames("spiffe://east.local/ns/istio-system/sa/federation-controller"),
+	}, {
+		name:            "client from a trust domain that is not allowed",
+		clientCA:        ca,
+		clientID:        "spiffe://south.local/ns/istio-system/sa/federation-controller",
+		clientTD:        "south.local",
+		serverAuthorize: AuthorizeTrustDomains(func() []string { return []string{"east.local"} }),
+		expectErr:       true,
+	}, {
+		name:            "server with unexpected subject alternative name",
+		clientCA:        ca,
+		clientID:        "spiffe://west.local/ns/istio-system/sa/federation-controller",
+		clientTD:        "west.local",
+		serverAuthorize: AuthorizeSubjectAltNames("spiffe://east.local/ns/istio-system/sa/other"),
+		expectErr:       true,
+	}, {
+		name:            "client certificate issued by an untrusted CA",
+		clientCA:        otherCA,
+		clientID:        "spiffe://west.local/ns/istio-system/sa/federation-controller",
+		clientTD:        "west.local",
+		serverAuthorize: AuthorizeTrustDomains(func() []string { return []string{"east.local"} }),
+		expectErr:       true,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			client, err := newFileCredentials(t, tc.clientCA, ca, tc.clientID, tc.clientTD)
+			if err != nil {
+				t.Fatalf("failed to load client credentials: %v", err)
+			}
+
+			listener, err := tls.Listen("tcp", "127.0.0.1:0", server.ServerConfig(AuthorizeTrustDomains(allowedTrustDomains)))
+			if err != nil {
+				t.Fatalf("failed to listen: %v", err)
+			}
+			defer listener.Close()
+
+			serverErr := make(chan error, 1)
+			go func() {
+				conn, err := listener.Accept()
+				if err != nil {
+					serverErr <- err
+					return
+				}
+				defer conn.Close()
+				serverErr <- conn.(*tls.Conn).Handshake()
+			}()
+
+			conn, err := tls.Dial("tcp", listener.Addr().String(), client.ClientConfig(tc.serverAuthorize))
+			if err == nil {
+				// The client completes the handshake before the server verifies the client certificate.
+				err = <-serverErr
+				conn.Close()
+			}
+			if tc.expectErr && err == nil {
+				t.Errorf("expected handshake to fail")
+			}
+			if !tc.expectErr && err != nil {
+				t.Errorf("unexpected handshake error: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,70 @@
+// Copyright Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the License);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an AS IS BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mtls
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+
+	"github.com/spiffe/go-spiffe/v2/svid/x509svid"
+	"github.com/spiffe/go-spiffe/v2/workloadapi"
+)
+
+// spiffeSource serves the latest X.509 SVID received from the Workload API and verifies peers against
+// the trust bundles of all trust domains known to the Workload API, including federated ones.
+type spiffeSource struct {
+	x509Source *workloadapi.X509Source
+}
+
+func newSPIFFESource(ctx context.Context, addr string) (*spiffeSource, error) {
+	var opts []workloadapi.X509SourceOption
+	if addr != "" {
+		opts = append(opts, workloadapi.WithClientOptions(workloadapi.WithAddr(addr)))
+	}
+	x509Source, err := workloadapi.NewX509Source(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed fetching X.509 SVID from the Workload API: %w", err)
+	}
+	return &spiffeSource{x509Source: x509Source}, nil
+}
+
+func (s *spiffeSource) certificate() (*tls.Certificate, error) {
+	svid, err := s.x509Source.GetX509SVID()
+	if err != nil {
+		return nil, fmt.Errorf("failed getting X.509 SVID: %w", err)
+	}
+	cert := &tls.Certificate{
+		PrivateKey: svid.PrivateKey,
+		Leaf:       svid.Certificates[0],
+	}
+	for _, c := range svid.Certificates {
+		cert.Certificate = append(cert.Certificate, c.Raw)
+	}
+	return cert, nil
+}
+
+func (s *spiffeSource) verify(rawCerts [][]byte) (*x509.Certificate, error) {
+	_, chains, err := x509svid.ParseAndVerify(rawCerts, s.x509Source)
+	if err != nil {
+		return nil, err
+	}
+	return chains[0][0], nil
+}
+
+func (s *spiffeSource) close() error {
+	return s.x509Source.Close()
+}
@@ -0,0 +1,35 @@
+// Copyright Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the License);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an AS IS BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package networking
+
+import "strings"
+
+// serviceHostnameSuffix is the suffix of hostnames of Kubernetes services.
+const serviceHostnameSuffix = ".svc.cluster.local"
+
+// ParseServiceHostname returns the name and namespace of the Kubernetes service with the given hostname,
+// e.g. reviews.bookinfo.svc.cluster.local. It returns false if the hostname does not identify a Kubernetes service,
+// e.g. if it is a local alias like reviews.west.global.
+func ParseServiceHostname(hostname string) (string, string, bool) {
+	prefix, found := strings.CutSuffix(hostname, serviceHostnameSuffix)
+	if !found {
+		return "", "", false
+	}
+	name, namespace, found := strings.Cut(prefix, ".")
+	if !found || name == "" || namespace == "" || strings.Contains(namespace, ".") {
+		return "", "", false
+	}
+	return name, namespace, true
+}
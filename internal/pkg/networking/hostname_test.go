@@ -0,0 +1,47 @@
+// Copyright Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the License);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an AS IS BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package networking
+
+import "testing"
+
+func TestParseServiceHostname(t *testing.T) {
+	testCases := []struct {
+		hostname          string
+		expectedName      string
+		expectedNamespace string
+		expectedOK        bool
+	}{{
+		hostname:          "reviews.bookinfo.svc.cluster.local",
+		expectedName:      "reviews",
+		expectedNamespace: "bookinfo",
+		expectedOK:        true,
+	}, {
+		hostname: "reviews.west.global",
+	}, {
+		hostname: "reviews.bookinfo.west.svc.cluster.local",
+	}, {
+		hostname: "bookinfo.svc.cluster.local",
+	}, {
+		hostname: ".bookinfo.svc.cluster.local",
+	}}
+	for _, tc := range testCases {
+		t.Run(tc.hostname, func(t *testing.T) {
+			name, namespace, ok := ParseServiceHostname(tc.hostname)
+			if name != tc.expectedName || namespace != tc.expectedNamespace || ok != tc.expectedOK {
+				t.Errorf("expected (%q, %q, %t), got (%q, %q, %t)", tc.expectedName, tc.expectedNamespace, tc.expectedOK, name, namespace, ok)
+			}
+		})
+	}
+}
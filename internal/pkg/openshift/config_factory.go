@@ -19,35 +19,46 @@ import (
 
 	routev1 "github.com/openshift/api/route/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/util/intstr"
 	v1 "k8s.io/client-go/listers/core/v1"
 
+	"github.com/openshift-service-mesh/federation/internal/pkg/common"
 	"github.com/openshift-service-mesh/federation/internal/pkg/config"
 )
 
 type ConfigFactory struct {
-	cfg           config.Federation
-	serviceLister v1.ServiceLister
+	cfg             config.Federation
+	mode            common.Mode
+	serviceLister   v1.ServiceLister
+	namespaceLister v1.NamespaceLister
 }
 
 func NewConfigFactory(
 	cfg config.Federation,
+	mode common.Mode,
 	serviceLister v1.ServiceLister,
+	namespaceLister v1.NamespaceLister,
 ) *ConfigFactory {
 	return &ConfigFactory{
-		cfg:           cfg,
-		serviceLister: serviceLister,
+		cfg:             cfg,
+		mode:            mode,
+		serviceLister:   serviceLister,
+		namespaceLister: namespaceLister,
 	}
 }
 
+// Owner returns the federation instance, which owns generated objects.
+func (cf *ConfigFactory) Owner() common.Owner {
+	return common.Owner{Mode: cf.mode, Instance: cf.cfg.MeshPeers.Local.Name}
+}
+
 func (cf *ConfigFactory) Routes() ([]*routev1.Route, error) {
 	createRoute := func(svcName, svcNamespace string, port int32) *routev1.Route {
 		return &routev1.Route{
 			ObjectMeta: metav1.ObjectMeta{
-				Name:      fmt.Sprintf("%s-%s-%d-to-federation-ingress-gateway", svcName, svcNamespace, port),
+				Name:      common.ObjectName(cf.Owner(), fmt.Sprintf("%s-%s-%d-to-federation-ingress-gateway", svcName, svcNamespace, port)),
 				Namespace: cf.cfg.MeshPeers.Local.ControlPlane.Namespace,
-				Labels:    map[string]string{"federation.openshift-service-mesh.io/peer": "todo"},
+				Labels:    common.OwnerLabels(cf.Owner()),
 			},
 			Spec: routev1.RouteSpec{
 				Host: fmt.Sprintf("%s-%d.%s.svc.cluster.local", svcName, port, svcNamespace),
@@ -68,16 +79,13 @@ func (cf *ConfigFactory) Routes() ([]*routev1.Route, error) {
 	routes := []*routev1.Route{
 		createRoute(fmt.Sprintf("federation-discovery-service-%s", cf.cfg.MeshPeers.Local.Name), "istio-system", 15080),
 	}
-	for _, exportLabelSelector := range cf.cfg.ExportedServiceSet.GetLabelSelectors() {
-		matchLabels := labels.SelectorFromSet(exportLabelSelector.MatchLabels)
-		services, err := cf.serviceLister.List(matchLabels)
-		if err != nil {
-			return nil, fmt.Errorf("error listing services (selector=%s): %w", matchLabels, err)
-		}
-		for _, svc := range services {
-			for _, port := range svc.Spec.Ports {
-				routes = append(routes, createRoute(svc.Name, svc.Namespace, port.Port))
-			}
+	services, err := common.ExportedServices(cf.serviceLister, cf.namespaceLister, cf.cfg.ExportedServiceSet.Rules)
+	if err != nil {
+		return nil, err
+	}
+	for _, svc := range services {
+		for _, port := range svc.Spec.Ports {
+			routes = append(routes, createRoute(svc.Name, svc.Namespace, port.Port))
 		}
 	}
 	return routes, nil